@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	// Uncomment this block to pass the first stage!
 	// "flag"
 	"os"
+
+	"github.com/codecrafters-io/git-starter-go/internal/commit"
 )
 
 // Usage: your_git.sh <command> <arg1> <arg2> ...
@@ -14,16 +17,28 @@ func main() {
 
 	// Uncomment this block to pass the first stage!
 	//
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: mygit <command> [<args>...]\n")
+	args := os.Args[1:]
+	// -C <path> runs as if started in <path>, it may be given more than once
+	for len(args) >= 2 && args[0] == "-C" {
+		if err := os.Chdir(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: cannot change to '%s': %v\n", args[1], err)
+			os.Exit(1)
+		}
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "usage: mygit [-C <path>] <command> [<args>...]\n")
 		os.Exit(1)
 	}
-	subcommand, err := NewSubCommand(os.Args[1], os.Args[1:])
+	subcommand, err := NewSubCommand(args[0], args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 	err = subcommand.Run()
+	if errors.Is(err, commit.ErrAborted) {
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\nUsage: %v\n", err, subcommand.Usage())
 		os.Exit(1)
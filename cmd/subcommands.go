@@ -4,8 +4,22 @@ import (
 	"flag"
 	"fmt"
 
+	"github.com/codecrafters-io/git-starter-go/internal/add"
+	"github.com/codecrafters-io/git-starter-go/internal/branch"
+	"github.com/codecrafters-io/git-starter-go/internal/checkout"
 	"github.com/codecrafters-io/git-starter-go/internal/clone"
+	"github.com/codecrafters-io/git-starter-go/internal/commit"
+	"github.com/codecrafters-io/git-starter-go/internal/config"
 	"github.com/codecrafters-io/git-starter-go/internal/general"
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/indexpack"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/reset"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	"github.com/codecrafters-io/git-starter-go/internal/revwalk"
+	"github.com/codecrafters-io/git-starter-go/internal/rm"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
 	"github.com/codecrafters-io/git-starter-go/internal/tree"
 	"github.com/codecrafters-io/git-starter-go/internal/treecommit"
 	"github.com/codecrafters-io/git-starter-go/internal/treewriter"
@@ -17,32 +31,70 @@ type Subcommand interface {
 	Usage() string
 }
 
+// commands that cannot do anything outside of a repository, the others get a
+// nil repository when none is found
+var needsRepository = map[string]bool{
+	"cat-file":     true,
+	"ls-tree":      true,
+	"write-tree":   true,
+	"commit-tree":  true,
+	"commit":       true,
+	"add":          true,
+	"rm":           true,
+	"check-ignore": true,
+	"status":       true,
+	"update-ref":   true,
+	"symbolic-ref": true,
+	"show-ref":     true,
+	"for-each-ref": true,
+	"reflog":       true,
+	"rev-parse":    true,
+	"branch":       true,
+	"checkout":     true,
+	"switch":       true,
+	"restore":      true,
+	"reset":        true,
+	"log":          true,
+	"rev-list":     true,
+}
+
 func NewSubCommand(subComName string, args []string) (Subcommand, error) {
+	repo, err := repository.Discover()
+	if err != nil {
+		if needsRepository[subComName] {
+			return nil, err
+		}
+	}
 	switch subComName {
 	case "init":
 		initer := &general.Init{
 			Fs: flag.NewFlagSet("init", flag.ExitOnError),
 		}
-		if initer.Initialize(args) != nil {
+		if initer.Initialize(args[1:]) != nil {
 			return nil, fmt.Errorf("Error initializing command")
 		}
 		return initer, nil
 	case "cat-file":
 		catter := &general.Catfile{
-			Fs: flag.NewFlagSet("cat-file", flag.ExitOnError),
+			Fs:   flag.NewFlagSet("cat-file", flag.ExitOnError),
+			Repo: repo,
+		}
+		err := catter.Initialize(args[1:])
+		if err != nil {
+			return catter, err
 		}
-		catter.Initialize(args[1:])
 		return catter, nil
 
 	case "hash-object":
 		hasher := &general.HashObject{
-			Fs: flag.NewFlagSet("hash-object", flag.ExitOnError),
+			Fs:   flag.NewFlagSet("hash-object", flag.ExitOnError),
+			Repo: repo,
 		}
 		hasher.Initialize(args[1:])
 		return hasher, nil
 
 	case "ls-tree":
-		lstreer := &tree.LsTree{Fs: flag.NewFlagSet("ls-tree", flag.ExitOnError)}
+		lstreer := &tree.LsTree{Fs: flag.NewFlagSet("ls-tree", flag.ExitOnError), Repo: repo}
 		err := lstreer.Initialize(args[1:])
 		if err != nil {
 			return lstreer, err
@@ -50,19 +102,188 @@ func NewSubCommand(subComName string, args []string) (Subcommand, error) {
 		return lstreer, nil
 
 	case "write-tree":
-		writer := &treewriter.Treewriter{Fs: flag.NewFlagSet("write-tree", flag.ExitOnError)}
+		writer := &treewriter.Treewriter{Fs: flag.NewFlagSet("write-tree", flag.ExitOnError), Repo: repo}
 		writer.Initialize(args[1:])
 		return writer, nil
 
 	case "commit-tree":
-		commiter := &treecommit.Treecommit{Fs: flag.NewFlagSet("commit-tree", flag.ExitOnError)}
-		commiter.Initialize(args[1:])
+		commiter := &treecommit.Treecommit{Fs: flag.NewFlagSet("commit-tree", flag.ExitOnError), Repo: repo}
+		err := commiter.Initialize(args[1:])
+		if err != nil {
+			return commiter, err
+		}
 		return commiter, nil
 
+	case "commit":
+		committer := &commit.Commit{Fs: flag.NewFlagSet("commit", flag.ExitOnError), Repo: repo}
+		err := committer.Initialize(args[1:])
+		if err != nil {
+			return committer, err
+		}
+		return committer, nil
+
+	case "add":
+		adder := &add.Add{Fs: flag.NewFlagSet("add", flag.ExitOnError), Repo: repo}
+		err := adder.Initialize(args[1:])
+		if err != nil {
+			return adder, err
+		}
+		return adder, nil
+
+	case "rm":
+		remover := &rm.Rm{Fs: flag.NewFlagSet("rm", flag.ExitOnError), Repo: repo}
+		err := remover.Initialize(args[1:])
+		if err != nil {
+			return remover, err
+		}
+		return remover, nil
+
+	case "status":
+		statuser := &status.Status{Fs: flag.NewFlagSet("status", flag.ExitOnError), Repo: repo}
+		err := statuser.Initialize(args[1:])
+		if err != nil {
+			return statuser, err
+		}
+		return statuser, nil
+
+	case "update-ref":
+		updater := &refs.UpdateRef{Fs: flag.NewFlagSet("update-ref", flag.ExitOnError), Repo: repo}
+		err := updater.Initialize(args[1:])
+		if err != nil {
+			return updater, err
+		}
+		return updater, nil
+
+	case "symbolic-ref":
+		symrefer := &refs.SymbolicRef{Fs: flag.NewFlagSet("symbolic-ref", flag.ExitOnError), Repo: repo}
+		err := symrefer.Initialize(args[1:])
+		if err != nil {
+			return symrefer, err
+		}
+		return symrefer, nil
+
+	case "show-ref":
+		shower := &refs.ShowRef{Fs: flag.NewFlagSet("show-ref", flag.ExitOnError), Repo: repo}
+		err := shower.Initialize(args[1:])
+		if err != nil {
+			return shower, err
+		}
+		return shower, nil
+
+	case "for-each-ref":
+		lister := &refs.ForEachRef{Fs: flag.NewFlagSet("for-each-ref", flag.ExitOnError), Repo: repo}
+		err := lister.Initialize(args[1:])
+		if err != nil {
+			return lister, err
+		}
+		return lister, nil
+
+	case "reflog":
+		reflogger := &revwalk.Reflog{Fs: flag.NewFlagSet("reflog", flag.ExitOnError), Repo: repo}
+		err := reflogger.Initialize(args[1:])
+		if err != nil {
+			return reflogger, err
+		}
+		return reflogger, nil
+
+	case "rev-parse":
+		parser := &revision.RevParse{Fs: flag.NewFlagSet("rev-parse", flag.ExitOnError), Repo: repo}
+		err := parser.Initialize(args[1:])
+		if err != nil {
+			return parser, err
+		}
+		return parser, nil
+
+	case "branch":
+		brancher := &branch.Branch{Fs: flag.NewFlagSet("branch", flag.ExitOnError), Repo: repo}
+		err := brancher.Initialize(args[1:])
+		if err != nil {
+			return brancher, err
+		}
+		return brancher, nil
+
+	case "checkout":
+		checkouter := &checkout.Checkout{Fs: flag.NewFlagSet("checkout", flag.ExitOnError), Repo: repo}
+		err := checkouter.Initialize(args[1:])
+		if err != nil {
+			return checkouter, err
+		}
+		return checkouter, nil
+
+	case "switch":
+		switcher := &checkout.Switch{Fs: flag.NewFlagSet("switch", flag.ExitOnError), Repo: repo}
+		err := switcher.Initialize(args[1:])
+		if err != nil {
+			return switcher, err
+		}
+		return switcher, nil
+
+	case "restore":
+		restorer := &checkout.Restore{Fs: flag.NewFlagSet("restore", flag.ExitOnError), Repo: repo}
+		err := restorer.Initialize(args[1:])
+		if err != nil {
+			return restorer, err
+		}
+		return restorer, nil
+
+	case "reset":
+		resetter := &reset.Reset{Fs: flag.NewFlagSet("reset", flag.ExitOnError), Repo: repo}
+		err := resetter.Initialize(args[1:])
+		if err != nil {
+			return resetter, err
+		}
+		return resetter, nil
+
+	case "log":
+		logger := &revwalk.Log{Fs: flag.NewFlagSet("log", flag.ExitOnError), Repo: repo}
+		err := logger.Initialize(args[1:])
+		if err != nil {
+			return logger, err
+		}
+		return logger, nil
+
+	case "rev-list":
+		lister := &revwalk.RevList{Fs: flag.NewFlagSet("rev-list", flag.ExitOnError), Repo: repo}
+		err := lister.Initialize(args[1:])
+		if err != nil {
+			return lister, err
+		}
+		return lister, nil
+
 	case "clone":
 		cloner := &clone.Clone{Fs: flag.NewFlagSet("clone", flag.ExitOnError)}
-		cloner.Initialize(args[1:])
+		err := cloner.Initialize(args[1:])
+		if err != nil {
+			return cloner, err
+		}
 		return cloner, nil
+
+	case "index-pack":
+		indexer := &indexpack.IndexPack{Fs: flag.NewFlagSet("index-pack", flag.ExitOnError), Repo: repo}
+		err := indexer.Initialize(args[1:])
+		if err != nil {
+			return indexer, err
+		}
+		return indexer, nil
+
+	case "check-ignore":
+		checker := &ignore.CheckIgnore{Fs: flag.NewFlagSet("check-ignore", flag.ExitOnError), Repo: repo}
+		err := checker.Initialize(args[1:])
+		if err != nil {
+			return checker, err
+		}
+		return checker, nil
+
+	case "config":
+		configer := &config.ConfigCommand{Fs: flag.NewFlagSet("config", flag.ExitOnError)}
+		if repo != nil {
+			configer.GitDir = repo.CommonDir
+		}
+		err := configer.Initialize(args[1:])
+		if err != nil {
+			return configer, err
+		}
+		return configer, nil
 	default:
 		return nil, fmt.Errorf("Unknown command %s\nUsage: git <command> <args>", subComName)
 	}
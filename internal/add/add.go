@@ -0,0 +1,304 @@
+package add
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
+)
+
+type Add struct {
+	Fs        *flag.FlagSet
+	Repo      *repository.Repository
+	all       bool // also stage new and deleted files everywhere when no pathspec is given
+	update    bool // only stage files that are already tracked
+	dryRun    bool
+	verbose   bool
+	force     bool // allow adding ignored files
+	pathspecs []string
+	fileMode  bool // core.fileMode, when false the executable bit of files is not trusted
+}
+
+func (a *Add) Initialize(args []string) error {
+	a.Fs.BoolVar(&a.all, "A", false, "Add, modify and remove index entries to match the work tree")
+	a.Fs.BoolVar(&a.all, "all", false, "Same as -A")
+	a.Fs.BoolVar(&a.update, "u", false, "Only update files that are already tracked")
+	a.Fs.BoolVar(&a.update, "update", false, "Same as -u")
+	a.Fs.BoolVar(&a.dryRun, "n", false, "Do not stage anything, only show what would be done")
+	a.Fs.BoolVar(&a.dryRun, "dry-run", false, "Same as -n")
+	a.Fs.BoolVar(&a.verbose, "v", false, "Show the files being added and removed")
+	a.Fs.BoolVar(&a.verbose, "verbose", false, "Same as -v")
+	a.Fs.BoolVar(&a.force, "f", false, "Allow adding otherwise ignored files")
+	a.Fs.BoolVar(&a.force, "force", false, "Same as -f")
+	err := a.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if a.all && a.update {
+		return errors.New("Options -A and -u cannot be used together")
+	}
+	a.pathspecs = a.Fs.Args()
+	if len(a.pathspecs) == 0 && !a.all && !a.update {
+		return errors.New("Nothing specified, nothing added.\nMaybe you wanted to say 'git add .'?")
+	}
+	return nil
+}
+
+func (a *Add) Usage() string {
+	return "git add [-A | -u] [-n] [-v] [-f] [<pathspec>...] : Add file contents to the index"
+}
+
+// change is one update of the index, entry is nil for removals
+type change struct {
+	path  string
+	entry *index.Entry
+}
+
+func (a *Add) Run() error {
+	if err := a.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	idx, err := a.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	cfg, err := a.Repo.Config()
+	if err != nil {
+		return err
+	}
+	a.fileMode, err = cfg.GetBool("core.filemode", true)
+	if err != nil {
+		return err
+	}
+	matcher := ignore.NewMatcher(a.Repo.WorkTree, a.Repo.CommonDir, cfg)
+	ps, err := pathspec.Parse(a.Repo.WorkTree, a.pathspecs)
+	if err != nil {
+		return err
+	}
+
+	// tracked files are updated, or removed when they are gone, even if they
+	// are ignored
+	var changes []change
+	tracked := make(map[string]bool)
+	for _, path := range trackedPaths(idx) {
+		tracked[path] = true
+		if !ps.Match(path) {
+			continue
+		}
+		c, err := a.updateTracked(idx, path)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+
+	if !a.update {
+		walkMatcher := matcher
+		if a.force {
+			walkMatcher = nil
+		}
+		err := worktree.Walk(a.Repo.WorkTree, walkMatcher, func(name string, fi os.FileInfo) error {
+			if tracked[name] || !ps.Match(name) {
+				return nil
+			}
+			entry, err := a.stage(name, fi, nil)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change{path: name, entry: entry})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// pathspecs that matched nothing are only acceptable when they name an
+	// ignored file, which is refused but does not stop the others
+	var ignored []string
+	if !a.update {
+		for _, arg := range ps.Unmatched() {
+			name, isDir, exists := a.lookup(arg)
+			if exists && matcher.Ignored(name, isDir) {
+				ignored = append(ignored, arg)
+				continue
+			}
+			return fmt.Errorf("pathspec '%s' did not match any files", arg)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	for _, c := range changes {
+		action := "add"
+		if c.entry == nil {
+			action = "remove"
+			idx.Remove(c.path)
+		} else {
+			idx.Add(c.entry)
+		}
+		if a.dryRun || a.verbose {
+			fmt.Printf("%s '%s'\n", action, c.path)
+		}
+	}
+
+	if !a.dryRun {
+		if err := a.Repo.WriteIndex(idx); err != nil {
+			return err
+		}
+	}
+	if len(ignored) > 0 {
+		return fmt.Errorf("The following paths are ignored by one of your .gitignore files:\n%s\nhint: Use -f if you really want to add them.", strings.Join(ignored, "\n"))
+	}
+	return nil
+}
+
+// stage hashes a file of the work tree into a new index entry, the blob is
+// only written when this is not a dry run. A nested repository is staged as a
+// gitlink to the commit it has checked out. existing is the entry being
+// replaced, if any
+func (a *Add) stage(name string, fi os.FileInfo, existing *index.Entry) (*index.Entry, error) {
+	full := a.fullPath(name)
+	if fi.IsDir() {
+		hash, err := gitlinkHead(full, name)
+		if err != nil {
+			return nil, err
+		}
+		return index.NewEntry(name, hash, fi), nil
+	}
+	obj, err := worktree.ReadBlob(full, fi)
+	if err != nil {
+		return nil, err
+	}
+	return a.stageBlob(name, fi, obj, existing)
+}
+
+func (a *Add) stageBlob(name string, fi os.FileInfo, obj *objectstore.Object, existing *index.Entry) (*index.Entry, error) {
+	hash, err := worktree.HashBlob(a.Repo.Objects, obj, !a.dryRun)
+	if err != nil {
+		return nil, err
+	}
+	entry := index.NewEntry(name, hash, fi)
+	entry.Mode = a.mode(fi, existing)
+	return entry, nil
+}
+
+// mode is the mode to stage a file with. Without core.fileMode the executable
+// bit comes from the index, new files are never executable
+func (a *Add) mode(fi os.FileInfo, existing *index.Entry) object.FileMode {
+	mode := index.ModeFromFileInfo(fi)
+	if a.fileMode || (mode != object.ModeBlob && mode != object.ModeExecutable) {
+		return mode
+	}
+	if existing != nil && (existing.Mode == object.ModeBlob || existing.Mode == object.ModeExecutable) {
+		return existing.Mode
+	}
+	return object.ModeBlob
+}
+
+// gitlinkHead is the commit checked out in the nested repository at full
+func gitlinkHead(full, name string) (objectstore.Hash, error) {
+	nested, err := repository.Open(full)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	hash, err := refs.NewStore(nested).Head()
+	if err != nil {
+		return objectstore.ZeroHash, fmt.Errorf("'%s/' does not have a commit checked out", name)
+	}
+	return hash, nil
+}
+
+// updateTracked compares a tracked path with the work tree. It returns nil
+// when there is nothing to stage, refreshing the stat data on the way
+func (a *Add) updateTracked(idx *index.Index, name string) (*change, error) {
+	full := a.fullPath(name)
+	fi, err := os.Lstat(full)
+	if os.IsNotExist(err) {
+		return &change{path: name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current := idx.Stages(name)[0]
+	clean := current.Stage == index.StageMerged && !current.IntentToAdd
+	if fi.IsDir() {
+		if !worktree.IsNestedRepository(full) {
+			// the file became a directory, whose files are found by the walk
+			return &change{path: name}, nil
+		}
+		hash, err := gitlinkHead(full, name)
+		if err != nil {
+			return nil, err
+		}
+		if clean && current.Mode == object.ModeGitlink && hash == current.Hash {
+			return nil, nil
+		}
+		return &change{path: name, entry: index.NewEntry(name, hash, fi)}, nil
+	}
+
+	if clean && !current.Changed(fi) && !idx.IsRacy(current) {
+		return nil, nil
+	}
+	obj, err := worktree.ReadBlob(full, fi)
+	if err != nil {
+		return nil, err
+	}
+	if clean && obj.Hash() == current.Hash && a.mode(fi, current) == current.Mode {
+		current.SetStat(fi)
+		return nil, nil
+	}
+	entry, err := a.stageBlob(name, fi, obj, current)
+	if err != nil {
+		return nil, err
+	}
+	return &change{path: name, entry: entry}, nil
+}
+
+// lookup resolves a pathspec argument to a path of the work tree
+func (a *Add) lookup(arg string) (name string, isDir bool, exists bool) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", false, false
+	}
+	fi, err := os.Lstat(abs)
+	if err != nil {
+		return "", false, false
+	}
+	rel, err := filepath.Rel(a.Repo.WorkTree, abs)
+	if err != nil {
+		return "", false, false
+	}
+	return filepath.ToSlash(rel), fi.IsDir(), true
+}
+
+func (a *Add) fullPath(name string) string {
+	return filepath.Join(a.Repo.WorkTree, filepath.FromSlash(name))
+}
+
+// trackedPaths lists each path of the index once, conflicted or not
+func trackedPaths(idx *index.Index) []string {
+	var paths []string
+	for _, e := range idx.Entries {
+		if len(paths) == 0 || paths[len(paths)-1] != e.Path {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
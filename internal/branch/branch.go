@@ -0,0 +1,473 @@
+package branch
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	"github.com/codecrafters-io/git-starter-go/internal/revwalk"
+)
+
+type Branch struct {
+	Fs            *flag.FlagSet
+	Repo          *repository.Repository
+	list          bool
+	verbose       bool
+	veryVerbose   bool // -vv, also names the upstream
+	all           bool
+	remotes       bool
+	merged        string
+	noMerged      string
+	contains      string
+	noContains    string
+	showCurrent   bool
+	delete        bool
+	forceDelete   bool
+	move          bool
+	forceMove     bool
+	force         bool
+	track         bool
+	noTrack       bool
+	upstream      string
+	unsetUpstream bool
+	args          []string
+	store         *refs.Store
+	resolver      *revision.Resolver
+}
+
+func (b *Branch) Initialize(args []string) error {
+	b.Fs.BoolVar(&b.list, "l", false, "List branches, only those matching the patterns given")
+	b.Fs.BoolVar(&b.list, "list", false, "Same as -l")
+	b.Fs.BoolVar(&b.verbose, "v", false, "Show the commit and subject of each branch, and how it relates to its upstream")
+	b.Fs.BoolVar(&b.verbose, "verbose", false, "Same as -v")
+	b.Fs.BoolVar(&b.veryVerbose, "vv", false, "Like -v, and name the upstream too")
+	b.Fs.BoolVar(&b.all, "a", false, "List both local and remote-tracking branches")
+	b.Fs.BoolVar(&b.all, "all", false, "Same as -a")
+	b.Fs.BoolVar(&b.remotes, "r", false, "List or delete remote-tracking branches")
+	b.Fs.BoolVar(&b.remotes, "remotes", false, "Same as -r")
+	b.Fs.StringVar(&b.merged, "merged", "", "List only the branches reachable from the commit, HEAD without one")
+	b.Fs.StringVar(&b.noMerged, "no-merged", "", "List only the branches not reachable from the commit, HEAD without one")
+	b.Fs.StringVar(&b.contains, "contains", "", "List only the branches containing the commit, HEAD without one")
+	b.Fs.StringVar(&b.noContains, "no-contains", "", "List only the branches not containing the commit, HEAD without one")
+	b.Fs.BoolVar(&b.showCurrent, "show-current", false, "Print the name of the current branch")
+	b.Fs.BoolVar(&b.delete, "d", false, "Delete branches, which must be merged")
+	b.Fs.BoolVar(&b.delete, "delete", false, "Same as -d")
+	b.Fs.BoolVar(&b.forceDelete, "D", false, "Delete branches even when they are not merged")
+	b.Fs.BoolVar(&b.move, "m", false, "Rename a branch, with its reflog and configuration")
+	b.Fs.BoolVar(&b.move, "move", false, "Same as -m")
+	b.Fs.BoolVar(&b.forceMove, "M", false, "Rename a branch even when the new name exists")
+	b.Fs.BoolVar(&b.force, "f", false, "Reset an existing branch to the start point")
+	b.Fs.BoolVar(&b.force, "force", false, "Same as -f")
+	b.Fs.BoolVar(&b.track, "t", false, "Set up the start point as upstream, even a local branch")
+	b.Fs.BoolVar(&b.track, "track", false, "Same as -t")
+	b.Fs.BoolVar(&b.noTrack, "no-track", false, "Do not set up an upstream, whatever branch.autoSetupMerge says")
+	b.Fs.StringVar(&b.upstream, "u", "", "Set the upstream of the branch, the current one without a name")
+	b.Fs.StringVar(&b.upstream, "set-upstream-to", "", "Same as -u")
+	b.Fs.BoolVar(&b.unsetUpstream, "unset-upstream", false, "Remove the upstream of the branch, the current one without a name")
+	if err := b.Fs.Parse(commitValues(args)); err != nil {
+		return err
+	}
+	b.args = b.Fs.Args()
+	b.verbose = b.verbose || b.veryVerbose
+	if b.forceDelete {
+		b.delete, b.force = true, true
+	}
+	if b.forceMove {
+		b.move, b.force = true, true
+	}
+	return nil
+}
+
+// commitValues gives HEAD to the filtering options whose commit is left out,
+// which is when nothing or another option follows them
+func commitValues(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+		switch arg {
+		case "--merged", "--no-merged", "--contains", "--no-contains":
+			if i+1 == len(args) || strings.HasPrefix(args[i+1], "-") {
+				arg += "=HEAD"
+			}
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+func (b *Branch) Usage() string {
+	return "git branch [-v | -vv] [-a | -r] [--merged [<commit>]] [--contains [<commit>]] [--list] [<pattern>...] | " +
+		"git branch [-f] [--track | --no-track] <branch> [<start-point>] | git branch (-d | -D) [-r] <branch>... | " +
+		"git branch (-m | -M) [<old-branch>] <new-branch> | git branch (-u <upstream> | --unset-upstream) [<branch>] | " +
+		"git branch --show-current : List, create, delete or rename branches"
+}
+
+func (b *Branch) Run() error {
+	b.store = refs.NewStore(b.Repo)
+	b.resolver = revision.NewResolver(b.Repo)
+
+	listing := len(b.args) == 0 || b.list || b.verbose ||
+		b.merged != "" || b.noMerged != "" || b.contains != "" || b.noContains != ""
+	switch {
+	case b.showCurrent:
+		return b.runShowCurrent()
+	case b.delete:
+		return b.runDelete()
+	case b.move:
+		return b.runRename()
+	case b.upstream != "":
+		return b.runSetUpstream()
+	case b.unsetUpstream:
+		return b.runUnsetUpstream()
+	case listing:
+		return b.runList()
+	case b.all || b.remotes:
+		return errors.New("The -a, and -r, options to 'git branch' do not take a branch name.\nDid you mean to use: -a|-r --list <pattern>?")
+	}
+	return b.runCreate()
+}
+
+func (b *Branch) runShowCurrent() error {
+	branch, err := b.store.HeadBranch()
+	if err != nil {
+		return err
+	}
+	if branch != "" {
+		fmt.Println(strings.TrimPrefix(branch, "refs/heads/"))
+	}
+	return nil
+}
+
+// checkedOutAt is where a branch that is checked out lives
+func (b *Branch) checkedOutAt() string {
+	if b.Repo.IsBare() {
+		return b.Repo.GitDir
+	}
+	return b.Repo.WorkTree
+}
+
+// runCreate makes a branch at the start point, HEAD by default, or resets
+// one with -f
+func (b *Branch) runCreate() error {
+	if len(b.args) > 2 {
+		return errors.New("Too many arguments")
+	}
+	head, err := b.store.HeadBranch()
+	if err != nil {
+		return err
+	}
+	// like git, the start point defaults to the current branch by name
+	name, start := b.args[0], "HEAD"
+	if head != "" {
+		start = strings.TrimPrefix(head, "refs/heads/")
+	}
+	if len(b.args) == 2 {
+		start = b.args[1]
+	}
+	full, err := RefName(name)
+	if err != nil {
+		return err
+	}
+	_, readErr := b.store.Read(full)
+	exists := readErr == nil
+	if exists && !b.force {
+		return fmt.Errorf("A branch named '%s' already exists", name)
+	}
+	if exists && full == head {
+		return fmt.Errorf("Cannot force update the branch '%s' checked out at '%s'", name, b.checkedOutAt())
+	}
+
+	hash, err := b.resolver.ResolveType(start, packextractor.OBJ_COMMIT)
+	if err != nil {
+		return fmt.Errorf("Not a valid object name: '%s'", start)
+	}
+	upstream, err := Tracking(b.Repo, start, b.track, b.noTrack)
+	if err != nil {
+		return err
+	}
+	message := "branch: Created from " + start
+	if exists {
+		message = "branch: Reset to " + start
+	}
+	if err := b.store.Update(full, hash, refs.UpdateOptions{Message: message}); err != nil {
+		return err
+	}
+	if upstream == nil {
+		return nil
+	}
+	return SetUpstream(b.Repo, name, upstream)
+}
+
+// currentBranch is the branch a command works on when none is named
+func (b *Branch) currentBranch() (string, error) {
+	if len(b.args) > 0 {
+		return b.args[0], nil
+	}
+	head, err := b.store.HeadBranch()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(head, "refs/heads/"), nil
+}
+
+// runSetUpstream makes a local or remote-tracking branch the upstream of
+// a branch, the current one by default
+func (b *Branch) runSetUpstream() error {
+	if len(b.args) > 1 {
+		return errors.New("Too many arguments to set new upstream")
+	}
+	name, err := b.currentBranch()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("Could not set upstream of HEAD to %s when it does not point to any branch.", b.upstream)
+	}
+	if _, err := b.store.Read("refs/heads/" + name); err != nil {
+		return fmt.Errorf("Branch '%s' does not exist", name)
+	}
+
+	ref, ok := b.resolver.RefName(b.upstream)
+	if !ok || !b.store.Exists(ref) {
+		return fmt.Errorf("The requested upstream branch '%s' does not exist", b.upstream)
+	}
+	if strings.HasPrefix(ref, "refs/heads/") {
+		return SetUpstream(b.Repo, name, &Upstream{Remote: ".", Merge: ref, Ref: ref})
+	}
+	cfg, err := b.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if remote, merge, ok := refs.TrackedBranch(cfg, ref); ok && strings.HasPrefix(ref, "refs/remotes/") {
+		return SetUpstream(b.Repo, name, &Upstream{Remote: remote, Merge: merge, Ref: ref})
+	}
+	return fmt.Errorf("Cannot set up tracking information; starting point '%s' is not a branch", b.upstream)
+}
+
+func (b *Branch) runUnsetUpstream() error {
+	if len(b.args) > 1 {
+		return errors.New("Too many arguments to unset upstream")
+	}
+	name, err := b.currentBranch()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("Could not unset upstream of HEAD when it does not point to any branch.")
+	}
+	file, err := config.ReadFile(b.Repo.Path("config"))
+	if err != nil {
+		return err
+	}
+	removed, err := file.Unset("branch."+name+".merge", true)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("Branch '%s' has no upstream information", name)
+	}
+	if _, err := file.Unset("branch."+name+".remote", true); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	b.Repo.ReloadConfig()
+	return nil
+}
+
+// runDelete removes the branches named, which must be merged into their
+// upstream, or into HEAD when they have none, unless it is forced. With -r
+// the names are remote-tracking branches, which are removed unchecked
+func (b *Branch) runDelete() error {
+	if len(b.args) == 0 {
+		return errors.New("Branch name required")
+	}
+	head, err := b.store.HeadBranch()
+	if err != nil {
+		return err
+	}
+	prefix, kind := "refs/heads/", "branch"
+	if b.remotes {
+		prefix, kind = "refs/remotes/", "remote-tracking branch"
+	}
+
+	failed := false
+	for _, name := range b.args {
+		full := prefix + name
+		if full == head {
+			fmt.Fprintf(os.Stderr, "error: Cannot delete branch '%s' checked out at '%s'\n", name, b.checkedOutAt())
+			failed = true
+			continue
+		}
+		ref, err := b.store.Read(full)
+		if err != nil || refs.CheckName(full) != nil {
+			fmt.Fprintf(os.Stderr, "error: %s '%s' not found.\n", kind, name)
+			failed = true
+			continue
+		}
+		was := ""
+		if ref.IsSymbolic() {
+			if resolved, err := b.store.Resolve(full); resolved != nil {
+				was = resolved.Name
+			} else if err != nil {
+				return err
+			}
+		} else {
+			was = revision.Abbreviate(b.Repo.Objects, ref.Hash, 7)
+			if !b.force && !b.remotes {
+				merged, err := b.checkMerged(name, full, ref.Hash)
+				if err != nil {
+					return err
+				}
+				if !merged {
+					fmt.Fprintf(os.Stderr, "error: The branch '%s' is not fully merged.\n"+
+						"If you are sure you want to delete it, run 'git branch -D %s'.\n", name, name)
+					failed = true
+					continue
+				}
+			}
+		}
+
+		if err := b.store.Delete(full, refs.UpdateOptions{NoDeref: true}); err != nil {
+			return err
+		}
+		if !b.remotes {
+			if err := b.removeConfig(name); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Deleted %s %s (was %s).\n", kind, name, was)
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// checkMerged is whether the branch is merged into its upstream, or into
+// HEAD without one. Like git, it warns about a branch merged into its
+// upstream but not into HEAD
+func (b *Branch) checkMerged(name, full string, hash objectstore.Hash) (bool, error) {
+	cfg, err := b.Repo.Config()
+	if err != nil {
+		return false, err
+	}
+	headHash, headErr := b.store.Head()
+	reference, referenceErr := headHash, headErr
+	upstream := refs.Upstream(cfg, full)
+	if upstream != "" {
+		if upstreamHash, err := b.store.Hash(upstream); err == nil {
+			reference, referenceErr = upstreamHash, nil
+		}
+	}
+	if referenceErr != nil {
+		return false, nil
+	}
+	w := revwalk.NewWalker(b.Repo)
+	merged, err := w.IsAncestor(hash, reference)
+	if err != nil || !merged || reference == headHash || headErr != nil {
+		return merged, err
+	}
+	inHead, err := w.IsAncestor(hash, headHash)
+	if err != nil {
+		return false, err
+	}
+	if !inHead {
+		fmt.Fprintf(os.Stderr, "warning: deleting branch '%s' that has been merged to\n"+
+			"         '%s', but not yet merged to HEAD.\n", name, upstream)
+	}
+	return true, nil
+}
+
+// removeConfig drops the branch.<name> section of a deleted branch
+func (b *Branch) removeConfig(name string) error {
+	file, err := config.ReadFile(b.Repo.Path("config"))
+	if err != nil {
+		return err
+	}
+	if !file.RemoveSection("branch", name) {
+		return nil
+	}
+	b.Repo.ReloadConfig()
+	return file.Save()
+}
+
+// runRename moves a branch, the current one when a single name is given,
+// with its reflog and configuration, and keeps HEAD on it
+func (b *Branch) runRename() error {
+	var oldName, newName string
+	switch len(b.args) {
+	case 0:
+		return errors.New("Branch name required")
+	case 1:
+		head, err := b.store.HeadBranch()
+		if err != nil {
+			return err
+		}
+		if head == "" {
+			return errors.New("Cannot rename the current branch while not on any.")
+		}
+		oldName, newName = strings.TrimPrefix(head, "refs/heads/"), b.args[0]
+	case 2:
+		oldName, newName = b.args[0], b.args[1]
+	default:
+		return errors.New("Too many arguments for a rename operation")
+	}
+
+	head, err := b.store.HeadBranch()
+	if err != nil {
+		return err
+	}
+	oldRef := "refs/heads/" + oldName
+	_, readErr := b.store.Read(oldRef)
+	exists := readErr == nil
+	// the current branch may be yet to be born, then only HEAD changes
+	if !exists && oldRef != head {
+		return fmt.Errorf("No branch named '%s'.", oldName)
+	}
+	newRef, err := RefName(newName)
+	if err != nil {
+		return err
+	}
+	if newRef != oldRef && b.store.Exists(newRef) {
+		if !b.force {
+			return fmt.Errorf("A branch named '%s' already exists", newName)
+		}
+		if newRef == head {
+			return fmt.Errorf("Cannot force update the branch '%s' checked out at '%s'", newName, b.checkedOutAt())
+		}
+	}
+
+	if exists {
+		message := fmt.Sprintf("Branch: renamed %s to %s", oldRef, newRef)
+		if err := b.store.Rename(oldRef, newRef, message); err != nil {
+			return err
+		}
+	}
+	if oldRef == head {
+		if err := b.store.SetSymbolic("HEAD", newRef, ""); err != nil {
+			return err
+		}
+	}
+
+	file, err := config.ReadFile(b.Repo.Path("config"))
+	if err != nil {
+		return err
+	}
+	if oldName != newName && file.RenameSection("branch", oldName, newName) {
+		b.Repo.ReloadConfig()
+		return file.Save()
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package branch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	"github.com/codecrafters-io/git-starter-go/internal/revwalk"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+)
+
+// listItem is one line of the listing
+type listItem struct {
+	name    string // as shown, like main, origin/main or remotes/origin/main
+	short   string // what patterns are matched against
+	ref     string // empty for a detached HEAD
+	hash    objectstore.Hash
+	target  string // the short name of what a symbolic ref points to
+	current bool
+}
+
+// runList prints the local branches, the remote-tracking ones with -r or
+// both with -a, sorted by name after a detached HEAD. The current branch
+// is marked with a star
+func (b *Branch) runList() error {
+	items, err := b.listItems()
+	if err != nil {
+		return err
+	}
+	items, err = b.filter(items)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, item := range items {
+		if len(item.name) > width {
+			width = len(item.name)
+		}
+	}
+	cfg, err := b.Repo.Config()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		marker := "  "
+		if item.current {
+			marker = "* "
+		}
+		switch {
+		case item.target != "":
+			fmt.Printf("%s%s -> %s\n", marker, item.name, item.target)
+		case !b.verbose:
+			fmt.Printf("%s%s\n", marker, item.name)
+		default:
+			commit, err := object.GetCommit(b.Repo.Objects, item.hash)
+			if err != nil {
+				return err
+			}
+			tracking, err := b.trackingInfo(cfg, item)
+			if err != nil {
+				return err
+			}
+			abbrev := revision.Abbreviate(b.Repo.Objects, item.hash, 7)
+			fmt.Printf("%s%-*s %s %s%s\n", marker, width, item.name, abbrev, tracking, commit.Subject())
+		}
+	}
+	return nil
+}
+
+func (b *Branch) listItems() ([]*listItem, error) {
+	head, err := b.store.HeadBranch()
+	if err != nil {
+		return nil, err
+	}
+	var items []*listItem
+	if !b.remotes || b.all {
+		if headHash, err := b.store.Head(); head == "" && err == nil {
+			label := status.DetachedHead(b.Repo, headHash)
+			if label == "" {
+				label = "no branch"
+			}
+			items = append(items, &listItem{name: "(" + label + ")", short: "HEAD", hash: headHash, current: true})
+		}
+		locals, err := b.store.List("refs/heads/")
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range locals {
+			name := strings.TrimPrefix(ref.Name, "refs/heads/")
+			items = append(items, &listItem{name: name, short: name, ref: ref.Name, hash: ref.Hash, current: ref.Name == head})
+		}
+	}
+	if b.remotes || b.all {
+		remotes, err := b.store.List("refs/remotes/")
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range remotes {
+			item := &listItem{name: strings.TrimPrefix(ref.Name, "refs/remotes/"), ref: ref.Name, hash: ref.Hash}
+			item.short = item.name
+			if b.all {
+				item.name = "remotes/" + item.name
+			}
+			if ref.IsSymbolic() {
+				item.target = b.store.Shorten(ref.Target)
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// filter keeps the items matching the patterns given, and the commit
+// options
+func (b *Branch) filter(items []*listItem) ([]*listItem, error) {
+	type commitFilter struct {
+		option   string
+		contains bool // the branch must reach the commit, rather than the other way around
+		want     bool
+	}
+	var filters []commitFilter
+	for _, f := range []commitFilter{
+		{b.merged, false, true},
+		{b.noMerged, false, false},
+		{b.contains, true, true},
+		{b.noContains, true, false},
+	} {
+		if f.option != "" {
+			filters = append(filters, f)
+		}
+	}
+	hashes := make(map[string]objectstore.Hash)
+	for _, f := range filters {
+		hash, err := b.resolver.ResolveType(f.option, packextractor.OBJ_COMMIT)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed object name %s", f.option)
+		}
+		hashes[f.option] = hash
+	}
+
+	w := revwalk.NewWalker(b.Repo)
+	var kept []*listItem
+	for _, item := range items {
+		if len(b.args) > 0 && !matchAny(b.args, item.short) {
+			continue
+		}
+		keep := true
+		for _, f := range filters {
+			commit, tip := item.hash, hashes[f.option]
+			if f.contains {
+				commit, tip = tip, commit
+			}
+			reachable, err := w.IsAncestor(commit, tip)
+			if err != nil {
+				return nil, err
+			}
+			keep = keep && reachable == f.want
+		}
+		if keep {
+			kept = append(kept, item)
+		}
+	}
+	return kept, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ignore.Wildmatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackingInfo describes how a local branch relates to its upstream, like
+// "[ahead 1, behind 2] ". With -vv the upstream is named as well, and a
+// branch that is up to date or whose upstream is gone says so
+func (b *Branch) trackingInfo(cfg *config.Config, item *listItem) (string, error) {
+	if !strings.HasPrefix(item.ref, "refs/heads/") {
+		return "", nil
+	}
+	upstream := refs.Upstream(cfg, item.ref)
+	if upstream == "" {
+		return "", nil
+	}
+	name := b.store.Shorten(upstream)
+	upstreamHash, err := b.store.Hash(upstream)
+	if errors.Is(err, refs.ErrNotFound) {
+		if b.veryVerbose {
+			return "[" + name + ": gone] ", nil
+		}
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	ahead, behind, err := revwalk.AheadBehind(b.Repo, item.hash, upstreamHash)
+	if err != nil {
+		return "", err
+	}
+	var counts []string
+	if ahead > 0 {
+		counts = append(counts, fmt.Sprintf("ahead %d", ahead))
+	}
+	if behind > 0 {
+		counts = append(counts, fmt.Sprintf("behind %d", behind))
+	}
+	text := strings.Join(counts, ", ")
+	switch {
+	case b.veryVerbose && text != "":
+		return "[" + name + ": " + text + "] ", nil
+	case b.veryVerbose:
+		return "[" + name + "] ", nil
+	case text != "":
+		return "[" + text + "] ", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,88 @@
+package branch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+// Upstream is what a branch tracks: the branch Merge on Remote, known here
+// as Ref. Remote is "." for a local branch
+type Upstream struct {
+	Remote string
+	Merge  string
+	Ref    string
+}
+
+// RefName is the full name of the branch name, which must be a valid one
+func RefName(name string) (string, error) {
+	full := "refs/heads/" + name
+	if name == "HEAD" || strings.HasPrefix(name, "-") || refs.CheckName(full) != nil {
+		return "", fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return full, nil
+}
+
+// Tracking is the upstream a new branch gets from its start point, as
+// branch.autoSetupMerge says: remote-tracking branches unless it is false,
+// and local branches too when it is always. track is --track, which means
+// always, and noTrack --no-track, which means false. It is nil when there
+// is none
+func Tracking(repo *repository.Repository, start string, track, noTrack bool) (*Upstream, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	mode, ok := cfg.Get("branch.autosetupmerge")
+	if !ok {
+		mode = "true"
+	}
+	switch {
+	case noTrack:
+		mode = "false"
+	case track:
+		mode = "always"
+	}
+	if enabled, err := config.ParseBool(mode); err == nil && !enabled {
+		return nil, nil
+	}
+
+	ref, ok := revision.NewResolver(repo).RefName(start)
+	switch {
+	case ok && strings.HasPrefix(ref, "refs/remotes/"):
+		if remote, merge, ok := refs.TrackedBranch(cfg, ref); ok {
+			return &Upstream{Remote: remote, Merge: merge, Ref: ref}, nil
+		}
+	case ok && strings.HasPrefix(ref, "refs/heads/") && strings.EqualFold(mode, "always"):
+		return &Upstream{Remote: ".", Merge: ref, Ref: ref}, nil
+	}
+	if track {
+		return nil, fmt.Errorf("Cannot set up tracking information; starting point '%s' is not a branch", start)
+	}
+	return nil, nil
+}
+
+// SetUpstream records in the repository configuration that the branch name
+// tracks upstream
+func SetUpstream(repo *repository.Repository, name string, upstream *Upstream) error {
+	file, err := config.ReadFile(repo.Path("config"))
+	if err != nil {
+		return err
+	}
+	if err := file.Set("branch."+name+".remote", upstream.Remote); err != nil {
+		return err
+	}
+	if err := file.Set("branch."+name+".merge", upstream.Merge); err != nil {
+		return err
+	}
+	if err := file.Save(); err != nil {
+		return err
+	}
+	repo.ReloadConfig()
+	fmt.Printf("branch '%s' set up to track '%s'.\n", name, refs.NewStore(repo).Shorten(upstream.Ref))
+	return nil
+}
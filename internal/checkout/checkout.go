@@ -0,0 +1,432 @@
+package checkout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
+)
+
+// ConflictError lists the paths a checkout refused to touch, nothing was
+// changed then
+type ConflictError struct {
+	Changed   []string // local changes that would be overwritten
+	Untracked []string // untracked files that would be overwritten
+	Removed   []string // untracked files that would be removed
+	Dirs      []string // directories to be replaced that hold untracked files
+	// staged are the changed paths whose index entry differs, rather than
+	// their file
+	staged map[string]bool
+}
+
+func (e *ConflictError) empty() bool {
+	return len(e.Changed) == 0 && len(e.Untracked) == 0 && len(e.Removed) == 0 && len(e.Dirs) == 0
+}
+
+func (e *ConflictError) Error() string {
+	var parts []string
+	if len(e.Changed) > 0 {
+		parts = append(parts, "Your local changes to the following files would be overwritten by checkout:\n\t"+
+			strings.Join(e.Changed, "\n\t")+"\nPlease commit your changes or stash them before you switch branches.")
+	}
+	if len(e.Untracked) > 0 {
+		parts = append(parts, "The following untracked working tree files would be overwritten by checkout:\n\t"+
+			strings.Join(e.Untracked, "\n\t")+"\nPlease move or remove them before you switch branches.")
+	}
+	if len(e.Removed) > 0 {
+		parts = append(parts, "The following untracked working tree files would be removed by checkout:\n\t"+
+			strings.Join(e.Removed, "\n\t")+"\nPlease move or remove them before you switch branches.")
+	}
+	if len(e.Dirs) > 0 {
+		parts = append(parts, "Updating the following directories would lose untracked files in them:\n\t"+
+			strings.Join(e.Dirs, "\n\t")+"\n")
+	}
+	return strings.Join(parts, "\nerror: ") + "\nAborting"
+}
+
+// Lines has a message for each path in the way that git's plumbing gives,
+// as reset --keep reports them, sorted by path
+func (e *ConflictError) Lines() []string {
+	type line struct{ path, text string }
+	var lines []line
+	for _, name := range e.Changed {
+		if e.staged[name] {
+			lines = append(lines, line{name, fmt.Sprintf("Entry '%s' would be overwritten by merge. Cannot merge.", name)})
+		} else {
+			lines = append(lines, line{name, fmt.Sprintf("Entry '%s' not uptodate. Cannot merge.", name)})
+		}
+	}
+	for _, name := range e.Untracked {
+		lines = append(lines, line{name, fmt.Sprintf("Untracked working tree file '%s' would be overwritten by merge.", name)})
+	}
+	for _, name := range e.Removed {
+		lines = append(lines, line{name, fmt.Sprintf("Untracked working tree file '%s' would be removed by merge.", name)})
+	}
+	for _, name := range e.Dirs {
+		lines = append(lines, line{name, fmt.Sprintf("Updating '%s' would lose untracked files in it", name)})
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].path < lines[j].path })
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = l.text
+	}
+	return texts
+}
+
+func (e *ConflictError) stage(name string) {
+	if e.staged == nil {
+		e.staged = map[string]bool{}
+	}
+	e.staged[name] = true
+	e.Changed = append(e.Changed, name)
+}
+
+// change is what a checkout does to one path: remove it, or write entry
+type change struct {
+	path   string
+	entry  object.TreeEntry
+	remove bool
+}
+
+// checker compares the index and the work tree while a checkout is planned,
+// and applies the plan
+type checker struct {
+	repo     *repository.Repository
+	idx      *index.Index
+	matcher  *ignore.Matcher
+	fileMode bool
+	symlinks bool
+}
+
+func newChecker(repo *repository.Repository, idx *index.Index) (*checker, error) {
+	if err := repo.RequireWorkTree(); err != nil {
+		return nil, err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{repo: repo, idx: idx, matcher: ignore.NewMatcher(repo.WorkTree, repo.CommonDir, cfg)}
+	if c.fileMode, err = cfg.GetBool("core.filemode", true); err != nil {
+		return nil, err
+	}
+	if c.symlinks, err = cfg.GetBool("core.symlinks", true); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update moves the index and the work tree from the tree of from to the one
+// of to, commits or trees with zero for the empty tree. Like git, a path that
+// is the same in both keeps its local changes, and one that differs is only
+// updated when neither its index entry nor its file were changed. When local
+// changes or untracked files would be lost nothing is touched and the error
+// is a *ConflictError
+func Update(repo *repository.Repository, idx *index.Index, from, to objectstore.Hash) error {
+	c, err := newChecker(repo, idx)
+	if err != nil {
+		return err
+	}
+	oldFiles, err := status.TreeFiles(repo.Objects, from)
+	if err != nil {
+		return err
+	}
+	newFiles, err := worktree.TreeFiles(repo.Objects, to)
+	if err != nil {
+		return err
+	}
+
+	var changes []change
+	conflicts := &ConflictError{}
+	for _, name := range paths(idx, oldFiles, newFiles) {
+		old, inOld := oldFiles[name]
+		new, inNew := newFiles[name]
+		current := idx.Entry(name)
+		unmerged := current == nil && len(idx.Stages(name)) > 0
+		same := inOld == inNew && (!inOld || sameEntry(old, new))
+
+		switch {
+		case unmerged:
+			// a conflict is only resolved by a path that does not change
+			switch {
+			case !same:
+				conflicts.stage(name)
+			case inNew:
+				changes = append(changes, change{path: name, entry: new})
+			default:
+				changes = append(changes, change{path: name, remove: true})
+			}
+
+		case current != nil:
+			staged := object.TreeEntry{Mode: current.Mode, Hash: current.Hash}
+			switch {
+			case same, inNew && sameEntry(staged, new):
+				// kept as it is
+			case inOld && sameEntry(staged, old) && !current.IntentToAdd:
+				ok, err := c.upToDate(current)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					conflicts.Changed = append(conflicts.Changed, name)
+				} else if inNew {
+					changes = append(changes, change{path: name, entry: new})
+				} else {
+					changes = append(changes, change{path: name, remove: true})
+				}
+			default:
+				conflicts.stage(name)
+			}
+
+		case inOld && inNew:
+			// deleted from the index, which is fine as long as it stays the same
+			if !same {
+				conflicts.stage(name)
+			}
+
+		case inNew:
+			untracked, err := c.untracked(name)
+			if err != nil {
+				return err
+			}
+			if fi, err := os.Lstat(c.fullPath(name)); len(untracked) > 0 && err == nil && fi.IsDir() {
+				conflicts.Dirs = append(conflicts.Dirs, name)
+			} else if len(untracked) > 0 {
+				conflicts.Untracked = append(conflicts.Untracked, untracked...)
+			} else {
+				changes = append(changes, change{path: name, entry: new})
+			}
+
+		default:
+			// deleted from the index and going away, a file left is lost
+			untracked, err := c.untracked(name)
+			if err != nil {
+				return err
+			}
+			conflicts.Removed = append(conflicts.Removed, untracked...)
+		}
+	}
+	if !conflicts.empty() {
+		return conflicts
+	}
+	return c.apply(changes)
+}
+
+// Reset makes the index and the work tree match the tree of to, dropping
+// local changes, like checkout -f and reset --hard do. Files the index does
+// not know are left alone, unless to has something at their path
+func Reset(repo *repository.Repository, idx *index.Index, to objectstore.Hash) error {
+	c, err := newChecker(repo, idx)
+	if err != nil {
+		return err
+	}
+	newFiles, err := worktree.TreeFiles(repo.Objects, to)
+	if err != nil {
+		return err
+	}
+	var changes []change
+	for _, name := range paths(idx, nil, newFiles) {
+		new, ok := newFiles[name]
+		if !ok {
+			changes = append(changes, change{path: name, remove: true})
+			continue
+		}
+		if current := idx.Entry(name); current != nil && !current.IntentToAdd &&
+			current.Mode == new.Mode && current.Hash == new.Hash {
+			upToDate, err := c.upToDate(current)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Lstat(c.fullPath(name)); upToDate && err == nil {
+				continue
+			}
+		}
+		changes = append(changes, change{path: name, entry: new})
+	}
+	return c.apply(changes)
+}
+
+// sameEntry is whether two entries have the same content, whatever their
+// names
+func sameEntry(a, b object.TreeEntry) bool {
+	return a.Mode == b.Mode && a.Hash == b.Hash
+}
+
+// paths is every path in the index or one of the trees, sorted
+func paths(idx *index.Index, trees ...map[string]object.TreeEntry) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, e := range idx.Entries {
+		add(e.Path)
+	}
+	for _, files := range trees {
+		for name := range files {
+			add(name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isNotDir is whether err comes from a file where a directory of the path
+// was expected, the path does not exist then
+func isNotDir(err error) bool {
+	return errors.Is(err, syscall.ENOTDIR)
+}
+
+func (c *checker) fullPath(name string) string {
+	return filepath.Join(c.repo.WorkTree, filepath.FromSlash(name))
+}
+
+// upToDate is whether the file of an index entry still has the content
+// staged. A missing file counts as up to date, there is nothing to lose
+func (c *checker) upToDate(e *index.Entry) (bool, error) {
+	full := c.fullPath(e.Path)
+	fi, err := os.Lstat(full)
+	if os.IsNotExist(err) || isNotDir(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if e.Mode == object.ModeGitlink {
+		return fi.IsDir(), nil
+	}
+	if fi.IsDir() {
+		return false, nil
+	}
+	mode := index.ModeFromFileInfo(fi)
+	// without core.fileMode the executable bit of the index is kept
+	if !c.fileMode && mode != object.ModeSymlink && e.Mode != object.ModeSymlink {
+		mode = e.Mode
+	}
+	// and without core.symlinks a symlink is checked out as a plain file
+	if !c.symlinks && mode == object.ModeBlob && e.Mode == object.ModeSymlink {
+		mode = e.Mode
+	}
+	if mode != e.Mode {
+		return false, nil
+	}
+	if !e.Changed(fi) && !c.idx.IsRacy(e) {
+		return true, nil
+	}
+	hash, err := worktree.HashFile(c.repo.Objects, full, fi, false)
+	return hash == e.Hash, err
+}
+
+// untracked lists the files at name, or under it when it is a directory,
+// that the index does not know and are not ignored, which writing or
+// removing name would lose
+func (c *checker) untracked(name string) ([]string, error) {
+	full := c.fullPath(name)
+	fi, err := os.Lstat(full)
+	if os.IsNotExist(err) || isNotDir(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		if c.idx.Entry(name) != nil || c.matcher.Ignored(name, false) {
+			return nil, nil
+		}
+		return []string{name}, nil
+	}
+	if worktree.IsNestedRepository(full) {
+		return []string{name + "/"}, nil
+	}
+	var files []string
+	err = worktree.WalkDir(c.repo.WorkTree, name, c.matcher, func(rel string, fi os.FileInfo) error {
+		switch {
+		case len(c.idx.Stages(rel)) > 0:
+		case fi.IsDir():
+			files = append(files, rel+"/")
+		default:
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// apply removes paths first, so that a file can take the place of a
+// directory and the other way around, then writes the new files and stages
+// them with their stat data
+func (c *checker) apply(changes []change) error {
+	for _, ch := range changes {
+		if !ch.remove {
+			continue
+		}
+		c.idx.Remove(ch.path)
+		if err := c.removeFile(ch.path); err != nil {
+			return err
+		}
+	}
+	for _, ch := range changes {
+		if ch.remove {
+			continue
+		}
+		if err := c.writeFile(ch.path, ch.entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write checks out entry at name, and returns its lstat
+func (c *checker) write(name string, entry object.TreeEntry) (os.FileInfo, error) {
+	if !worktree.ValidPath(name) {
+		return nil, fmt.Errorf("invalid path '%s'", name)
+	}
+	return worktree.WriteEntry(c.repo.Objects, c.fullPath(name), entry.Mode, entry.Hash, c.symlinks)
+}
+
+// writeFile checks out entry at name and stages it
+func (c *checker) writeFile(name string, entry object.TreeEntry) error {
+	fi, err := c.write(name, entry)
+	if err != nil {
+		return err
+	}
+	e := index.NewEntry(name, entry.Hash, fi)
+	e.Mode = entry.Mode
+	c.idx.Add(e)
+	return nil
+}
+
+// removeFile deletes the file and then its parent directories that became
+// empty. A submodule is only removed when it is empty
+func (c *checker) removeFile(name string) error {
+	if !worktree.ValidPath(name) {
+		return fmt.Errorf("invalid path '%s'", name)
+	}
+	err := os.Remove(c.fullPath(name))
+	if err != nil && !os.IsNotExist(err) && !isNotDir(err) {
+		if fi, statErr := os.Lstat(c.fullPath(name)); statErr != nil || !fi.IsDir() {
+			return err
+		}
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(c.fullPath(dir)) != nil {
+			break // not empty
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package checkout
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+type Checkout struct {
+	Fs          *flag.FlagSet
+	Repo        *repository.Repository
+	newBranch   string // -b
+	resetBranch string // -B, creates the branch or resets it
+	detach      bool
+	force       bool
+	quiet       bool
+	track       bool
+	noTrack     bool
+	args        []string
+	paths       []string // after --
+	dashDash    bool
+	store       *refs.Store
+	resolver    *revision.Resolver
+}
+
+func (c *Checkout) Initialize(args []string) error {
+	c.Fs.StringVar(&c.newBranch, "b", "", "Create a branch at the start point and switch to it")
+	c.Fs.StringVar(&c.resetBranch, "B", "", "Like -b, but reset the branch when it exists")
+	c.Fs.BoolVar(&c.detach, "detach", false, "Detach HEAD at the commit, even for a branch")
+	c.Fs.BoolVar(&c.force, "f", false, "Throw away local changes, and untracked files in the way")
+	c.Fs.BoolVar(&c.force, "force", false, "Same as -f")
+	c.Fs.BoolVar(&c.quiet, "q", false, "Only report errors")
+	c.Fs.BoolVar(&c.quiet, "quiet", false, "Same as -q")
+	c.Fs.BoolVar(&c.track, "t", false, "Make the start point the upstream of the new branch")
+	c.Fs.BoolVar(&c.track, "track", false, "Same as -t")
+	c.Fs.BoolVar(&c.noTrack, "no-track", false, "Do not set up an upstream for the new branch")
+	args, c.paths, c.dashDash = revision.SplitDashDash(args)
+	if err := c.Fs.Parse(args); err != nil {
+		return err
+	}
+	c.args = c.Fs.Args()
+	if c.newBranch != "" && c.resetBranch != "" {
+		return errors.New("-b and -B are incompatible")
+	}
+	return nil
+}
+
+func (c *Checkout) Usage() string {
+	return "git checkout [-q] [-f] [--detach] [(-b | -B) <new-branch> [--track | --no-track]] [<branch> | <commit>] | " +
+		"git checkout [-q] [<tree-ish>] [--] <pathspec>... : Switch branches or restore working tree files"
+}
+
+func (c *Checkout) Run() error {
+	if err := c.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	c.store = refs.NewStore(c.Repo)
+	c.resolver = revision.NewResolver(c.Repo)
+
+	creating := c.newBranch != "" || c.resetBranch != ""
+	if len(c.paths) > 0 {
+		switch {
+		case len(c.args) > 1:
+			return fmt.Errorf("Only one reference expected, %d given.", len(c.args))
+		case creating || c.detach:
+			return c.pathsWithBranch()
+		case len(c.args) == 1:
+			return c.runPaths(c.args[0], c.paths)
+		}
+		return c.runPaths("", c.paths)
+	}
+
+	if creating {
+		if len(c.args) > 1 {
+			return c.pathsWithBranch()
+		}
+		return c.runCreate()
+	}
+	if len(c.args) == 0 {
+		if c.detach {
+			return c.runMove("HEAD")
+		}
+		m := &move{name: "HEAD", stay: true, force: c.force, quiet: c.quiet}
+		head, err := c.store.HeadBranch()
+		if err != nil {
+			return err
+		}
+		m.branch = head
+		if m.commit, err = c.store.Head(); err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+		return m.run(c.Repo)
+	}
+
+	// without --, the first argument is a revision when it names one and
+	// paths follow it, otherwise everything is a path
+	if _, err := c.resolver.Resolve(expandPrevious(c.args[0])); err != nil && !c.detach && !c.dashDash {
+		if _, ok := c.switcher().remoteBranch(c.args[0]); !ok || len(c.args) > 1 {
+			return c.runPaths("", c.args)
+		}
+	}
+	switch {
+	case len(c.args) > 1 && c.dashDash:
+		return fmt.Errorf("Only one reference expected, %d given.", len(c.args))
+	case len(c.args) > 1 && c.detach:
+		return c.pathsWithBranch()
+	case len(c.args) > 1:
+		return c.runPaths(c.args[0], c.args[1:])
+	}
+	return c.runMove(c.args[0])
+}
+
+func (c *Checkout) pathsWithBranch() error {
+	if c.detach {
+		return errors.New("'--detach' cannot be used with updating paths")
+	}
+	name := c.newBranch + c.resetBranch
+	return fmt.Errorf("Cannot update paths and switch to branch '%s' at the same time.", name)
+}
+
+// runPaths checks out the files matching args from the index, or from the
+// tree of source into the index and the work tree
+func (c *Checkout) runPaths(source string, args []string) error {
+	ps, err := pathspec.Parse(c.Repo.WorkTree, args)
+	if err != nil {
+		return err
+	}
+	opts := PathOptions{Worktree: true, Overlay: true}
+	from := "the index"
+	if source != "" {
+		hash, err := c.resolver.Resolve(expandPrevious(source))
+		if err != nil {
+			return fmt.Errorf("Invalid reference: %s", source)
+		}
+		_, tree, err := object.PeelToTree(c.Repo.Objects, hash)
+		if err != nil {
+			return fmt.Errorf("Reference is not a tree: %s", source)
+		}
+		opts.Source, opts.Staged = &tree, true
+		from = revision.Abbreviate(c.Repo.Objects, tree, 7)
+	}
+
+	idx, err := c.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	count, err := RestorePaths(c.Repo, idx, ps, opts)
+	if err != nil {
+		return err
+	}
+	if err := c.Repo.WriteIndex(idx); err != nil {
+		return err
+	}
+	// like git, the count is only given when paths were not marked with --
+	if !c.quiet && !c.dashDash {
+		noun := "paths"
+		if count == 1 {
+			noun = "path"
+		}
+		fmt.Fprintf(os.Stderr, "Updated %d %s from %s\n", count, noun, from)
+	}
+	return nil
+}
+
+// runMove switches to the branch or detaches HEAD at the commit arg names.
+// A name that only exists as a branch of one remote gets a local branch
+// tracking it
+func (c *Checkout) runMove(arg string) error {
+	sw := c.switcher()
+	name := previousName(c.resolver, arg)
+	m, err := sw.toBranch(name)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		return m.run(c.Repo)
+	}
+	if hash, err := c.resolver.ResolveType(expandPrevious(arg), packextractor.OBJ_COMMIT); err == nil {
+		m := sw.newMove(name)
+		if arg == "HEAD" && !c.detach {
+			// nothing changes but the files
+			if m.branch, err = c.store.HeadBranch(); err != nil {
+				return err
+			}
+			m.stay = true
+		}
+		m.commit = hash
+		return m.run(c.Repo)
+	} else if _, resolveErr := c.resolver.Resolve(expandPrevious(arg)); resolveErr == nil {
+		return fmt.Errorf("Reference is not a commit: %s", arg)
+	}
+	remote, ok := sw.remoteBranch(arg)
+	if !ok || c.detach {
+		return fmt.Errorf("pathspec '%s' did not match any file(s) known to git", arg)
+	}
+	return sw.create(arg, false, remote, true)
+}
+
+// runCreate handles -b and -B, from the start point given or HEAD
+func (c *Checkout) runCreate() error {
+	name, reset := c.newBranch, false
+	if c.resetBranch != "" {
+		name, reset = c.resetBranch, true
+	}
+	start := "HEAD"
+	if len(c.args) == 1 {
+		start = c.args[0]
+	}
+	return c.switcher().create(name, reset, start, len(c.args) == 1)
+}
+
+func (c *Checkout) switcher() *switcher {
+	return &switcher{repo: c.Repo, store: c.store, resolver: c.resolver,
+		force: c.force, quiet: c.quiet, detach: c.detach, track: c.track, noTrack: c.noTrack}
+}
+
+// expandPrevious makes "-" the previous branch, like @{-1}
+func expandPrevious(arg string) string {
+	if arg == "-" {
+		return "@{-1}"
+	}
+	return arg
+}
+
+// previousName is the branch name, or full commit, that "-" and @{-n}
+// stand for, and arg itself for anything else
+func previousName(resolver *revision.Resolver, arg string) string {
+	arg = expandPrevious(arg)
+	if !strings.HasPrefix(arg, "@{-") {
+		return arg
+	}
+	if ref, ok := resolver.RefName(arg); ok && strings.HasPrefix(ref, "refs/heads/") {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if hash, err := resolver.Resolve(arg); err == nil {
+		return hash.String()
+	}
+	return arg
+}
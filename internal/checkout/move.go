@@ -0,0 +1,383 @@
+package checkout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/branch"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	"github.com/codecrafters-io/git-starter-go/internal/revwalk"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+)
+
+// detachedAdvice is shown when HEAD leaves a branch for a commit, unless
+// advice.detachedHead is false
+const detachedAdvice = `Note: switching to '%s'.
+
+You are in 'detached HEAD' state. You can look around, make experimental
+changes and commit them, and you can discard any commits you make in this
+state without impacting any branches by switching back to a branch.
+
+If you want to create a new branch to retain commits you create, you may
+do so (now or later) by using -c with the switch command. Example:
+
+  git switch -c <new-branch-name>
+
+Or undo this operation with:
+
+  git switch -
+
+Turn off this advice by setting config variable advice.detachedHead to false
+
+`
+
+// move is a change of HEAD to a branch or to a detached commit, with the
+// branch it creates on the way
+type move struct {
+	name     string           // as asked for, for messages and the reflog
+	branch   string           // the full name of the branch to be on, empty to detach
+	commit   objectstore.Hash // zero to switch to a branch yet to be born
+	stay     bool             // HEAD is left as it is, like for a checkout without arguments
+	create   bool             // the branch is created, or reset when it exists
+	start    string           // what a created branch starts from, for its reflog
+	upstream *branch.Upstream // set up for a created branch
+	force    bool             // local changes are dropped
+	detach   bool             // asked for explicitly, which needs no advice
+	quiet    bool
+}
+
+// switcher makes the moves checkout and switch have in common
+type switcher struct {
+	repo     *repository.Repository
+	store    *refs.Store
+	resolver *revision.Resolver
+	force    bool
+	quiet    bool
+	detach   bool
+	track    bool
+	noTrack  bool
+}
+
+func (s *switcher) newMove(name string) *move {
+	return &move{name: name, force: s.force, quiet: s.quiet, detach: s.detach}
+}
+
+// toBranch is the move to the local branch name, nil when there is no such
+// branch or HEAD is to be detached
+func (s *switcher) toBranch(name string) (*move, error) {
+	full := "refs/heads/" + name
+	if s.detach || refs.CheckName(full) != nil || !s.store.Exists(full) {
+		return nil, nil
+	}
+	hash, err := s.store.Hash(full)
+	if err != nil {
+		return nil, err
+	}
+	m := s.newMove(name)
+	m.branch, m.commit = full, hash
+	return m, nil
+}
+
+// create makes the branch name at start, or resets it, and switches to it.
+// When no start was given, a HEAD yet to be born only gets the new name
+func (s *switcher) create(name string, reset bool, start string, given bool) error {
+	full, err := branch.RefName(name)
+	if err != nil {
+		return err
+	}
+	if s.store.Exists(full) && !reset {
+		return fmt.Errorf("A branch named '%s' already exists", name)
+	}
+	m := s.newMove(name)
+	m.branch, m.create, m.start = full, true, start
+	if !given {
+		if _, err := s.store.Head(); errors.Is(err, refs.ErrNotFound) {
+			return m.run(s.repo)
+		}
+	}
+	hash, err := s.resolver.ResolveType(expandPrevious(start), packextractor.OBJ_COMMIT)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a commit and a branch '%s' cannot be created from it", start, name)
+	}
+	m.commit = hash
+	if m.upstream, err = branch.Tracking(s.repo, start, s.track, s.noTrack); err != nil {
+		return err
+	}
+	return m.run(s.repo)
+}
+
+// remoteBranch is the full name of the remote-tracking branch name stands
+// for when exactly one remote has a branch of that name
+func (s *switcher) remoteBranch(name string) (string, bool) {
+	cfg, err := s.repo.Config()
+	if err != nil || refs.CheckName("refs/heads/"+name) != nil {
+		return "", false
+	}
+	var found []string
+	for _, remote := range cfg.Subsections("remote") {
+		if s.store.Exists("refs/remotes/" + remote + "/" + name) {
+			found = append(found, "refs/remotes/"+remote+"/"+name)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// run updates the index and the work tree, then the refs, and tells what
+// happened the way git does: local changes and the relation to the upstream
+// on stdout, the rest on stderr
+func (m *move) run(repo *repository.Repository) error {
+	store := refs.NewStore(repo)
+	oldBranch, err := store.HeadBranch()
+	if err != nil {
+		return err
+	}
+	oldHash, err := store.Head()
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+
+	if !m.commit.IsZero() {
+		idx, err := repo.ReadIndex()
+		if err != nil {
+			return err
+		}
+		unmerged := idx.Unmerged()
+		if len(unmerged) > 0 && !m.force {
+			for _, name := range unmerged {
+				fmt.Printf("%s: needs merge\n", name)
+			}
+			return errors.New("You need to resolve your current index first")
+		}
+		if m.force {
+			err = Reset(repo, idx, m.commit)
+		} else {
+			err = Update(repo, idx, oldHash, m.commit)
+		}
+		if err != nil {
+			return err
+		}
+		if err := repo.WriteIndex(idx); err != nil {
+			return err
+		}
+	}
+
+	if !m.quiet && oldBranch == "" && !oldHash.IsZero() && oldHash != m.commit {
+		if err := orphanWarning(repo, store, oldHash, m.commit); err != nil {
+			return err
+		}
+	}
+	existed := store.Exists(m.branch)
+	if err := m.updateRefs(repo, store, oldBranch, oldHash); err != nil {
+		return err
+	}
+	if m.quiet {
+		return nil
+	}
+	return m.report(repo, oldBranch, existed)
+}
+
+// updateRefs creates or resets the branch when asked to, and points HEAD at
+// the branch or the commit
+func (m *move) updateRefs(repo *repository.Repository, store *refs.Store, oldBranch string, oldHash objectstore.Hash) error {
+	short := strings.TrimPrefix(m.branch, "refs/heads/")
+	if m.create && !m.commit.IsZero() {
+		message := "branch: Created from " + m.start
+		if store.Exists(m.branch) {
+			message = "branch: Reset to " + m.start
+		}
+		if err := store.Update(m.branch, m.commit, refs.UpdateOptions{Message: message}); err != nil {
+			return err
+		}
+		if m.upstream != nil {
+			if err := branch.SetUpstream(repo, short, m.upstream); err != nil {
+				return err
+			}
+		}
+	}
+	if m.stay {
+		return nil
+	}
+
+	from := strings.TrimPrefix(oldBranch, "refs/heads/")
+	if oldBranch == "" {
+		from = oldHash.String()
+	}
+	message := fmt.Sprintf("checkout: moving from %s to %s", from, m.name)
+	if m.branch != "" {
+		return store.SetSymbolic("HEAD", m.branch, message)
+	}
+	return store.Update("HEAD", m.commit, refs.UpdateOptions{NoDeref: true, Message: message})
+}
+
+// report prints the local changes kept, where HEAD is now and how the
+// branch compares with its upstream
+func (m *move) report(repo *repository.Repository, oldBranch string, existed bool) error {
+	st, err := status.Compute(repo, status.Options{Untracked: status.UntrackedNo})
+	if err != nil {
+		return err
+	}
+	if !m.force {
+		for _, e := range st.Entries {
+			if letter := localChange(e); letter != 0 {
+				fmt.Printf("%c\t%s\n", letter, e.Path)
+			}
+		}
+	}
+
+	short := strings.TrimPrefix(m.branch, "refs/heads/")
+	switch {
+	case m.stay:
+	case m.branch != "" && oldBranch == m.branch && m.create:
+		fmt.Fprintf(os.Stderr, "Reset branch '%s'\n", short)
+	case m.branch != "" && oldBranch == m.branch:
+		fmt.Fprintf(os.Stderr, "Already on '%s'\n", short)
+	case m.branch != "" && m.create && existed:
+		fmt.Fprintf(os.Stderr, "Switched to and reset branch '%s'\n", short)
+	case m.branch != "" && m.create:
+		fmt.Fprintf(os.Stderr, "Switched to a new branch '%s'\n", short)
+	case m.branch != "":
+		fmt.Fprintf(os.Stderr, "Switched to branch '%s'\n", short)
+	default:
+		cfg, err := repo.Config()
+		if err != nil {
+			return err
+		}
+		advice, err := cfg.GetBool("advice.detachedhead", true)
+		if err != nil {
+			return err
+		}
+		if oldBranch != "" && !m.detach && advice {
+			fmt.Fprintf(os.Stderr, detachedAdvice, m.name)
+		}
+		description, err := describe(repo, m.commit)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "HEAD is now at %s\n", description)
+	}
+
+	// like git, a branch just created is not compared with its upstream
+	if st.Branch != "" && (m.stay || m.branch != "" && (!m.create || existed)) {
+		fmt.Print(status.Tracking(st))
+	}
+	return nil
+}
+
+// localChange is the letter of a path whose changes are kept, comparing
+// HEAD with the work tree, or 0 when there is none
+func localChange(e *status.Entry) byte {
+	switch {
+	case e.Unmerged():
+		return 'U'
+	case e.Unstaged == 'D' && e.Staged == 'A':
+		return 0
+	case e.Unstaged == 'D':
+		return 'D'
+	case e.Staged != ' ':
+		return e.Staged
+	case e.Unstaged == ' ':
+		return 0
+	}
+	return e.Unstaged
+}
+
+// describe is the abbreviated commit and its subject
+func describe(repo *repository.Repository, hash objectstore.Hash) (string, error) {
+	commit, err := object.GetCommit(repo.Objects, hash)
+	if err != nil {
+		return "", err
+	}
+	return revision.Abbreviate(repo.Objects, hash, 7) + " " + commit.Subject(), nil
+}
+
+// orphanCutoff is how many of the commits left behind are listed
+const orphanCutoff = 4
+
+// orphanWarning tells about the commits of a detached HEAD that no ref
+// reaches once HEAD moves to next, or where HEAD was when there are none
+func orphanWarning(repo *repository.Repository, store *refs.Store, old, next objectstore.Hash) error {
+	lost, err := unreachable(repo, store, old, next)
+	if err != nil {
+		return err
+	}
+	if len(lost) == 0 {
+		description, err := describe(repo, old)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Previous HEAD position was %s\n", description)
+		return nil
+	}
+
+	var b strings.Builder
+	if len(lost) == 1 {
+		b.WriteString("Warning: you are leaving 1 commit behind, not connected to\nany of your branches:\n\n")
+	} else {
+		fmt.Fprintf(&b, "Warning: you are leaving %d commits behind, not connected to\nany of your branches:\n\n", len(lost))
+	}
+	for i, hash := range lost {
+		if i == orphanCutoff && len(lost) > orphanCutoff+1 {
+			fmt.Fprintf(&b, " ... and %d more.\n", len(lost)-orphanCutoff)
+			break
+		}
+		description, err := describe(repo, hash)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "  %s\n", description)
+	}
+	them := "them"
+	if len(lost) == 1 {
+		them = "it"
+	}
+	fmt.Fprintf(&b, "\nIf you want to keep %s by creating a new branch, this may be a good time\n"+
+		"to do so with:\n\n git branch <new-branch-name> %s\n\n", them, revision.Abbreviate(repo.Objects, old, 7))
+	fmt.Fprint(os.Stderr, b.String())
+	return nil
+}
+
+// unreachable lists the commits reachable from old but neither from a ref
+// nor from next, newest first
+func unreachable(repo *repository.Repository, store *refs.Store, old, next objectstore.Hash) ([]objectstore.Hash, error) {
+	all, err := store.List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	w := revwalk.NewWalker(repo)
+	if err := w.Push(old); err != nil {
+		return nil, err
+	}
+	if !next.IsZero() {
+		if err := w.Hide(next); err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range all {
+		if obj, hash, err := object.Peel(repo.Objects, ref.Hash); err == nil && obj.Type == packextractor.OBJ_COMMIT {
+			if err := w.Hide(hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var lost []objectstore.Hash
+	for {
+		c, err := w.Next()
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return lost, nil
+		}
+		lost = append(lost, c.Hash)
+	}
+}
@@ -0,0 +1,128 @@
+package checkout
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
+)
+
+// PathOptions say where RestorePaths takes the paths from and what it
+// updates
+type PathOptions struct {
+	// Source is the tree-ish the paths come from, nil for the index
+	Source *objectstore.Hash
+	// Staged updates the index, only with a Source
+	Staged bool
+	// Worktree updates the files of the work tree
+	Worktree bool
+	// Overlay keeps the paths the source does not have, rather than removing
+	// them
+	Overlay bool
+}
+
+// RestorePaths brings the paths matching ps back to what the source has,
+// and returns how many files of the work tree were written. Files already
+// matching the index are left alone when restoring from it. Every pathspec
+// must match something in the source or the index, and unmerged paths can
+// only be restored from a tree
+func RestorePaths(repo *repository.Repository, idx *index.Index, ps *pathspec.Pathspec, opts PathOptions) (int, error) {
+	c, err := newChecker(repo, idx)
+	if err != nil {
+		return 0, err
+	}
+	var sourceFiles map[string]object.TreeEntry
+	if opts.Source != nil {
+		if sourceFiles, err = worktree.TreeFiles(repo.Objects, *opts.Source); err != nil {
+			return 0, err
+		}
+	}
+
+	var matched []string
+	for _, name := range paths(idx, sourceFiles) {
+		if ps.Match(name) {
+			matched = append(matched, name)
+		}
+	}
+	if unmatched := ps.Unmatched(); len(unmatched) > 0 {
+		return 0, fmt.Errorf("pathspec '%s' did not match any file(s) known to git", unmatched[0])
+	}
+
+	if opts.Source == nil {
+		return c.restoreFromIndex(matched)
+	}
+	count := 0
+	for _, name := range matched {
+		entry, ok := sourceFiles[name]
+		switch {
+		case !ok && opts.Overlay:
+		case !ok:
+			if opts.Staged {
+				idx.Remove(name)
+			}
+			if opts.Worktree {
+				if err := c.removeFile(name); err != nil {
+					return count, err
+				}
+			}
+		case opts.Worktree && opts.Staged:
+			if err := c.writeFile(name, entry); err != nil {
+				return count, err
+			}
+			count++
+		case opts.Worktree:
+			if _, err := c.write(name, entry); err != nil {
+				return count, err
+			}
+			count++
+		case opts.Staged:
+			// the file is not written, so there is no stat data to record
+			idx.Add(&index.Entry{Path: name, Mode: entry.Mode, Hash: entry.Hash})
+		}
+	}
+	return count, nil
+}
+
+// restoreFromIndex writes the files of the index entries named that no
+// longer match them
+func (c *checker) restoreFromIndex(names []string) (int, error) {
+	var unmerged []string
+	for _, name := range names {
+		if c.idx.Entry(name) == nil {
+			unmerged = append(unmerged, name)
+		}
+	}
+	if len(unmerged) > 0 {
+		sort.Strings(unmerged)
+		return 0, fmt.Errorf("path '%s' is unmerged", strings.Join(unmerged, "' is unmerged\nerror: path '"))
+	}
+
+	count := 0
+	for _, name := range names {
+		e := c.idx.Entry(name)
+		if e.IntentToAdd {
+			continue
+		}
+		upToDate, err := c.upToDate(e)
+		if err != nil {
+			return count, err
+		}
+		if _, err := os.Lstat(c.fullPath(name)); upToDate && err == nil {
+			continue
+		}
+		fi, err := c.write(name, object.TreeEntry{Mode: e.Mode, Hash: e.Hash})
+		if err != nil {
+			return count, err
+		}
+		e.SetStat(fi)
+		count++
+	}
+	return count, nil
+}
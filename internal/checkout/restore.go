@@ -0,0 +1,94 @@
+package checkout
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+type Restore struct {
+	Fs       *flag.FlagSet
+	Repo     *repository.Repository
+	source   string
+	staged   bool
+	worktree bool
+	overlay  bool
+	quiet    bool
+	paths    []string
+}
+
+func (r *Restore) Initialize(args []string) error {
+	r.Fs.StringVar(&r.source, "s", "", "Restore from this tree-ish, by default the index or HEAD with --staged")
+	r.Fs.StringVar(&r.source, "source", "", "Same as -s")
+	r.Fs.BoolVar(&r.staged, "S", false, "Restore the index")
+	r.Fs.BoolVar(&r.staged, "staged", false, "Same as -S")
+	r.Fs.BoolVar(&r.worktree, "W", false, "Restore the working tree, the default without --staged")
+	r.Fs.BoolVar(&r.worktree, "worktree", false, "Same as -W")
+	r.Fs.BoolVar(&r.overlay, "overlay", false, "Keep the files the source does not have")
+	r.Fs.BoolVar(&r.quiet, "q", false, "Only report errors")
+	r.Fs.BoolVar(&r.quiet, "quiet", false, "Same as -q")
+	if err := r.Fs.Parse(args); err != nil {
+		return err
+	}
+	r.paths = r.Fs.Args()
+	if len(r.paths) == 0 {
+		return errors.New("You must specify path(s) to restore")
+	}
+	if !r.staged {
+		r.worktree = true
+	}
+	return nil
+}
+
+func (r *Restore) Usage() string {
+	return "git restore [-s <tree-ish>] [-S] [-W] [--overlay] [-q] [--] <pathspec>... : Restore working tree files"
+}
+
+func (r *Restore) Run() error {
+	if err := r.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	opts := PathOptions{Staged: r.staged, Worktree: r.worktree, Overlay: r.overlay}
+	switch {
+	case r.source != "":
+		hash, err := revision.NewResolver(r.Repo).Resolve(r.source)
+		if err != nil {
+			return fmt.Errorf("Could not resolve %s", r.source)
+		}
+		_, tree, err := object.PeelToTree(r.Repo.Objects, hash)
+		if err != nil {
+			return fmt.Errorf("Reference is not a tree: %s", r.source)
+		}
+		opts.Source = &tree
+	case r.staged:
+		// HEAD, or nothing before the first commit
+		head, err := refs.NewStore(r.Repo).Head()
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return err
+		}
+		opts.Source = &head
+	}
+	if opts.Source == nil {
+		// the index is the source, there is nothing to remove
+		opts.Overlay = true
+	}
+
+	ps, err := pathspec.Parse(r.Repo.WorkTree, r.paths)
+	if err != nil {
+		return err
+	}
+	idx, err := r.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if _, err := RestorePaths(r.Repo, idx, ps, opts); err != nil {
+		return err
+	}
+	return r.Repo.WriteIndex(idx)
+}
@@ -0,0 +1,118 @@
+package checkout
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+type Switch struct {
+	Fs          *flag.FlagSet
+	Repo        *repository.Repository
+	create      string // -c
+	forceCreate string // -C, creates the branch or resets it
+	detach      bool
+	force       bool
+	quiet       bool
+	track       bool
+	noTrack     bool
+	args        []string
+}
+
+func (s *Switch) Initialize(args []string) error {
+	s.Fs.StringVar(&s.create, "c", "", "Create a branch at the start point and switch to it")
+	s.Fs.StringVar(&s.create, "create", "", "Same as -c")
+	s.Fs.StringVar(&s.forceCreate, "C", "", "Like -c, but reset the branch when it exists")
+	s.Fs.StringVar(&s.forceCreate, "force-create", "", "Same as -C")
+	s.Fs.BoolVar(&s.detach, "d", false, "Detach HEAD at the commit")
+	s.Fs.BoolVar(&s.detach, "detach", false, "Same as -d")
+	s.Fs.BoolVar(&s.force, "f", false, "Throw away local changes, and untracked files in the way")
+	s.Fs.BoolVar(&s.force, "force", false, "Same as -f")
+	s.Fs.BoolVar(&s.force, "discard-changes", false, "Same as -f")
+	s.Fs.BoolVar(&s.quiet, "q", false, "Only report errors")
+	s.Fs.BoolVar(&s.quiet, "quiet", false, "Same as -q")
+	s.Fs.BoolVar(&s.track, "t", false, "Make the start point the upstream of the new branch")
+	s.Fs.BoolVar(&s.track, "track", false, "Same as -t")
+	s.Fs.BoolVar(&s.noTrack, "no-track", false, "Do not set up an upstream for the new branch")
+	if err := s.Fs.Parse(args); err != nil {
+		return err
+	}
+	s.args = s.Fs.Args()
+	if s.create != "" && s.forceCreate != "" {
+		return errors.New("-c and -C are incompatible")
+	}
+	if len(s.args) > 1 {
+		return errors.New("Only one reference expected")
+	}
+	return nil
+}
+
+func (s *Switch) Usage() string {
+	return "git switch [-q] [-f] [--track | --no-track] ((-c | -C) <new-branch> [<start-point>] | -d [<commit>] | <branch>) : Switch branches"
+}
+
+func (s *Switch) Run() error {
+	if err := s.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	resolver := revision.NewResolver(s.Repo)
+	sw := &switcher{repo: s.Repo, store: refs.NewStore(s.Repo), resolver: resolver,
+		force: s.force, quiet: s.quiet, detach: s.detach, track: s.track, noTrack: s.noTrack}
+
+	if s.create != "" || s.forceCreate != "" {
+		name, reset := s.create, false
+		if s.forceCreate != "" {
+			name, reset = s.forceCreate, true
+		}
+		start := "HEAD"
+		if len(s.args) == 1 {
+			start = s.args[0]
+		}
+		return sw.create(name, reset, start, len(s.args) == 1)
+	}
+
+	arg := "HEAD"
+	if len(s.args) == 1 {
+		arg = s.args[0]
+	} else if !s.detach {
+		return errors.New("Missing branch or commit argument")
+	}
+	name := previousName(resolver, arg)
+	m, err := sw.toBranch(name)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		return m.run(s.Repo)
+	}
+
+	hash, err := resolver.ResolveType(expandPrevious(arg), packextractor.OBJ_COMMIT)
+	if err != nil {
+		if remote, ok := sw.remoteBranch(arg); ok && !s.detach {
+			return sw.create(arg, false, remote, true)
+		}
+		return fmt.Errorf("Invalid reference: %s", arg)
+	}
+	if !s.detach {
+		kind := "commit"
+		if ref, ok := resolver.RefName(expandPrevious(arg)); ok {
+			switch {
+			case strings.HasPrefix(ref, "refs/tags/"):
+				kind = "tag"
+			case strings.HasPrefix(ref, "refs/remotes/"):
+				kind = "remote branch"
+			}
+		}
+		return fmt.Errorf("A branch is expected, got %s '%s'\n"+
+			"hint: If you want to detach HEAD at the commit, try again with the --detach option.", kind, arg)
+	}
+	m = sw.newMove(name)
+	m.commit = hash
+	return m.run(s.Repo)
+}
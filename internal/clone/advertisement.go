@@ -0,0 +1,118 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// remoteRef is a single line of the reference advertisement
+type remoteRef struct {
+	hash string
+	name string
+}
+
+// advertisement is what the server tells us before we ask for a pack: the
+// refs it has and the capabilities it supports. The capabilities ride along
+// on the first ref line, after a NUL byte
+//
+//	<hash> HEAD\0multi_ack ofs-delta symref=HEAD:refs/heads/main agent=git/2.43
+type advertisement struct {
+	refs         []remoteRef
+	capabilities []string
+}
+
+// cloneTarget is what ends up checked out, branch is empty for a detached HEAD
+type cloneTarget struct {
+	branch string
+	hash   string
+}
+
+func parseAdvertisement(lines []string) (*advertisement, error) {
+	adv := &advertisement{}
+	first, caps, found := strings.Cut(lines[0], "\x00")
+	if found {
+		adv.capabilities = strings.Fields(caps)
+	}
+	lines[0] = first
+	for _, line := range lines {
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || len(hash) != 40 {
+			return nil, fmt.Errorf("Malformed ref advertisement %q", line)
+		}
+		// an empty repository advertises only its capabilities
+		if name == "capabilities^{}" {
+			continue
+		}
+		adv.refs = append(adv.refs, remoteRef{hash: hash, name: name})
+	}
+	if len(adv.refs) == 0 {
+		return nil, errors.New("You appear to have cloned an empty repository")
+	}
+	return adv, nil
+}
+
+// capability returns the values of every "name=value" capability, for
+// capabilities without a value it returns a single empty string
+func (adv *advertisement) capability(name string) []string {
+	var values []string
+	for _, c := range adv.capabilities {
+		if c == name {
+			values = append(values, "")
+		} else if value, ok := strings.CutPrefix(c, name+"="); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// symref returns the target of a symbolic ref advertised with symref=<ref>:<target>
+func (adv *advertisement) symref(ref string) (string, bool) {
+	for _, value := range adv.capability("symref") {
+		if source, target, ok := strings.Cut(value, ":"); ok && source == ref {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+func (adv *advertisement) find(name string) (remoteRef, bool) {
+	for _, ref := range adv.refs {
+		if ref.name == name {
+			return ref, true
+		}
+	}
+	return remoteRef{}, false
+}
+
+// headBranch returns the short name of the branch the remote HEAD points to.
+// Old servers do not send symref, then we guess like git does: a branch
+// pointing at the same commit as HEAD, preferring master
+func (adv *advertisement) headBranch() (string, error) {
+	if target, ok := adv.symref("HEAD"); ok {
+		if _, found := adv.find(target); found && strings.HasPrefix(target, "refs/heads/") {
+			return strings.TrimPrefix(target, "refs/heads/"), nil
+		}
+	}
+	head, ok := adv.find("HEAD")
+	if !ok {
+		return "", errors.New("Remote HEAD refers to nonexistent ref, unable to checkout")
+	}
+	var candidates []string
+	for _, ref := range adv.refs {
+		if ref.hash == head.hash && strings.HasPrefix(ref.name, "refs/heads/") {
+			candidates = append(candidates, strings.TrimPrefix(ref.name, "refs/heads/"))
+		}
+	}
+	if len(candidates) == 0 {
+		return "", errors.New("Remote HEAD is detached, use --branch to pick a branch")
+	}
+	sort.Strings(candidates)
+	for _, c := range candidates {
+		if c == "master" {
+			return c, nil
+		}
+	}
+	return candidates[0], nil
+}
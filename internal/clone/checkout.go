@@ -0,0 +1,132 @@
+package clone
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/checkout"
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// clonedRepo is the local repository being populated from a fetched pack
+type clonedRepo struct {
+	workTree string
+	gitDir   string
+	store    *objectstore.DiskStore
+}
+
+func (r *clonedRepo) init() error {
+	for _, dir := range []string{"objects/info", "objects/pack", "refs/heads", "refs/tags", "refs/remotes/origin"} {
+		if err := os.MkdirAll(filepath.Join(r.gitDir, dir), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storePack keeps the fetched pack as is, next to its index
+func (r *clonedRepo) storePack(packfile []byte) error {
+	r.store = objectstore.Open(filepath.Join(r.gitDir, "objects"))
+	_, err := r.store.Packs.AddPack(packfile)
+	return err
+}
+
+func (r *clonedRepo) writeRef(name, value string) error {
+	refPath := filepath.Join(r.gitDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(refPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(refPath, []byte(value+"\n"), 0o644)
+}
+
+// writeRefs maps the remote branches to refs/remotes/origin/*, copies the
+// tags and creates the local branch together with HEAD. Like git, only the
+// branch, HEAD and origin/HEAD get a reflog, which starts with the clone
+func (r *clonedRepo) writeRefs(adv *advertisement, target cloneTarget, URL string) error {
+	for _, ref := range adv.refs {
+		switch {
+		case strings.HasSuffix(ref.name, "^{}"):
+			continue
+		case strings.HasPrefix(ref.name, "refs/heads/"):
+			name := "refs/remotes/origin/" + strings.TrimPrefix(ref.name, "refs/heads/")
+			if err := r.writeRef(name, ref.hash); err != nil {
+				return err
+			}
+		case strings.HasPrefix(ref.name, "refs/tags/"):
+			if err := r.writeRef(ref.name, ref.hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	repo := repository.New(r.gitDir, r.workTree, "")
+	repo.Objects = r.store
+	store := refs.NewStore(repo)
+	message := "clone: from " + URL
+	if remoteHead, err := adv.headBranch(); err == nil {
+		if err := store.SetSymbolic("refs/remotes/origin/HEAD", "refs/remotes/origin/"+remoteHead, message); err != nil {
+			return err
+		}
+	}
+	hash, err := objectstore.ParseHash(target.hash)
+	if err != nil {
+		return err
+	}
+	if target.branch == "" {
+		_, head, err := object.Peel(r.store, hash)
+		if err != nil {
+			return err
+		}
+		return store.Update("HEAD", head, refs.UpdateOptions{NoDeref: true, Message: message})
+	}
+	if err := store.SetSymbolic("HEAD", "refs/heads/"+target.branch, ""); err != nil {
+		return err
+	}
+	return store.Update("refs/heads/"+target.branch, hash, refs.UpdateOptions{Message: message})
+}
+
+// writeConfig records origin, and the upstream of branch unless it is empty
+func (r *clonedRepo) writeConfig(URL, branch string) error {
+	f := &config.File{Path: filepath.Join(r.gitDir, "config")}
+	settings := [][2]string{
+		{"core.repositoryformatversion", "0"},
+		{"core.filemode", "true"},
+		{"core.bare", "false"},
+		{"core.logallrefupdates", "true"},
+		{"remote.origin.url", URL},
+		{"remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"},
+	}
+	if branch != "" {
+		settings = append(settings,
+			[2]string{"branch." + branch + ".remote", "origin"},
+			[2]string{"branch." + branch + ".merge", "refs/heads/" + branch})
+	}
+	for _, setting := range settings {
+		if err := f.Set(setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+	return f.Save()
+}
+
+// checkout writes the tree of the given commit (or tag of a commit) into the
+// work tree and stages it so that the clone starts out clean
+func (r *clonedRepo) checkout(hexHash string) error {
+	hash, err := objectstore.ParseHash(hexHash)
+	if err != nil {
+		return err
+	}
+	repo := repository.New(r.gitDir, r.workTree, "")
+	repo.Objects = r.store
+	idx := index.New()
+	if err := checkout.Reset(repo, idx, hash); err != nil {
+		return err
+	}
+	return repo.WriteIndex(idx)
+}
@@ -2,7 +2,6 @@ package clone
 
 import (
 	"bytes"
-	"compress/zlib"
 	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
@@ -11,29 +10,52 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
 type Clone struct {
-	Fs  *flag.FlagSet
-	URL string
+	Fs        *flag.FlagSet
+	URL       string
+	Directory string
+	Branch    string // branch or tag to check out instead of the remote HEAD
 }
 
 func (t *Clone) Initialize(args []string) error {
-	if len(args) < 1 {
+	t.Fs.StringVar(&t.Branch, "branch", "", "Check out <branch> (or tag) instead of the remote's HEAD")
+	t.Fs.StringVar(&t.Branch, "b", "", "Shorthand for --branch")
+	err := t.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	rest := t.Fs.Args()
+	if len(rest) < 1 || len(rest) > 2 {
 		return fmt.Errorf("Not enough arguments")
 	}
-	t.URL = args[len(args)-1]
+	t.URL = strings.TrimSuffix(rest[0], "/")
+	if len(rest) == 2 {
+		t.Directory = rest[1]
+	} else {
+		t.Directory = defaultDirectory(t.URL)
+	}
 	return nil
 }
 
 func (t *Clone) Usage() string {
-	return "Clone a repository: git clone <url>"
+	return "Clone a repository: git clone [-b <branch>] <url> [<directory>]"
+}
+
+// defaultDirectory is the "humanish" part of the url, like git does
+// https://github.com/user/repo.git -> repo
+func defaultDirectory(URL string) string {
+	return strings.TrimSuffix(path.Base(URL), ".git")
 }
 
 // Sends GET request to the reference url and extracts references
 // URL: root url to the repo
-func getReferences(URL string) ([]string, error) {
+func getReferences(URL string) (*advertisement, error) {
 	referencePostfix := "/info/refs?service=git-upload-pack" // defined by the git http smart protocol
 	referenceURL := URL + referencePostfix
 
@@ -51,27 +73,32 @@ func getReferences(URL string) ([]string, error) {
 		return nil, err
 	}
 
-	refs, err := extractRefs(data)
+	adv, err := extractRefs(data)
 	if err != nil {
 		return nil, err
 	}
 
-	return refs, nil
+	return adv, nil
 }
 
-// masterHash: Hash of the commit object that points to Master. This is where data transfer starts
+// wants: hashes of the objects we ask for, the server sends everything reachable from them
 // sends post request to the endpoint /git-upload-pack to get actual packfile
-func getPackFile(URL, masterHash string) ([]byte, error) {
+func getPackFile(URL string, wants []string) ([]byte, error) {
 	packPostfix := "/git-upload-pack"
 	objURL := URL + packPostfix
-	initiateProtoMsg := fmt.Sprintf("0032want %v\n00000009done\n", masterHash)
-	reqBody := bytes.NewBufferString(initiateProtoMsg)
+	var reqBody bytes.Buffer
+	for _, want := range wants {
+		line := fmt.Sprintf("want %v\n", want)
+		fmt.Fprintf(&reqBody, "%04x%s", len(line)+4, line)
+	}
+	reqBody.WriteString("00000009done\n")
 	contentType := "application/x-git-upload-pack-request"
 
-	resp, err := http.Post(objURL, contentType, reqBody)
+	resp, err := http.Post(objURL, contentType, &reqBody)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("Error on fetching pack files %v", resp.Status)
 	}
@@ -82,57 +109,56 @@ func getPackFile(URL, masterHash string) ([]byte, error) {
 	}
 
 	// first 8 bytes are ack things(https://www.git-scm.com/docs/gitprotocol-pack#:~:text=0009done%5Cn%0A%0A%20%20%20S%3A-,0008NAK%5Cn,-S%3A%20%5BPACKFILE%5D)
+	if len(data) < 8 || !bytes.HasPrefix(data[8:], []byte("PACK")) {
+		return nil, errors.New("Server did not send a packfile")
+	}
 
 	return data[8:], nil
 }
 
 func verifyPackfile(packfile []byte) bool {
 	checksumLen := 20
+	if len(packfile) < checksumLen {
+		return false
+	}
 	packOnly := packfile[:len(packfile)-checksumLen]
 	checksum := packfile[len(packfile)-checksumLen:]
 	expectedChecksum := sha1.Sum(packOnly)
 	return bytes.Equal(expectedChecksum[:], checksum)
 }
 
-type ObjectType uint8
-
-const (
-	OBJ_COMMIT    ObjectType = 1
-	OBJ_TREE      ObjectType = 2
-	OBJ_BLOB      ObjectType = 3
-	OBJ_TAG       ObjectType = 4
-	OBJ_OFS_DELTA ObjectType = 6
-	OBJ_REF_DELTA ObjectType = 7
-)
-
-var OJB_MAP = map[ObjectType]string{
-	OBJ_COMMIT:    "OBJ_COMMIT",
-	OBJ_TREE:      "OBJ_TREE",
-	OBJ_BLOB:      "OBJ_BLOB",
-	OBJ_TAG:       "OBJ_TAG",
-	OBJ_OFS_DELTA: "OBJ_OFS_DELTA",
-	OBJ_REF_DELTA: "OBJ_REF_DELTA",
-}
-
-type Object struct {
-	objType string
-	size    int
-	data    []byte
-}
-
 func (t *Clone) Run() error {
+	if entries, err := os.ReadDir(t.Directory); err == nil && len(entries) > 0 {
+		return fmt.Errorf("destination path '%s' already exists and is not an empty directory", t.Directory)
+	}
+	fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", t.Directory)
+
 	// get references
-	refs, err := getReferences(t.URL)
+	adv, err := getReferences(t.URL)
 	if err != nil {
 		return err
 	}
 
-	masterHash, err := getMasterObjHash(refs)
+	target, err := t.pickTarget(adv)
 	if err != nil {
 		return err
 	}
 
-	packfile, err := getPackFile(t.URL, masterHash)
+	wants := []string{target.hash}
+	seen := map[string]bool{target.hash: true}
+	// only what the clone keeps: the branches and the tags
+	for _, ref := range adv.refs {
+		if seen[ref.hash] || strings.HasSuffix(ref.name, "^{}") {
+			continue
+		}
+		if !strings.HasPrefix(ref.name, "refs/heads/") && !strings.HasPrefix(ref.name, "refs/tags/") {
+			continue
+		}
+		seen[ref.hash] = true
+		wants = append(wants, ref.hash)
+	}
+
+	packfile, err := getPackFile(t.URL, wants)
 	if err != nil {
 		return err
 	}
@@ -140,85 +166,49 @@ func (t *Clone) Run() error {
 	if ok := verifyPackfile(packfile); !ok {
 		return errors.New("Could not verify the packfile")
 	}
-	fmt.Println(packfile[:20])
-	// skip "pack"
-	packfile = packfile[4:]
-
-	versionNum := binary.BigEndian.Uint32(packfile[:4])
-	totalSize := binary.BigEndian.Uint32(packfile[4 : 4+4])
-
-	packfile = packfile[8:]
-
-	fmt.Println(versionNum, totalSize, packfile[0:3])
-
-	for {
-		// scan objects
-		index := 0
-		firstByte := packfile[index]
-		fmt.Println(firstByte, packfile[index+1])
-		shouldReadNext := firstByte > 128 // MSB == 1
-		var mask uint8 = 112              // 0b01110000
-		objectType := firstByte & mask >> 4
-		objectTypeName := OJB_MAP[ObjectType(objectType)]
-		fmt.Println(objectTypeName, shouldReadNext)
-		mask = 15 // 0b00001111
-		objectSize := uint64(firstByte & mask)
-		shift := 4
-		// first 4 bits form least significant byte for integer: size  eg: 0000XXXX, first 4 are 0 and last 4 are actual bits
-		// other 7 bits are prepended to first 4 while first bit is 1
-		// for eg: byte array [144 15 120] will form integer bit sequence of 0b11110000 = 240
-
-		for shouldReadNext {
-			index++
-			nextByte := packfile[index]
-			shouldReadNext = nextByte > 128 // MSB == 1
-			var msbMask uint8 = 127
-			nextByte = nextByte & msbMask
-			fmt.Println(objectSize, index)
-			objectSize = objectSize | uint64(nextByte)<<shift
-			shift += 7
-			fmt.Println(objectSize, index)
-		}
-
-		object := packfile[index+1:]
-		objstream := bytes.NewBuffer(object)
-
-		r, err := zlib.NewReader(objstream)
-		if err != nil {
-			return err
-		}
-		data, _ := io.ReadAll(r)
-		dataStr := string(data)
-		fmt.Printf("%v \n len = %d\n", dataStr, len(data))
-
-		fmt.Println()
-		offset := len(object) - objstream.Len()
-		if offset <= 0 {
-			break
-		}
-		packfile = packfile[offset+1:]
 
+	repo := &clonedRepo{
+		workTree: t.Directory,
+		gitDir:   filepath.Join(t.Directory, ".git"),
 	}
-
-	fmt.Println("NOTE: Clone is WORK IN PROGRESS")
-	return nil
+	if err := repo.init(); err != nil {
+		return err
+	}
+	if err := repo.storePack(packfile); err != nil {
+		return err
+	}
+	defer repo.store.Close()
+	// the config comes first, it decides which refs get a reflog
+	if err := repo.writeConfig(t.URL, target.branch); err != nil {
+		return err
+	}
+	if err := repo.writeRefs(adv, target, t.URL); err != nil {
+		return err
+	}
+	return repo.checkout(target.hash)
 }
 
-func getMasterObjHash(refs []string) (string, error) {
-	for _, ref := range refs {
-		// hashValue := strings.SplitN(ref, " ", 2)
-		// hash := hashValue[0]
-		// value := hashValue[1]
-		var hash, val string
-		fmt.Sscanf(ref, "%v %v", &hash, &val) // saw a better way :)
-		if val == "refs/heads/master" {
-			return hash, nil
+// pickTarget decides what the clone checks out: the --branch option when
+// given, otherwise the branch the remote HEAD points to
+func (t *Clone) pickTarget(adv *advertisement) (cloneTarget, error) {
+	if t.Branch != "" {
+		if ref, ok := adv.find("refs/heads/" + t.Branch); ok {
+			return cloneTarget{branch: t.Branch, hash: ref.hash}, nil
 		}
+		if ref, ok := adv.find("refs/tags/" + t.Branch); ok {
+			return cloneTarget{hash: ref.hash}, nil
+		}
+		return cloneTarget{}, fmt.Errorf("Remote branch %s not found in upstream origin", t.Branch)
+	}
+	branch, err := adv.headBranch()
+	if err != nil {
+		return cloneTarget{}, err
 	}
-	return "", errors.New("Did not get refs/heads/master")
+	ref, _ := adv.find("refs/heads/" + branch)
+	return cloneTarget{branch: branch, hash: ref.hash}, nil
 }
 
-func extractRefs(data []byte) ([]string, error) {
+func extractRefs(data []byte) (*advertisement, error) {
 	// should be of the form
 	// smart_reply     =  PKT-LINE("# service=$servicename" LF)
 	//  "0000"
@@ -227,28 +217,50 @@ func extractRefs(data []byte) ([]string, error) {
 	//  "0000"
 	// ref_list        =  empty_list / non_empty_list
 
-	// skip first line
-	lineSize, err := bToUint16(data[:4])
-	if err != nil {
-		return nil, err
+	// a dumb HTTP server sends its info/refs file as is
+	rest, ok := bytes.CutPrefix(data, []byte("001e# service=git-upload-pack\n"))
+	if !ok {
+		return nil, errors.New("Remote repository does not speak the smart HTTP protocol")
 	}
-	skipLine4Zeros := lineSize + 4
 	refList := make([]string, 0)
-	currIdx := skipLine4Zeros
-	// while not considering version
-	for int(currIdx) < len(data)-4 { // 4 is subtracted to rule out trailing 4 zeros
-		currData := data[currIdx:]
-		size := currData[:4]
-		sizeInt, err := bToUint16(size)
+	for len(rest) > 0 {
+		var line []byte
+		var err error
+		line, rest, err = nextPktLine(rest)
 		if err != nil {
 			return nil, err
 		}
-		refList = append(refList, string(currData[4:sizeInt]))
-		currIdx += sizeInt
+		if line == nil { // flush-pkt
+			continue
+		}
+		refList = append(refList, strings.TrimSuffix(string(line), "\n"))
+	}
+	if len(refList) > 0 && strings.HasPrefix(refList[0], "version ") {
+		refList = refList[1:]
+	}
+	if len(refList) == 0 {
+		return nil, errors.New("Remote repository has no references")
+	}
+	return parseAdvertisement(refList)
+}
+
+// nextPktLine splits the first pkt-line off data, giving its payload, or nil
+// for a flush-pkt, and what follows it
+func nextPktLine(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errors.New("Truncated pkt-line in the reference advertisement")
+	}
+	size, err := bToUint16(data[:4])
+	if err != nil {
+		return nil, nil, err
+	}
+	if size == 0 {
+		return nil, data[4:], nil
+	}
+	if size < 4 || int(size) > len(data) {
+		return nil, nil, fmt.Errorf("Invalid pkt-line length %d in the reference advertisement", size)
 	}
-	// remove caps from first entry
-	refList[0] = strings.Split(refList[0], string(rune(0)))[0]
-	return refList, nil
+	return data[4:size], data[size:], nil
 }
 
 func bToUint16(bytes []byte) (uint16, error) {
@@ -0,0 +1,261 @@
+package commit
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/flags"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+	"github.com/codecrafters-io/git-starter-go/internal/treecommit"
+	"github.com/codecrafters-io/git-starter-go/internal/treewriter"
+)
+
+// ErrAborted is returned when the commit is not made and why has been shown
+// already, the command fails without saying more
+var ErrAborted = errors.New("Commit aborted")
+
+type Commit struct {
+	Fs          *flag.FlagSet
+	Repo        *repository.Repository
+	messages    flags.StringList
+	messageFile string
+	amend       bool // replace the commit HEAD points to
+	allowEmpty  bool // allow a commit with the same tree as its parent
+	quiet       bool
+}
+
+func (c *Commit) Initialize(args []string) error {
+	c.Fs.Var(&c.messages, "m", "Commit message, each one becomes a paragraph")
+	c.Fs.Var(&c.messages, "message", "Same as -m")
+	c.Fs.StringVar(&c.messageFile, "F", "", "Read the commit message from a file, - for stdin")
+	c.Fs.StringVar(&c.messageFile, "file", "", "Same as -F")
+	c.Fs.BoolVar(&c.amend, "amend", false, "Replace the tip of the current branch by a new commit")
+	c.Fs.BoolVar(&c.allowEmpty, "allow-empty", false, "Allow a commit that does not change the tree")
+	c.Fs.BoolVar(&c.quiet, "q", false, "Do not print the commit summary")
+	c.Fs.BoolVar(&c.quiet, "quiet", false, "Same as -q")
+	err := c.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if len(c.messages) > 0 && c.messageFile != "" {
+		return errors.New("Options -m and -F cannot be used together")
+	}
+	if c.Fs.NArg() > 0 {
+		return fmt.Errorf("Unexpected argument %s, committing only some paths is not supported", c.Fs.Arg(0))
+	}
+	return nil
+}
+
+func (c *Commit) Usage() string {
+	return "git commit [-m <msg> | -F <file>] [--amend] [--allow-empty] [-q] : Record changes to the repository"
+}
+
+func (c *Commit) Run() error {
+	if err := c.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	idx, err := c.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if len(idx.Unmerged()) > 0 {
+		return errors.New("Committing is not possible because you have unmerged files.\n" +
+			"hint: Fix them up in the work tree, and then use 'git add/rm <file>'\n" +
+			"hint: as appropriate to mark resolution and make a commit.")
+	}
+
+	store := refs.NewStore(c.Repo)
+	head, err := store.Head()
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	commit := &object.Commit{}
+	var amended *object.Commit
+	reflogAction := "commit"
+	mergeHeads, err := c.mergeHeads()
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.amend:
+		if head.IsZero() {
+			return errors.New("You have nothing to amend.")
+		}
+		if len(mergeHeads) > 0 {
+			return errors.New("You are in the middle of a merge -- cannot amend.")
+		}
+		amended, err = object.GetCommit(c.Repo.Objects, head)
+		if err != nil {
+			return err
+		}
+		commit.Parents = amended.Parents
+		commit.Author = amended.Author
+		reflogAction = "commit (amend)"
+	case head.IsZero():
+		reflogAction = "commit (initial)"
+	default:
+		commit.Parents = append([]objectstore.Hash{head}, mergeHeads...)
+		if len(mergeHeads) > 0 {
+			reflogAction = "commit (merge)"
+		}
+	}
+
+	commit.Tree, err = treewriter.WriteTree(c.Repo.Objects, idx, treewriter.Options{})
+	if err != nil {
+		return err
+	}
+	if !c.allowEmpty && len(mergeHeads) == 0 && !(c.amend && len(commit.Parents) > 1) {
+		empty, err := c.unchanged(commit)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return c.nothingToCommit()
+		}
+	}
+
+	commit.Message, err = c.message(amended)
+	if err != nil {
+		return err
+	}
+	if commit.Message == "" {
+		fmt.Fprintln(os.Stderr, "Aborting commit due to empty commit message.")
+		return ErrAborted
+	}
+
+	// keep the cache tree that writing the tree refreshed
+	if err := c.Repo.WriteIndex(idx); err != nil {
+		return err
+	}
+	hash, err := treecommit.CommitTree(c.Repo, commit)
+	if err != nil {
+		return err
+	}
+	message := reflogAction + ": " + strings.SplitN(commit.Message, "\n", 2)[0]
+	if err := store.Update("HEAD", hash, refs.UpdateOptions{Old: &head, Message: message}); err != nil {
+		return err
+	}
+	for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE"} {
+		os.Remove(c.Repo.Path(name))
+	}
+
+	if c.quiet {
+		return nil
+	}
+	return c.printSummary(commit, hash, head.IsZero(), c.amend)
+}
+
+// mergeHeads are the commits being merged, from MERGE_HEAD
+func (c *Commit) mergeHeads() ([]objectstore.Hash, error) {
+	data, err := os.ReadFile(c.Repo.Path("MERGE_HEAD"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var heads []objectstore.Hash
+	for _, line := range strings.Fields(string(data)) {
+		hash, err := objectstore.ParseHash(line)
+		if err != nil {
+			return nil, fmt.Errorf("Corrupt MERGE_HEAD file (%s)", line)
+		}
+		heads = append(heads, hash)
+	}
+	return heads, nil
+}
+
+// unchanged tells whether the commit would have the same tree as its first
+// parent, or the empty tree for a root commit
+func (c *Commit) unchanged(commit *object.Commit) (bool, error) {
+	if len(commit.Parents) == 0 {
+		tree, err := object.GetTree(c.Repo.Objects, commit.Tree)
+		if err != nil {
+			return false, err
+		}
+		return len(tree.Entries) == 0, nil
+	}
+	parent, err := object.GetCommit(c.Repo.Objects, commit.Parents[0])
+	if err != nil {
+		return false, err
+	}
+	return parent.Tree == commit.Tree, nil
+}
+
+// nothingToCommit shows the status like git does and aborts
+func (c *Commit) nothingToCommit() error {
+	st := &status.Status{Fs: flag.NewFlagSet("status", flag.ExitOnError), Repo: c.Repo, Committing: true, Amend: c.amend}
+	if err := st.Initialize(nil); err != nil {
+		return err
+	}
+	if err := st.Run(); err != nil {
+		return err
+	}
+	if c.amend {
+		fmt.Fprint(os.Stderr, "You asked to amend the most recent commit, but doing so would make\n"+
+			"it empty. You can repeat your command with --allow-empty, or you can\n"+
+			"remove the commit entirely with \"git reset HEAD^\".\n")
+	}
+	return ErrAborted
+}
+
+// message is the cleaned up message from -m or -F. Without either, an amended
+// commit keeps its message and a merge uses MERGE_MSG
+func (c *Commit) message(amended *object.Commit) (string, error) {
+	switch {
+	case len(c.messages) > 0:
+		// each -m is a paragraph of its own
+		return cleanup(strings.Join(c.messages, "\n\n"), false), nil
+	case c.messageFile != "":
+		data, err := treecommit.ReadMessageFile(c.messageFile)
+		if err != nil {
+			return "", err
+		}
+		return cleanup(string(data), false), nil
+	case amended != nil:
+		return amended.Message, nil
+	}
+	if data, err := os.ReadFile(c.Repo.Path("MERGE_MSG")); err == nil {
+		return cleanup(string(data), true), nil
+	}
+	return "", errors.New("Please supply the message using either -m or -F option.")
+}
+
+// cleanup strips trailing whitespace, leading and trailing blank lines and
+// repeated blank lines from a message, and with stripComments the lines
+// starting with '#'. A message that is not empty ends with a newline
+func cleanup(message string, stripComments bool) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(message, "\n") {
+		if stripComments && strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// identityOf is "Name <email>" of a signature
+func identityOf(sig object.Signature) string {
+	return sig.Name + " <" + sig.Email + ">"
+}
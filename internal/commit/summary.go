@@ -0,0 +1,260 @@
+package commit
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+)
+
+// printSummary prints what git prints after a commit:
+//
+//	[main 1c9e1e4] Add the parser
+//	 2 files changed, 10 insertions(+), 1 deletion(-)
+//	 create mode 100644 parser.go
+func (c *Commit) printSummary(commit *object.Commit, hash objectstore.Hash, root, amend bool) error {
+	branch, err := refs.NewStore(c.Repo).HeadBranch()
+	if err != nil {
+		return err
+	}
+	where := strings.TrimPrefix(branch, "refs/heads/")
+	if branch == "" {
+		where = "detached HEAD"
+	}
+	if root {
+		where += " (root-commit)"
+	}
+	fmt.Printf("[%s %s] %s\n", where, hash.String()[:7], commit.Subject())
+	if author := identityOf(commit.Author); author != identityOf(commit.Committer) {
+		fmt.Printf(" Author: %s\n", author)
+	}
+	if amend {
+		fmt.Printf(" Date: %s %s\n", commit.Author.When.Format("Mon Jan 2 15:04:05 2006"), commit.Author.Timezone())
+	}
+
+	// like git log, merges do not show a diff
+	if len(commit.Parents) > 1 {
+		return nil
+	}
+	parent := objectstore.ZeroHash
+	if len(commit.Parents) == 1 {
+		parent = commit.Parents[0]
+	}
+	changes, err := status.CompareTrees(c.Repo.Objects, parent, hash, true)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	insertions, deletions := 0, 0
+	for _, change := range changes {
+		old, err := content(c.Repo.Objects, change.HeadMode, change.HeadHash)
+		if err != nil {
+			return err
+		}
+		new, err := content(c.Repo.Objects, change.IndexMode, change.IndexHash)
+		if err != nil {
+			return err
+		}
+		added, removed := countLines(old, new)
+		insertions += added
+		deletions += removed
+	}
+	fmt.Println(shortStat(len(changes), insertions, deletions))
+
+	for _, change := range changes {
+		switch {
+		case change.Staged == 'A':
+			fmt.Printf(" create mode %06o %s\n", uint32(change.IndexMode), change.Path)
+		case change.Staged == 'D':
+			fmt.Printf(" delete mode %06o %s\n", uint32(change.HeadMode), change.Path)
+		case change.Staged == 'R':
+			fmt.Printf(" rename %s (%d%%)\n", renameName(change.OrigPath, change.Path), change.Score)
+			if change.HeadMode != change.IndexMode {
+				fmt.Printf(" mode change %06o => %06o\n", uint32(change.HeadMode), uint32(change.IndexMode))
+			}
+		case change.HeadMode != change.IndexMode:
+			fmt.Printf(" mode change %06o => %06o %s\n", uint32(change.HeadMode), uint32(change.IndexMode), change.Path)
+		}
+	}
+	return nil
+}
+
+// content is what the line counts are taken from, a submodule counts as the
+// single line git shows for it
+func content(store objectstore.ObjectStore, mode object.FileMode, hash objectstore.Hash) ([]byte, error) {
+	switch {
+	case mode == 0:
+		return nil, nil
+	case mode == object.ModeGitlink:
+		return []byte("Subproject commit " + hash.String() + "\n"), nil
+	}
+	blob, err := object.GetBlob(store, hash)
+	if err != nil {
+		return nil, err
+	}
+	return blob.Data, nil
+}
+
+func shortStat(files, insertions, deletions int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, " %d %s changed", files, status.Plural(files, "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		fmt.Fprintf(&b, ", %d %s(+)", insertions, status.Plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		fmt.Fprintf(&b, ", %d %s(-)", deletions, status.Plural(deletions, "deletion", "deletions"))
+	}
+	return b.String()
+}
+
+// renameName shortens a rename to the part that changed, like
+// "src/{old => new}/main.go"
+func renameName(from, to string) string {
+	// the common prefix ends with a slash
+	prefix := 0
+	for i := 0; i < len(from) && i < len(to) && from[i] == to[i]; i++ {
+		if from[i] == '/' {
+			prefix = i + 1
+		}
+	}
+	// the common suffix starts with a slash, which may be the one ending the
+	// prefix. Both names are compared from their terminating NUL like in git
+	at := func(s string, i int) byte {
+		if i == len(s) {
+			return 0
+		}
+		return s[i]
+	}
+	stop := prefix
+	if prefix > 0 {
+		stop--
+	}
+	suffix := 0
+	for i, j := len(from), len(to); i >= stop && j >= stop && at(from, i) == at(to, j); i, j = i-1, j-1 {
+		if at(from, i) == '/' {
+			suffix = len(from) - i
+		}
+	}
+	fromMid := len(from) - prefix - suffix
+	toMid := len(to) - prefix - suffix
+	if fromMid < 0 {
+		fromMid = 0
+	}
+	if toMid < 0 {
+		toMid = 0
+	}
+	name := from[prefix:prefix+fromMid] + " => " + to[prefix:prefix+toMid]
+	if prefix+suffix > 0 {
+		name = from[:prefix] + "{" + name + "}" + from[len(from)-suffix:]
+	}
+	return name
+}
+
+// countLines is the number of lines added and removed by the shortest edit
+// from old to new, binary content has no lines
+func countLines(old, new []byte) (int, int) {
+	if isBinary(old) || isBinary(new) {
+		return 0, 0
+	}
+	a, b := splitLines(old), splitLines(new)
+	common := commonLines(a, b)
+	return len(b) - common, len(a) - common
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines numbers the lines, a line keeps its newline so that a missing
+// newline at the end is a change too
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n') + 1
+		if end == 0 {
+			end = len(data)
+		}
+		lines = append(lines, string(data[:end]))
+		data = data[end:]
+	}
+	return lines
+}
+
+// maxEditCost bounds the work on files that have almost nothing in common,
+// their lines are all counted as changed
+const maxEditCost = 20000
+
+// commonLines is the length of the longest common subsequence of a and b.
+// Lines at the start and end that are equal and lines found on one side only
+// are dealt with before the middle is handed to Myers' O(ND) algorithm
+func commonLines(a, b []string) int {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	inA := make(map[string]bool, len(a))
+	for _, line := range a {
+		inA[line] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, line := range b {
+		inB[line] = true
+	}
+	a, b = keep(a, inB), keep(b, inA)
+
+	n, m := len(a), len(b)
+	distance := n + m
+	if max := n + m; max > 0 {
+		v := make([]int, 2*max+2)
+	search:
+		for d := 0; d <= max && d <= maxEditCost; d++ {
+			for k := -d; k <= d; k += 2 {
+				var x int
+				if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+					x = v[max+k+1]
+				} else {
+					x = v[max+k-1] + 1
+				}
+				y := x - k
+				for x < n && y < m && a[x] == b[y] {
+					x++
+					y++
+				}
+				v[max+k] = x
+				if x >= n && y >= m {
+					distance = d
+					break search
+				}
+			}
+		}
+	}
+	return prefix + suffix + (n+m-distance)/2
+}
+
+// keep filters lines to the ones in the set
+func keep(lines []string, set map[string]bool) []string {
+	var kept []string
+	for _, line := range lines {
+		if set[line] {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
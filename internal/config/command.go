@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type ConfigCommand struct {
+	Fs     *flag.FlagSet
+	GitDir string // empty outside of a repository
+
+	get, getAll, add, unset, unsetAll, replaceAll, list, showOrigin bool
+	global, system, local                                           bool
+	file                                                            string
+	args                                                            []string
+}
+
+func (cc *ConfigCommand) Initialize(args []string) error {
+	cc.Fs.BoolVar(&cc.get, "get", false, "Get the value of a key")
+	cc.Fs.BoolVar(&cc.getAll, "get-all", false, "Get every value of a multi-valued key")
+	cc.Fs.BoolVar(&cc.add, "add", false, "Add a value without replacing the existing ones")
+	cc.Fs.BoolVar(&cc.unset, "unset", false, "Remove a key")
+	cc.Fs.BoolVar(&cc.unsetAll, "unset-all", false, "Remove every value of a key")
+	cc.Fs.BoolVar(&cc.replaceAll, "replace-all", false, "Replace every value of a key with one")
+	cc.Fs.BoolVar(&cc.list, "list", false, "List every variable")
+	cc.Fs.BoolVar(&cc.list, "l", false, "Shorthand for --list")
+	cc.Fs.BoolVar(&cc.showOrigin, "show-origin", false, "Show the file each value comes from")
+	cc.Fs.BoolVar(&cc.global, "global", false, "Use the user's config file")
+	cc.Fs.BoolVar(&cc.system, "system", false, "Use the system-wide config file")
+	cc.Fs.BoolVar(&cc.local, "local", false, "Use the repository config file")
+	cc.Fs.StringVar(&cc.file, "file", "", "Use the given config file")
+	cc.Fs.StringVar(&cc.file, "f", "", "Shorthand for --file")
+	err := cc.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	cc.args = cc.Fs.Args()
+
+	actions := 0
+	for _, set := range []bool{cc.get, cc.getAll, cc.add, cc.unset, cc.unsetAll, cc.replaceAll, cc.list} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return errors.New("Only one action at a time")
+	}
+	return nil
+}
+
+func (cc *ConfigCommand) Usage() string {
+	return "git config [--global|--system|--local|-f <file>] [--get|--get-all|--add|--unset|--unset-all|--replace-all|--list] <key> [<value>]"
+}
+
+// scopeFile is the file writes go to, and reads are limited to when a scope was given
+func (cc *ConfigCommand) scopeFile() (string, error) {
+	switch {
+	case cc.file != "":
+		return cc.file, nil
+	case cc.global:
+		path := GlobalWritePath()
+		if path == "" {
+			return "", errors.New("$HOME not set")
+		}
+		return path, nil
+	case cc.system:
+		return SystemPath(), nil
+	}
+	if cc.GitDir == "" {
+		return "", errors.New("Not in a git directory")
+	}
+	return filepath.Join(cc.GitDir, "config"), nil
+}
+
+func (cc *ConfigCommand) hasScope() bool {
+	return cc.file != "" || cc.global || cc.system || cc.local
+}
+
+func (cc *ConfigCommand) read() (*Config, error) {
+	if !cc.hasScope() {
+		return Load(cc.GitDir)
+	}
+	path, err := cc.scopeFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path, cc.GitDir)
+}
+
+func (cc *ConfigCommand) Run() error {
+	switch {
+	case cc.list:
+		return cc.runList()
+	case cc.get, cc.getAll:
+		return cc.runGet()
+	case cc.unset, cc.unsetAll:
+		return cc.write(1, func(f *File) error {
+			count, err := f.Unset(cc.args[0], cc.unsetAll)
+			if err == nil && count == 0 {
+				err = fmt.Errorf("No such key: %s", cc.args[0])
+			}
+			return err
+		})
+	case cc.add:
+		return cc.write(2, func(f *File) error { return f.Add(cc.args[0], cc.args[1]) })
+	case cc.replaceAll:
+		return cc.write(2, func(f *File) error { return f.ReplaceAll(cc.args[0], cc.args[1]) })
+	}
+	switch len(cc.args) {
+	case 1:
+		return cc.runGet()
+	case 2:
+		return cc.write(2, func(f *File) error { return f.Set(cc.args[0], cc.args[1]) })
+	}
+	return errors.New("Wrong number of arguments")
+}
+
+func (cc *ConfigCommand) runList() error {
+	c, err := cc.read()
+	if err != nil {
+		return err
+	}
+	for _, e := range c.Entries() {
+		if cc.showOrigin {
+			fmt.Printf("file:%s\t", e.Origin)
+		}
+		if e.HasValue {
+			fmt.Printf("%s=%s\n", e.Key(), e.Value)
+		} else {
+			fmt.Println(e.Key())
+		}
+	}
+	return nil
+}
+
+func (cc *ConfigCommand) runGet() error {
+	if len(cc.args) != 1 {
+		return errors.New("Wrong number of arguments, expected <key>")
+	}
+	if _, err := ParseKey(cc.args[0]); err != nil {
+		return err
+	}
+	c, err := cc.read()
+	if err != nil {
+		return err
+	}
+	values := c.lookup(cc.args[0])
+	if len(values) == 0 {
+		return fmt.Errorf("Key not found: %s", cc.args[0])
+	}
+	if !cc.getAll {
+		values = values[len(values)-1:]
+	}
+	for _, e := range values {
+		if cc.showOrigin {
+			fmt.Printf("file:%s\t", e.Origin)
+		}
+		fmt.Println(e.Value)
+	}
+	return nil
+}
+
+func (cc *ConfigCommand) write(nargs int, edit func(f *File) error) error {
+	if len(cc.args) != nargs {
+		return fmt.Errorf("Wrong number of arguments, expected %d", nargs)
+	}
+	path, err := cc.scopeFile()
+	if err != nil {
+		return err
+	}
+	f, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(f); err != nil {
+		if errors.Is(err, ErrMultipleValues) {
+			return fmt.Errorf("Cannot overwrite multiple values with a single value, use --add or --replace-all to change %s", strings.ToLower(cc.args[0]))
+		}
+		return err
+	}
+	return f.Save()
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Key is a parsed "section.subsection.name", section and name are lower-cased
+type Key struct {
+	Section    string
+	Subsection string
+	Name       string
+	RawName    string // the name as the user spelled it, used when writing
+}
+
+func ParseKey(key string) (Key, error) {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first <= 0 || last == len(key)-1 {
+		return Key{}, fmt.Errorf("Key does not contain a section: %s", key)
+	}
+	k := Key{
+		Section: strings.ToLower(key[:first]),
+		RawName: key[last+1:],
+	}
+	k.Name = strings.ToLower(k.RawName)
+	if first != last {
+		k.Subsection = key[first+1 : last]
+	}
+	if !isAlpha(k.Name[0]) {
+		return Key{}, fmt.Errorf("Invalid key: %s", key)
+	}
+	return k, nil
+}
+
+func (k Key) String() string {
+	if k.Subsection == "" {
+		return k.Section + "." + k.Name
+	}
+	return k.Section + "." + k.Subsection + "." + k.Name
+}
+
+type Scope uint8
+
+const (
+	ScopeSystem Scope = iota
+	ScopeGlobal
+	ScopeLocal
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeSystem:
+		return "system"
+	case ScopeGlobal:
+		return "global"
+	}
+	return "local"
+}
+
+type Entry struct {
+	Section    string
+	Subsection string
+	Name       string
+	Value      string
+	HasValue   bool
+	Origin     string // the file the entry was read from
+	Scope      Scope
+}
+
+func (e Entry) Key() Key {
+	return Key{Section: e.Section, Subsection: e.Subsection, Name: e.Name, RawName: e.Name}
+}
+
+// Config is the merged, read only view of every config file. Later entries
+// win, so the repository overrides the user who overrides the system
+type Config struct {
+	entries []Entry
+}
+
+// SystemPath is /etc/gitconfig unless GIT_CONFIG_SYSTEM says otherwise, and
+// empty when GIT_CONFIG_NOSYSTEM is set
+func SystemPath() string {
+	if noSystem, _ := ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM")); noSystem {
+		return ""
+	}
+	if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/gitconfig"
+}
+
+// GlobalPaths are the user's files in the order they are read:
+// $XDG_CONFIG_HOME/git/config (or ~/.config/git/config) and then ~/.gitconfig
+func GlobalPaths() []string {
+	if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}
+	}
+	var paths []string
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+	if xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		paths = append(paths, filepath.Join(home, ".gitconfig"))
+	}
+	return paths
+}
+
+// GlobalWritePath is the file "config --global" writes to: ~/.gitconfig,
+// unless only the XDG file exists
+func GlobalWritePath() string {
+	paths := GlobalPaths()
+	if len(paths) == 0 {
+		return ""
+	}
+	global := paths[len(paths)-1]
+	if _, err := os.Stat(global); os.IsNotExist(err) && len(paths) > 1 {
+		if _, err := os.Stat(paths[0]); err == nil {
+			return paths[0]
+		}
+	}
+	return global
+}
+
+// Load reads system, global and, when gitDir is not empty, repository config
+func Load(gitDir string) (*Config, error) {
+	c := &Config{}
+	l := &loader{config: c, gitDir: gitDir}
+	if path := SystemPath(); path != "" {
+		if err := l.load(path, ScopeSystem, 0); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range GlobalPaths() {
+		if err := l.load(path, ScopeGlobal, 0); err != nil {
+			return nil, err
+		}
+	}
+	if gitDir != "" {
+		if err := l.load(filepath.Join(gitDir, "config"), ScopeLocal, 0); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// LoadFile reads a single file (with its includes), as "config --file" does
+func LoadFile(path, gitDir string) (*Config, error) {
+	c := &Config{}
+	l := &loader{config: c, gitDir: gitDir}
+	if err := l.load(path, ScopeLocal, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Entries returns every entry in the order they were read
+func (c *Config) Entries() []Entry {
+	return c.entries
+}
+
+func (c *Config) lookup(key string) []Entry {
+	k, err := ParseKey(key)
+	if err != nil {
+		return nil
+	}
+	var found []Entry
+	for _, e := range c.entries {
+		if e.Section == k.Section && e.Subsection == k.Subsection && e.Name == k.Name {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Get returns the last value of key
+func (c *Config) Get(key string) (string, bool) {
+	found := c.lookup(key)
+	if len(found) == 0 {
+		return "", false
+	}
+	return found[len(found)-1].Value, true
+}
+
+// GetAll returns every value of a multi-valued key
+func (c *Config) GetAll(key string) []string {
+	var values []string
+	for _, e := range c.lookup(key) {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// GetBool returns the boolean value of key, or def when it is not set
+func (c *Config) GetBool(key string, def bool) (bool, error) {
+	found := c.lookup(key)
+	if len(found) == 0 {
+		return def, nil
+	}
+	last := found[len(found)-1]
+	if !last.HasValue {
+		return true, nil
+	}
+	value, err := ParseBool(last.Value)
+	if err != nil {
+		return def, fmt.Errorf("Bad boolean config value '%s' for '%s'", last.Value, key)
+	}
+	return value, nil
+}
+
+// GetInt returns the integer value of key, understanding the k, m and g suffixes
+func (c *Config) GetInt(key string, def int64) (int64, error) {
+	value, ok := c.Get(key)
+	if !ok {
+		return def, nil
+	}
+	n, err := ParseInt(value)
+	if err != nil {
+		return def, fmt.Errorf("Bad numeric config value '%s' for '%s'", value, key)
+	}
+	return n, nil
+}
+
+// Subsections lists the subsections of a section, like the names of every [remote "..."]
+func (c *Config) Subsections(section string) []string {
+	section = strings.ToLower(section)
+	seen := map[string]bool{}
+	var subsections []string
+	for _, e := range c.entries {
+		if e.Section == section && e.Subsection != "" && !seen[e.Subsection] {
+			seen[e.Subsection] = true
+			subsections = append(subsections, e.Subsection)
+		}
+	}
+	return subsections
+}
+
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("Not a boolean: %s", value)
+}
+
+func ParseInt(value string) (int64, error) {
+	multiplier := int64(1)
+	if value != "" {
+		switch strings.ToLower(value[len(value)-1:]) {
+		case "k":
+			multiplier = 1 << 10
+		case "m":
+			multiplier = 1 << 20
+		case "g":
+			multiplier = 1 << 30
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
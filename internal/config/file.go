@@ -0,0 +1,511 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type itemKind uint8
+
+const (
+	itemOther   itemKind = iota // blank lines and comments
+	itemSection                 // a [section "subsection"] header
+	itemEntry                   // a name = value line, possibly continued over several lines
+)
+
+// item is one piece of a config file, raw is its exact text so that a file we
+// edit is written back untouched apart from the lines we changed
+type item struct {
+	kind       itemKind
+	raw        string
+	section    string // lower-cased
+	subsection string // case sensitive, except in the deprecated [section.subsection] form
+	name       string // lower-cased
+	value      string
+	hasValue   bool // "name" alone is a boolean true, unlike "name ="
+}
+
+// File is a single config file kept in the form it was read in
+type File struct {
+	Path  string
+	items []*item
+}
+
+// ReadFile parses a config file, a missing file is an empty config
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &File{Path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v in %s", err, path)
+	}
+	f.Path = path
+	return f, nil
+}
+
+type parser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Bad config line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// Parse reads the git config format:
+//
+//	# comment
+//	[section]
+//		name = value ; comment
+//	[section "subsection"]
+//		name = "quoted \"value\"" \
+//			continued
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	p := &parser{data: data, line: 1}
+	var section, subsection string
+	for p.pos < len(p.data) {
+		start := p.pos
+		p.skipSpace()
+		c := p.peek()
+		switch {
+		case p.pos >= len(p.data):
+			f.items = append(f.items, &item{kind: itemOther, raw: string(p.data[start:])})
+		case c == '\n':
+			p.pos++
+			p.line++
+			f.items = append(f.items, &item{kind: itemOther, raw: string(p.data[start:p.pos])})
+		case c == '#' || c == ';':
+			p.skipLine()
+			f.items = append(f.items, &item{kind: itemOther, raw: string(p.data[start:p.pos])})
+		case c == '[':
+			var err error
+			section, subsection, err = p.parseSection()
+			if err != nil {
+				return nil, err
+			}
+			f.items = append(f.items, &item{kind: itemSection, raw: string(p.data[start:p.pos]), section: section, subsection: subsection})
+		case isAlpha(c):
+			if section == "" {
+				return nil, p.errorf("entry outside of a section")
+			}
+			it, err := p.parseEntry()
+			if err != nil {
+				return nil, err
+			}
+			it.raw = string(p.data[start:p.pos])
+			it.section, it.subsection = section, subsection
+			f.items = append(f.items, it)
+		default:
+			return nil, p.errorf("unexpected %q", c)
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t' || p.data[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// skipLine moves past the end of the current line
+func (p *parser) skipLine() {
+	for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos < len(p.data) {
+		p.pos++
+		p.line++
+	}
+}
+
+// afterValue accepts what may follow a header or value: spaces, a comment and the newline
+func (p *parser) afterValue() error {
+	p.skipSpace()
+	switch p.peek() {
+	case '#', ';':
+		p.skipLine()
+	case '\n':
+		p.pos++
+		p.line++
+	case 0:
+	default:
+		return p.errorf("unexpected %q", p.peek())
+	}
+	return nil
+}
+
+func (p *parser) parseSection() (string, string, error) {
+	p.pos++ // [
+	start := p.pos
+	for p.pos < len(p.data) && (isAlnum(p.data[p.pos]) || p.data[p.pos] == '-' || p.data[p.pos] == '.') {
+		p.pos++
+	}
+	name := string(p.data[start:p.pos])
+	if name == "" {
+		return "", "", p.errorf("empty section name")
+	}
+	var subsection string
+	switch p.peek() {
+	case ']':
+		// deprecated [section.subsection] form, the subsection is case insensitive
+		if dot := strings.IndexByte(name, '.'); dot >= 0 {
+			name, subsection = name[:dot], strings.ToLower(name[dot+1:])
+		}
+	case ' ', '\t':
+		p.skipSpace()
+		if p.peek() != '"' {
+			return "", "", p.errorf("expected a quoted subsection")
+		}
+		p.pos++
+		var sub strings.Builder
+		for {
+			c := p.peek()
+			if c == 0 || c == '\n' {
+				return "", "", p.errorf("unterminated subsection")
+			}
+			p.pos++
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				c = p.peek()
+				if c == 0 || c == '\n' {
+					return "", "", p.errorf("unterminated subsection")
+				}
+				p.pos++
+			}
+			sub.WriteByte(c)
+		}
+		subsection = sub.String()
+		if p.peek() != ']' {
+			return "", "", p.errorf("expected ]")
+		}
+	default:
+		return "", "", p.errorf("invalid section header")
+	}
+	p.pos++ // ]
+	if err := p.afterValue(); err != nil {
+		return "", "", err
+	}
+	return strings.ToLower(name), subsection, nil
+}
+
+func (p *parser) parseEntry() (*item, error) {
+	start := p.pos
+	for p.pos < len(p.data) && (isAlnum(p.data[p.pos]) || p.data[p.pos] == '-') {
+		p.pos++
+	}
+	it := &item{kind: itemEntry, name: strings.ToLower(string(p.data[start:p.pos]))}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return it, p.afterValue()
+	}
+	p.pos++
+	it.hasValue = true
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	it.value = value
+	return it, nil
+}
+
+// parseValue follows git: leading and trailing blanks are dropped, blanks in
+// between are kept, quotes protect blanks and comment characters, and a
+// backslash at the end of a line continues the value on the next one
+func (p *parser) parseValue() (string, error) {
+	var value strings.Builder
+	quoted := false
+	spaces := 0
+	for {
+		c := p.peek()
+		if c == 0 || (c == '\n' && !quoted) {
+			break
+		}
+		if c == '\n' {
+			return "", p.errorf("unterminated quote")
+		}
+		p.pos++
+		if !quoted && (c == ';' || c == '#') {
+			p.skipLine()
+			return value.String(), nil
+		}
+		if !quoted && (c == ' ' || c == '\t' || c == '\r') {
+			if value.Len() > 0 {
+				spaces++
+			}
+			continue
+		}
+		for ; spaces > 0; spaces-- {
+			value.WriteByte(' ')
+		}
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			escaped := p.peek()
+			p.pos++
+			switch escaped {
+			case '\n':
+				p.line++
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'b':
+				value.WriteByte('\b')
+			case '\\', '"':
+				value.WriteByte(escaped)
+			default:
+				return "", p.errorf("bad escape \\%c", escaped)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	if quoted {
+		return "", p.errorf("unterminated quote")
+	}
+	if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+	return value.String(), nil
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9')
+}
+
+// Bytes renders the file, unchanged items keep their original text
+func (f *File) Bytes() []byte {
+	var sb strings.Builder
+	for _, it := range f.items {
+		sb.WriteString(it.raw)
+	}
+	return []byte(sb.String())
+}
+
+// Save writes the file through a .lock file, like git does
+func (f *File) Save() error {
+	lock := f.Path + ".lock"
+	lockFile, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return fmt.Errorf("Could not lock config file %s: another process holds the lock", f.Path)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = lockFile.Write(f.Bytes())
+	if closeErr := lockFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, f.Path)
+}
+
+// Entries returns the entries of this file only, without following includes
+func (f *File) Entries() []Entry {
+	var entries []Entry
+	for _, it := range f.items {
+		if it.kind == itemEntry {
+			entries = append(entries, Entry{
+				Section:    it.section,
+				Subsection: it.subsection,
+				Name:       it.name,
+				Value:      it.value,
+				HasValue:   it.hasValue,
+				Origin:     f.Path,
+			})
+		}
+	}
+	return entries
+}
+
+func (it *item) matches(k Key) bool {
+	return it.kind == itemEntry && it.section == k.Section && it.subsection == k.Subsection && it.name == k.Name
+}
+
+func (it *item) inSection(k Key) bool {
+	return it.kind != itemOther && it.section == k.Section && it.subsection == k.Subsection
+}
+
+var ErrMultipleValues = errors.New("Key has multiple values")
+
+// Set replaces the value of key, or adds it when missing. It refuses to pick
+// one of several values, use ReplaceAll for that
+func (f *File) Set(key, value string) error {
+	k, err := ParseKey(key)
+	if err != nil {
+		return err
+	}
+	var found []int
+	for i, it := range f.items {
+		if it.matches(k) {
+			found = append(found, i)
+		}
+	}
+	if len(found) > 1 {
+		return ErrMultipleValues
+	}
+	if len(found) == 1 {
+		f.items[found[0]] = newEntry(k, value)
+		return nil
+	}
+	f.add(k, value)
+	return nil
+}
+
+// Add appends another value for key, keeping the existing ones
+func (f *File) Add(key, value string) error {
+	k, err := ParseKey(key)
+	if err != nil {
+		return err
+	}
+	f.add(k, value)
+	return nil
+}
+
+// ReplaceAll removes every value of key and sets a single new one
+func (f *File) ReplaceAll(key, value string) error {
+	if _, err := f.Unset(key, true); err != nil {
+		return err
+	}
+	return f.Add(key, value)
+}
+
+func (f *File) add(k Key, value string) {
+	last := -1
+	for i, it := range f.items {
+		if it.inSection(k) {
+			last = i
+		}
+	}
+	entry := newEntry(k, value)
+	if last < 0 {
+		if n := len(f.items); n > 0 && !strings.HasSuffix(f.items[n-1].raw, "\n") {
+			f.items[n-1].raw += "\n"
+		}
+		f.items = append(f.items, &item{kind: itemSection, raw: sectionHeader(k), section: k.Section, subsection: k.Subsection}, entry)
+		return
+	}
+	if !strings.HasSuffix(f.items[last].raw, "\n") {
+		f.items[last].raw += "\n"
+	}
+	f.items = append(f.items[:last+1], append([]*item{entry}, f.items[last+1:]...)...)
+}
+
+// Unset removes key, all of its values when all is set. It returns how many
+// values were removed
+func (f *File) Unset(key string, all bool) (int, error) {
+	k, err := ParseKey(key)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, it := range f.items {
+		if it.matches(k) {
+			count++
+		}
+	}
+	if count > 1 && !all {
+		return 0, ErrMultipleValues
+	}
+	kept := f.items[:0]
+	for _, it := range f.items {
+		if !it.matches(k) {
+			kept = append(kept, it)
+		}
+	}
+	f.items = kept
+	return count, nil
+}
+
+// RemoveSection drops a section with everything in it, returning false if it did not exist
+func (f *File) RemoveSection(section, subsection string) bool {
+	k := Key{Section: strings.ToLower(section), Subsection: subsection}
+	removed := false
+	kept := f.items[:0]
+	inside := false
+	for _, it := range f.items {
+		if it.kind == itemSection {
+			inside = it.inSection(k)
+			removed = removed || inside
+		}
+		if !inside {
+			kept = append(kept, it)
+		}
+	}
+	f.items = kept
+	return removed
+}
+
+// RenameSection moves every [section "subsection"] to [section "newSubsection"]
+func (f *File) RenameSection(section, subsection, newSubsection string) bool {
+	k := Key{Section: strings.ToLower(section), Subsection: subsection}
+	renamed := false
+	for _, it := range f.items {
+		if !it.inSection(k) {
+			continue
+		}
+		it.subsection = newSubsection
+		if it.kind == itemSection {
+			it.raw = sectionHeader(Key{Section: it.section, Subsection: newSubsection})
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+func sectionHeader(k Key) string {
+	if k.Subsection == "" {
+		return "[" + k.Section + "]\n"
+	}
+	sub := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(k.Subsection)
+	return fmt.Sprintf("[%s \"%s\"]\n", k.Section, sub)
+}
+
+func newEntry(k Key, value string) *item {
+	return &item{
+		kind:       itemEntry,
+		raw:        fmt.Sprintf("\t%s = %s\n", k.RawName, quoteValue(value)),
+		section:    k.Section,
+		subsection: k.Subsection,
+		name:       k.Name,
+		value:      value,
+		hasValue:   true,
+	}
+}
+
+// quoteValue escapes a value so that parseValue reads it back unchanged
+func quoteValue(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, ";#") {
+		return `"` + escaped + `"`
+	}
+	return escaped
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxIncludeDepth stops include loops, git uses the same limit
+const maxIncludeDepth = 10
+
+type loader struct {
+	config *Config
+	gitDir string
+}
+
+// load appends the entries of path, replacing include.path and matching
+// includeIf.<condition>.path entries with the content of the included file
+func (l *loader) load(path string, scope Scope, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("Exceeded maximum include depth (%d) while including %s", maxIncludeDepth, path)
+	}
+	f, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range f.Entries() {
+		e.Scope = scope
+		l.config.entries = append(l.config.entries, e)
+		if e.Name != "path" || !e.HasValue {
+			continue
+		}
+		include := e.Section == "include" && e.Subsection == ""
+		if e.Section == "includeif" {
+			include, err = l.conditionHolds(e.Subsection, path)
+			if err != nil {
+				return err
+			}
+		}
+		if !include {
+			continue
+		}
+		target := ExpandHome(e.Value)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			continue // a missing include is silently ignored
+		}
+		if err := l.load(target, scope, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conditionHolds evaluates the condition of an [includeIf "<condition>"] section:
+// gitdir:<pattern>, gitdir/i:<pattern> or onbranch:<pattern>
+func (l *loader) conditionHolds(condition, configPath string) (bool, error) {
+	kind, pattern, ok := strings.Cut(condition, ":")
+	if !ok || l.gitDir == "" {
+		return false, nil
+	}
+	switch kind {
+	case "gitdir", "gitdir/i":
+		pattern = ExpandHome(pattern)
+		if strings.HasPrefix(pattern, "./") {
+			pattern = filepath.Join(filepath.Dir(configPath), pattern[2:])
+		} else if !filepath.IsAbs(pattern) {
+			pattern = "**/" + pattern
+		}
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		gitDir, err := filepath.Abs(l.gitDir)
+		if err != nil {
+			return false, err
+		}
+		return globMatch(pattern, filepath.ToSlash(gitDir), kind == "gitdir/i"), nil
+	case "onbranch":
+		head, err := os.ReadFile(filepath.Join(l.gitDir, "HEAD"))
+		if err != nil {
+			return false, nil
+		}
+		branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+		if !ok {
+			return false, nil
+		}
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return globMatch(pattern, branch, false), nil
+	}
+	return false, nil
+}
+
+// ExpandHome resolves a leading ~/ the way git does for path valued settings
+func ExpandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// globMatch matches the wildcards used by includeIf: "*" and "?" stay inside
+// a path component while "**" crosses them
+func globMatch(pattern, name string, foldCase bool) bool {
+	var re strings.Builder
+	if foldCase {
+		re.WriteString("(?i)")
+	}
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					re.WriteString("(.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	matched, err := regexp.MatchString(re.String(), name)
+	return err == nil && matched
+}
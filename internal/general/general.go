@@ -1,19 +1,23 @@
 package general
 
 import (
-	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
 	"flag"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	lstree "github.com/codecrafters-io/git-starter-go/internal/tree"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
 )
 
 type Catfile struct {
 	Fs        *flag.FlagSet
+	Repo      *repository.Repository
 	objName   string
 	pprint    bool
 	objType   bool
@@ -30,50 +34,47 @@ func (c *Catfile) Initialize(args []string) error {
 	if err != nil {
 		return err
 	}
-	c.objName = args[len(args)-1]
-	// if c.objName starts with - return error
-	if c.objName[0] == '-' {
-		return fmt.Errorf("Invalid object name")
+	if c.Fs.NArg() != 1 {
+		return fmt.Errorf("Expected a single object name")
 	}
+	c.objName = c.Fs.Arg(0)
 	return nil
 }
 
 func (c *Catfile) Run() error {
-	filepath := filepath.Join(".git/objects", c.objName[:2], c.objName[2:])
-
-	file, err := os.Open(filepath)
-	if err != nil {
-		return err
+	obj, err := c.readObject()
+	if c.exitWith0 {
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
-	defer file.Close()
-	r, err := zlib.NewReader(file)
 	if err != nil {
 		return err
 	}
-	data, _ := io.ReadAll(r)
-	dataStr := string(data)
-	objTypeLen := strings.IndexByte(dataStr, ' ')
-	sizeLen := strings.IndexByte(dataStr, 0)
-	objType := dataStr[:objTypeLen]
-	size := dataStr[objTypeLen+1 : sizeLen]
-	body := dataStr[sizeLen+1:]
 	if c.objType {
-		fmt.Print(string(objType))
+		fmt.Print(obj.Type)
 		return nil
 	}
 	if c.objSize {
-		fmt.Print(string(size))
+		fmt.Print(len(obj.Data))
 		return nil
 	}
-	if c.exitWith0 {
-		os.Exit(0)
-	}
 	if c.pprint {
-		fmt.Print(body)
+		if obj.Type == packextractor.OBJ_TREE {
+			tree, err := object.ParseTree(obj.Data)
+			if err != nil {
+				return err
+			}
+			for _, entry := range tree.Entries {
+				fmt.Println(lstree.FormatEntry(entry))
+			}
+			return nil
+		}
+		fmt.Print(string(obj.Data))
 		return nil
 	}
-	fmt.Print(dataStr)
-	r.Close()
+	os.Stdout.Write(obj.Encode())
 
 	return nil
 
@@ -113,13 +114,22 @@ func (c *Catfile) Run() error {
 	// }
 }
 
+func (c *Catfile) readObject() (*objectstore.Object, error) {
+	hash, err := revision.NewResolver(c.Repo).Resolve(c.objName)
+	if err != nil {
+		return nil, err
+	}
+	return c.Repo.Objects.Get(hash)
+}
+
 func (c *Catfile) Usage() string {
 	return "git cat-file : Prints the contents of a git object"
 }
 
 type HashObject struct {
 	Fs          *flag.FlagSet
-	writeObject bool // if true write the object's output to .git/objects/<2char>/<remining char>
+	Repo        *repository.Repository // may be nil when only hashing
+	writeObject bool                   // if true write the object's output to .git/objects/<2char>/<remining char>
 	objName     string
 }
 
@@ -132,54 +142,27 @@ func (h *HashObject) Initialize(args []string) error {
 }
 
 func (h *HashObject) Run() error {
-	// Check for .git directory, read file, generate header, compute hash
-	// If writeObject is true, write the object to disk
+	// read file, compute hash
+	// If writeObject is true, write the object to the store
 	// Print the hash
-	objDir := ".git/objects"
-	_, err := os.Stat(objDir)
-	if err != nil {
-		return fmt.Errorf("Could not find valid git repository, Did you git init?")
-	}
-	fileInfo, err := os.Stat(h.objName)
-	if err != nil {
-		return fmt.Errorf("Could not find the file: %s \n", h.objName)
-	}
-
-	// read the file
 	data, err := os.ReadFile(h.objName)
 	if err != nil {
-		return err
+		return fmt.Errorf("Could not find the file: %s \n", h.objName)
 	}
 
-	// header := []byte("blob" + strconv.Itoa(int(fileInfo.Size())) + string(0)) my version :)
-	headerStr := fmt.Sprintf("blob %d%c", fileInfo.Size(), 0)
-	header := []byte(headerStr)
-	finalData := append(header, data...)
-	hash := sha1.Sum(finalData)
-	hashStr := fmt.Sprintf("%x", hash)
+	var store objectstore.ObjectStore
 	if h.writeObject {
-		prefix := hashStr[:2]
-		filename := hashStr[2:]
-
-		err = os.Mkdir(filepath.Join(objDir, prefix), 0o755)
-		if err != nil {
-			return err
-		}
-		var compressedData bytes.Buffer
-		w := zlib.NewWriter(&compressedData)
-		_, err = w.Write(finalData)
-		if err != nil {
-			return err
-		}
-
-		w.Close()
-		err = os.WriteFile(filepath.Join(objDir, prefix, filename), compressedData.Bytes(), 0o744)
-		if err != nil {
-			return err
+		if h.Repo == nil {
+			return repository.ErrNotARepository
 		}
+		store = h.Repo.Objects
+	}
+	hash, err := worktree.HashBlob(store, &objectstore.Object{Type: packextractor.OBJ_BLOB, Data: data}, h.writeObject)
+	if err != nil {
+		return err
 	}
 
-	fmt.Print(hashStr)
+	fmt.Print(hash)
 
 	return nil
 }
@@ -189,27 +172,48 @@ func (h *HashObject) Usage() string {
 }
 
 type Init struct {
-	Fs *flag.FlagSet
+	Fs        *flag.FlagSet
+	directory string
 }
 
 func (in *Init) Initialize(args []string) error {
+	err := in.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	in.directory = in.Fs.Arg(0)
 	return nil
 }
 
 func (in *Init) Usage() string {
-	return "git init : Initializes an empty git repository in the current directory"
+	return "git init [<directory>] : Initializes an empty git repository in the current directory"
 }
 
 func (in *Init) Run() error {
-	for _, dir := range []string{".git", ".git/objects", ".git/reFs"} {
+	// GIT_DIR decides where the repository goes, like it does for every other command
+	gitDir := os.Getenv("GIT_DIR")
+	if gitDir == "" {
+		gitDir = filepath.Join(in.directory, ".git")
+	}
+	dirs := []string{
+		gitDir,
+		filepath.Join(gitDir, "objects"),
+		filepath.Join(gitDir, "refs", "heads"),
+		filepath.Join(gitDir, "refs", "tags"),
+	}
+	for _, dir := range dirs {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating directory: %s\n", err)
 		}
 	}
 
-	headFileContents := []byte("ref: reFs/heads/main\n")
-	if err := os.WriteFile(".git/HEAD", headFileContents, 0o644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing file: %s\n", err)
+	// initializing an existing repository again keeps the branch it is on
+	headPath := filepath.Join(gitDir, "HEAD")
+	if _, err := os.Stat(headPath); os.IsNotExist(err) {
+		headFileContents := []byte("ref: refs/heads/main\n")
+		if err := os.WriteFile(headPath, headFileContents, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing file: %s\n", err)
+		}
 	}
 
 	fmt.Println("Initialized git directory")
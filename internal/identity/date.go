@@ -0,0 +1,160 @@
+package identity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+)
+
+// dateLayouts are the human readable formats accepted in GIT_*_DATE, the
+// zone is optional and defaults to the local one
+var dateLayouts = []string{
+	time.RFC1123Z,                    // Thu, 07 Apr 2005 22:13:13 +0200
+	"Mon, 2 Jan 2006 15:04:05 -0700", // RFC 2822 without the zero padding
+	"Mon Jan 2 15:04:05 2006 -0700",  // git log's default format
+	time.RFC3339,                     // 2005-04-07T22:13:13+02:00
+	"2006-01-02T15:04:05-0700",       // ISO 8601 with a git style zone
+	"2006-01-02 15:04:05 -0700",      // git log --date=iso
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate accepts git's internal "<unix seconds> <+zone>" (optionally
+// prefixed with @), RFC 2822 and ISO 8601 dates
+func ParseDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+	fields := strings.Fields(strings.TrimPrefix(date, "@"))
+	if len(fields) >= 1 && len(fields) <= 2 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			when := time.Unix(seconds, 0)
+			if len(fields) == 2 {
+				offset, err := object.ParseTimezone(fields[1])
+				if err != nil {
+					return time.Time{}, err
+				}
+				return when.In(time.FixedZone("", offset)), nil
+			}
+			return when, nil
+		}
+	}
+	for _, layout := range dateLayouts {
+		if when, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return when, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid date format: %s", date)
+}
+
+// FormatDate shows the date of a signature in one of git's --date modes:
+// default, iso, iso-strict, rfc, short, raw, unix, local and relative
+func FormatDate(sig object.Signature, mode string) (string, error) {
+	when := sig.When
+	switch mode {
+	case "", "default":
+		return when.Format("Mon Jan 2 15:04:05 2006 ") + sig.Timezone(), nil
+	case "local":
+		return when.Local().Format("Mon Jan 2 15:04:05 2006"), nil
+	case "iso", "iso8601":
+		return when.Format("2006-01-02 15:04:05 ") + sig.Timezone(), nil
+	case "iso-strict", "iso8601-strict":
+		return when.Format("2006-01-02T15:04:05-07:00"), nil
+	case "rfc", "rfc2822":
+		return when.Format("Mon, 2 Jan 2006 15:04:05 ") + sig.Timezone(), nil
+	case "short":
+		return when.Format("2006-01-02"), nil
+	case "raw":
+		return fmt.Sprintf("%d %s", when.Unix(), sig.Timezone()), nil
+	case "unix":
+		return strconv.FormatInt(when.Unix(), 10), nil
+	case "relative":
+		return relativeDate(when, time.Now()), nil
+	}
+	return "", fmt.Errorf("Unknown date format %s", mode)
+}
+
+// relativeDate rounds the time since when the way git does, from seconds up
+// to years
+func relativeDate(when, now time.Time) string {
+	diff := int64(now.Sub(when) / time.Second)
+	if diff < 0 {
+		return "in the future"
+	}
+	ago := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	if diff < 90 {
+		return ago(diff, "second") + " ago"
+	}
+	if diff = (diff + 30) / 60; diff < 90 {
+		return ago(diff, "minute") + " ago"
+	}
+	if diff = (diff + 30) / 60; diff < 36 {
+		return ago(diff, "hour") + " ago"
+	}
+	if diff = (diff + 12) / 24; diff < 14 {
+		return ago(diff, "day") + " ago"
+	}
+	if diff < 70 {
+		return ago((diff+3)/7, "week") + " ago"
+	}
+	if diff < 365 {
+		return ago((diff+15)/30, "month") + " ago"
+	}
+	if diff < 1825 {
+		months := (diff*12*2 + 365) / (365 * 2)
+		if months%12 != 0 {
+			return ago(months/12, "year") + ", " + ago(months%12, "month") + " ago"
+		}
+		return ago(months/12, "year") + " ago"
+	}
+	return ago((diff+183)/365, "year") + " ago"
+}
+
+// approxUnits are the units of relative dates like 2.weeks.ago
+var approxUnits = map[string]func(t time.Time, n int) time.Time{
+	"second": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Second) },
+	"minute": func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Minute) },
+	"hour":   func(t time.Time, n int) time.Time { return t.Add(-time.Duration(n) * time.Hour) },
+	"day":    func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -n) },
+	"week":   func(t time.Time, n int) time.Time { return t.AddDate(0, 0, -7*n) },
+	"month":  func(t time.Time, n int) time.Time { return t.AddDate(0, -n, 0) },
+	"year":   func(t time.Time, n int) time.Time { return t.AddDate(-n, 0, 0) },
+}
+
+// ApproxDate accepts what ParseDate does, "now", "yesterday" and relative
+// dates like "2.weeks.ago", "90 days" or "3 days 4 hours ago", counted back
+// from now
+func ApproxDate(date string, now time.Time) (time.Time, error) {
+	if when, err := ParseDate(date); err == nil {
+		return when, nil
+	}
+	words := strings.Fields(strings.NewReplacer(".", " ", ",", " ").Replace(strings.ToLower(date)))
+	if len(words) > 0 && words[len(words)-1] == "ago" {
+		words = words[:len(words)-1]
+	}
+	switch {
+	case len(words) == 1 && words[0] == "now":
+		return now, nil
+	case len(words) == 1 && words[0] == "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case len(words) == 0 || len(words)%2 != 0:
+		return time.Time{}, fmt.Errorf("Invalid date format: %s", date)
+	}
+	when := now
+	for i := 0; i < len(words); i += 2 {
+		n, err := strconv.Atoi(words[i])
+		subtract, ok := approxUnits[strings.TrimSuffix(words[i+1], "s")]
+		if err != nil || !ok {
+			return time.Time{}, fmt.Errorf("Invalid date format: %s", date)
+		}
+		when = subtract(when, n)
+	}
+	return when, nil
+}
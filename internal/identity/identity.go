@@ -0,0 +1,107 @@
+package identity
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+)
+
+// Author is who wrote a change: GIT_AUTHOR_NAME/EMAIL/DATE, then author.* and
+// user.* from config, then what the system knows about the user
+func Author(cfg *config.Config) (object.Signature, error) {
+	return lookup(cfg, "AUTHOR", "author")
+}
+
+// Committer is who recorded the change, looked up like Author with the
+// GIT_COMMITTER_* variables and committer.* config
+func Committer(cfg *config.Config) (object.Signature, error) {
+	return lookup(cfg, "COMMITTER", "committer")
+}
+
+func lookup(cfg *config.Config, envRole, configRole string) (object.Signature, error) {
+	name := firstSet(os.Getenv("GIT_"+envRole+"_NAME"), get(cfg, configRole+".name"), get(cfg, "user.name"))
+	email := firstSet(os.Getenv("GIT_"+envRole+"_EMAIL"), get(cfg, configRole+".email"), get(cfg, "user.email"), os.Getenv("EMAIL"))
+
+	if name == "" || email == "" {
+		if onlyConfig, _ := getBool(cfg, "user.useconfigonly"); onlyConfig {
+			return object.Signature{}, fmt.Errorf("%s%s identity unknown, set user.name and user.email", strings.ToUpper(configRole[:1]), configRole[1:])
+		}
+		systemName, systemEmail := systemIdentity()
+		name = firstSet(name, systemName)
+		email = firstSet(email, systemEmail)
+	}
+	if name == "" {
+		return object.Signature{}, fmt.Errorf("Empty ident name not allowed for %s", configRole)
+	}
+
+	when := time.Now()
+	if date := os.Getenv("GIT_" + envRole + "_DATE"); date != "" {
+		var err error
+		when, err = ParseDate(date)
+		if err != nil {
+			return object.Signature{}, err
+		}
+	}
+	return object.NewSignature(sanitize(name), sanitize(email), when), nil
+}
+
+// systemIdentity is git's fallback: the login name and <user>@<hostname>
+func systemIdentity() (string, string) {
+	u, err := user.Current()
+	if err != nil {
+		return "", ""
+	}
+	name := u.Name
+	if i := strings.IndexByte(name, ','); i >= 0 {
+		name = name[:i] // the GECOS field may carry more than the name
+	}
+	if name == "" {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return name, u.Username + "@" + host
+}
+
+// sanitize drops the characters that would break the "Name <email>" syntax
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '<', '>', '\n':
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+func get(cfg *config.Config, key string) string {
+	if cfg == nil {
+		return ""
+	}
+	value, _ := cfg.Get(key)
+	return value
+}
+
+func getBool(cfg *config.Config, key string) (bool, error) {
+	if cfg == nil {
+		return false, nil
+	}
+	return cfg.GetBool(key, false)
+}
+
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
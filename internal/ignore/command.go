@@ -0,0 +1,129 @@
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type CheckIgnore struct {
+	Fs          *flag.FlagSet
+	Repo        *repository.Repository
+	verbose     bool // show the pattern that matched
+	nonMatching bool // with -v, also list the paths that matched nothing
+	noIndex     bool // do not treat tracked files as not ignored
+	stdin       bool
+	quiet       bool
+	paths       []string
+}
+
+func (c *CheckIgnore) Initialize(args []string) error {
+	c.Fs.BoolVar(&c.verbose, "v", false, "Show the exclude pattern matching each path")
+	c.Fs.BoolVar(&c.verbose, "verbose", false, "Same as -v")
+	c.Fs.BoolVar(&c.nonMatching, "n", false, "Also show the paths that match no pattern, needs -v")
+	c.Fs.BoolVar(&c.nonMatching, "non-matching", false, "Same as -n")
+	c.Fs.BoolVar(&c.noIndex, "no-index", false, "Do not look in the index, tracked files may be reported too")
+	c.Fs.BoolVar(&c.stdin, "stdin", false, "Read the paths from stdin, one per line")
+	c.Fs.BoolVar(&c.quiet, "q", false, "Only set the exit status")
+	c.Fs.BoolVar(&c.quiet, "quiet", false, "Same as -q")
+	err := c.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	c.paths = c.Fs.Args()
+	switch {
+	case c.stdin && len(c.paths) > 0:
+		return errors.New("Cannot specify pathnames with --stdin")
+	case !c.stdin && len(c.paths) == 0:
+		return errors.New("No path specified")
+	case c.nonMatching && !c.verbose:
+		return errors.New("--non-matching is only valid with --verbose")
+	case c.quiet && c.verbose:
+		return errors.New("Cannot have both --quiet and --verbose")
+	case c.quiet && len(c.paths) > 1:
+		return errors.New("--quiet is only valid with a single pathname")
+	}
+	return nil
+}
+
+func (c *CheckIgnore) Usage() string {
+	return "git check-ignore [-v [-n]] [-q] [--no-index] (--stdin | <pathname>...) : Debug gitignore / exclude files"
+}
+
+func (c *CheckIgnore) Run() error {
+	if err := c.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	cfg, err := c.Repo.Config()
+	if err != nil {
+		return err
+	}
+	matcher := NewMatcher(c.Repo.WorkTree, c.Repo.CommonDir, cfg)
+	idx, err := c.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+
+	if c.stdin {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			c.paths = append(c.paths, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	anyIgnored := false
+	for _, arg := range c.paths {
+		name, isDir, err := c.resolve(arg)
+		if err != nil {
+			return err
+		}
+		var p *Pattern
+		// tracked files are never ignored
+		if c.noIndex || len(idx.Stages(name)) == 0 {
+			p = matcher.Match(name, isDir)
+		}
+		if p != nil && !p.negate {
+			anyIgnored = true
+		}
+		switch {
+		case c.quiet:
+		case p != nil && c.verbose:
+			fmt.Printf("%s:%d:%s\t%s\n", p.Source, p.Line, p.Text, arg)
+		case p != nil && !p.negate:
+			fmt.Println(arg)
+		case p == nil && c.nonMatching:
+			fmt.Printf("::\t%s\n", arg)
+		}
+	}
+	if !anyIgnored {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// resolve turns a path given relative to the current directory into a slash
+// separated path from the top of the work tree
+func (c *CheckIgnore) resolve(arg string) (string, bool, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return "", false, err
+	}
+	rel, err := filepath.Rel(c.Repo.WorkTree, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("%s: '%s' is outside repository at '%s'", arg, arg, c.Repo.WorkTree)
+	}
+	isDir := strings.HasSuffix(arg, "/")
+	if fi, err := os.Lstat(abs); err == nil && fi.IsDir() {
+		isDir = true
+	}
+	return filepath.ToSlash(rel), isDir, nil
+}
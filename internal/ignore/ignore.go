@@ -0,0 +1,211 @@
+package ignore
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+)
+
+// Pattern is one line of a .gitignore style file
+type Pattern struct {
+	Source string // the file the pattern comes from, as check-ignore shows it
+	Line   int
+	Text   string // the line as written, trailing spaces removed
+
+	pattern  string
+	negate   bool   // "!pattern" re-includes what an earlier pattern excluded
+	dirOnly  bool   // "pattern/" only matches directories
+	anchored bool   // a slash anywhere but the end ties the pattern to base
+	base     string // directory of the file the pattern came from, "" for the top
+}
+
+// ParsePatterns reads the lines of an ignore file, patterns are relative to
+// base, the slash separated directory the file lives in
+func ParsePatterns(data []byte, base, source string) []*Pattern {
+	var patterns []*Pattern
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if p := parsePattern(string(line), base); p != nil {
+			p.Source = source
+			p.Line = i + 1
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func parsePattern(line, base string) *Pattern {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+	p := &Pattern{Text: line, base: base}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return nil
+	}
+	p.pattern = line
+	return p
+}
+
+// trimTrailingSpaces drops trailing spaces unless they are escaped with a backslash
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		if end > 1 && line[end-2] == '\\' {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// Negated reports whether the pattern re-includes the paths it matches
+func (p *Pattern) Negated() bool {
+	return p.negate
+}
+
+// Match reports whether the slash separated path, relative to the top of the
+// work tree, is matched by the pattern. A pattern without a slash is matched
+// against the last component of the path, at any depth below its base
+func (p *Pattern) Match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		rest, ok := strings.CutPrefix(name, p.base+"/")
+		if !ok {
+			return false
+		}
+		name = rest
+	}
+	if !p.anchored {
+		name = path.Base(name)
+	}
+	return Wildmatch(p.pattern, name)
+}
+
+// Matcher decides which paths of a work tree are ignored. Patterns are read
+// from core.excludesFile, .git/info/exclude and every .gitignore, a later
+// source and a deeper .gitignore take precedence
+type Matcher struct {
+	workTree string
+	global   []*Pattern
+	dirs     map[string][]*Pattern // .gitignore patterns by directory, loaded on demand
+}
+
+func NewMatcher(workTree, gitDir string, cfg *config.Config) *Matcher {
+	m := &Matcher{workTree: workTree, dirs: make(map[string][]*Pattern)}
+	if excludes, source := excludesFile(cfg); excludes != "" {
+		m.global = append(m.global, readPatterns(excludes, "", source)...)
+	}
+	exclude := filepath.Join(gitDir, "info", "exclude")
+	source := exclude
+	if rel, err := filepath.Rel(workTree, exclude); err == nil && !strings.HasPrefix(rel, "..") {
+		source = filepath.ToSlash(rel)
+	}
+	m.global = append(m.global, readPatterns(exclude, "", source)...)
+	return m
+}
+
+// excludesFile is core.excludesFile, or $XDG_CONFIG_HOME/git/ignore by
+// default. It returns the path to read and the path as configured
+func excludesFile(cfg *config.Config) (string, string) {
+	if cfg != nil {
+		if path, ok := cfg.Get("core.excludesfile"); ok {
+			return config.ExpandHome(path), path
+		}
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		xdg = filepath.Join(home, ".config")
+	}
+	path := filepath.Join(xdg, "git", "ignore")
+	return path, path
+}
+
+func readPatterns(file, base, source string) []*Pattern {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	return ParsePatterns(data, base, source)
+}
+
+func (m *Matcher) dirPatterns(dir string) []*Pattern {
+	patterns, ok := m.dirs[dir]
+	if !ok {
+		source := path.Join(dir, ".gitignore")
+		patterns = readPatterns(filepath.Join(m.workTree, filepath.FromSlash(source)), dir, source)
+		m.dirs[dir] = patterns
+	}
+	return patterns
+}
+
+// Ignored reports whether the slash separated path is ignored
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+	p := m.Match(name, isDir)
+	return p != nil && !p.negate
+}
+
+// Match returns the pattern that decides whether the slash separated path is
+// ignored, nil when none matches. A directory that is ignored decides for
+// everything inside it, since git never looks into it to find re-included
+// files. The result is a negated pattern when the path is explicitly not ignored
+func (m *Matcher) Match(name string, isDir bool) *Pattern {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if p := m.match(strings.Join(parts[:i], "/"), true); p != nil && !p.negate {
+			return p
+		}
+	}
+	return m.match(name, isDir)
+}
+
+// match checks the path alone. The deepest .gitignore with a matching pattern
+// decides, then .git/info/exclude and core.excludesFile, and within a file the
+// last matching pattern wins
+func (m *Matcher) match(name string, isDir bool) *Pattern {
+	dir := path.Dir(name)
+	for {
+		if dir == "." {
+			dir = ""
+		}
+		if p := lastMatch(m.dirPatterns(dir), name, isDir); p != nil {
+			return p
+		}
+		if dir == "" {
+			break
+		}
+		dir = path.Dir(dir)
+	}
+	return lastMatch(m.global, name, isDir)
+}
+
+func lastMatch(patterns []*Pattern, name string, isDir bool) *Pattern {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i].Match(name, isDir) {
+			return patterns[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package ignore
+
+import "strings"
+
+// results of wildmatch, the aborts let callers stop trying further offsets
+// for a "*" once the rest of the pattern can no longer match
+const (
+	wmMatch = iota
+	wmNoMatch
+	wmAbortAll
+	wmAbortToStarStar
+)
+
+// Wildmatch matches text against a glob the way gitignore patterns are
+// matched: "*", "?" and bracket expressions never match a slash, while "**"
+// between slashes (or at either end) matches any number of directories
+func Wildmatch(pattern, text string) bool {
+	return dowild(pattern, text) == wmMatch
+}
+
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func dowild(pattern, text string) int {
+	p, t := 0, 0
+	for ; p < len(pattern); p, t = p+1, t+1 {
+		pCh := pattern[p]
+		tCh := at(text, t)
+		if t >= len(text) && pCh != '*' {
+			return wmAbortAll
+		}
+		switch pCh {
+		case '\\':
+			// the next character is literal
+			p++
+			if at(pattern, p) != tCh {
+				return wmNoMatch
+			}
+		case '?':
+			if tCh == '/' {
+				return wmNoMatch
+			}
+		case '*':
+			matchSlash := false
+			p++
+			if at(pattern, p) == '*' {
+				prev := p - 2
+				for at(pattern, p) == '*' {
+					p++
+				}
+				next := at(pattern, p)
+				if (prev < 0 || pattern[prev] == '/') && (next == 0 || next == '/' || next == '\\' && at(pattern, p+1) == '/') {
+					// "**/" may match no directory at all: foo/**/bar matches foo/bar
+					if next == '/' && dowild(pattern[p+1:], text[t:]) == wmMatch {
+						return wmMatch
+					}
+					matchSlash = true
+				}
+			}
+			if p >= len(pattern) {
+				// a trailing "**" matches everything, a "*" only up to the next slash
+				if !matchSlash && strings.IndexByte(text[t:], '/') >= 0 {
+					return wmNoMatch
+				}
+				return wmMatch
+			}
+			if !matchSlash && pattern[p] == '/' {
+				// "*/" consumes exactly one directory
+				slash := strings.IndexByte(text[t:], '/')
+				if slash < 0 {
+					return wmNoMatch
+				}
+				t += slash
+				continue
+			}
+			for t < len(text) {
+				// skip ahead to the literal that follows the star
+				if c := pattern[p]; !isGlobSpecial(c) {
+					for t < len(text) && (matchSlash || text[t] != '/') && text[t] != c {
+						t++
+					}
+					if at(text, t) != c {
+						return wmNoMatch
+					}
+				}
+				matched := dowild(pattern[p:], text[t:])
+				if matched != wmNoMatch {
+					if !matchSlash || matched != wmAbortToStarStar {
+						return matched
+					}
+				} else if !matchSlash && text[t] == '/' {
+					return wmAbortToStarStar
+				}
+				t++
+			}
+			return wmAbortAll
+		case '[':
+			end, matched, ok := matchBracket(pattern, p, tCh)
+			if !ok {
+				return wmAbortAll
+			}
+			if !matched || tCh == '/' {
+				return wmNoMatch
+			}
+			p = end
+		default:
+			if pCh != tCh {
+				return wmNoMatch
+			}
+		}
+	}
+	if t < len(text) {
+		return wmNoMatch
+	}
+	return wmMatch
+}
+
+func isGlobSpecial(c byte) bool {
+	return c == '*' || c == '?' || c == '[' || c == '\\'
+}
+
+// matchBracket matches c against the bracket expression starting at
+// pattern[start] and returns the index of its closing bracket. ok is false
+// when the expression is not terminated
+func matchBracket(pattern string, start int, c byte) (end int, matched bool, ok bool) {
+	p := start + 1
+	negated := false
+	if ch := at(pattern, p); ch == '!' || ch == '^' {
+		negated = true
+		p++
+	}
+	var prev byte
+	for first := true; first || at(pattern, p) != ']'; first = false {
+		ch := at(pattern, p)
+		switch {
+		case ch == 0:
+			return 0, false, false
+		case ch == '\\':
+			p++
+			ch = at(pattern, p)
+			if ch == 0 {
+				return 0, false, false
+			}
+			if c == ch {
+				matched = true
+			}
+		case ch == '-' && prev != 0 && at(pattern, p+1) != 0 && at(pattern, p+1) != ']':
+			p++
+			hi := pattern[p]
+			if hi == '\\' {
+				p++
+				hi = at(pattern, p)
+				if hi == 0 {
+					return 0, false, false
+				}
+			}
+			if c >= prev && c <= hi {
+				matched = true
+			}
+			ch = 0 // a range cannot start another one
+		case ch == '[' && at(pattern, p+1) == ':':
+			closing := strings.IndexByte(pattern[p+2:], ']')
+			if closing < 0 {
+				return 0, false, false
+			}
+			end := p + 2 + closing
+			if closing < 1 || pattern[end-1] != ':' {
+				// no ":]", the '[' is an ordinary character
+				if c == '[' {
+					matched = true
+				}
+				break
+			}
+			in, known := inClass(pattern[p+2:end-1], c)
+			if !known {
+				return 0, false, false
+			}
+			if in {
+				matched = true
+			}
+			p = end
+			ch = 0
+		default:
+			if c == ch {
+				matched = true
+			}
+		}
+		prev = ch
+		p++
+	}
+	return p, matched != negated, true
+}
+
+// inClass implements the POSIX classes allowed in bracket expressions
+func inClass(class string, c byte) (in bool, known bool) {
+	isUpper := c >= 'A' && c <= 'Z'
+	isLower := c >= 'a' && c <= 'z'
+	isDigit := c >= '0' && c <= '9'
+	isPrint := c >= 0x20 && c < 0x7f
+	switch class {
+	case "alnum":
+		return isUpper || isLower || isDigit, true
+	case "alpha":
+		return isUpper || isLower, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return isPrint && c != ' ', true
+	case "lower":
+		return isLower, true
+	case "print":
+		return isPrint, true
+	case "punct":
+		return isPrint && c != ' ' && !isUpper && !isLower && !isDigit, true
+	case "space":
+		return c == ' ' || c >= '\t' && c <= '\r', true
+	case "upper":
+		return isUpper, true
+	case "xdigit":
+		return isDigit || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F', true
+	}
+	return false, false
+}
@@ -0,0 +1,102 @@
+package index
+
+import (
+	"os"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+// Entry flags as stored on disk, the name length takes the low 12 bits
+const (
+	flagAssumeValid = 0x8000
+	flagExtended    = 0x4000
+	flagStageMask   = 0x3000
+	flagStageShift  = 12
+	flagNameMask    = 0x0fff
+
+	// extended flags, version 3 and later
+	flagSkipWorktree = 0x4000
+	flagIntentToAdd  = 0x2000
+)
+
+// Stages of an unmerged path, a merged path only has StageMerged
+const (
+	StageMerged = 0
+	StageBase   = 1
+	StageOurs   = 2
+	StageTheirs = 3
+)
+
+// Entry is one path of the index with the stat data git uses to tell whether
+// the file in the work tree may have changed since it was staged
+type Entry struct {
+	CTime time.Time
+	MTime time.Time
+	Dev   uint32
+	Ino   uint32
+	Mode  object.FileMode
+	UID   uint32
+	GID   uint32
+	Size  uint32 // truncated to 32 bits, like git
+	Hash  objectstore.Hash
+	Stage int
+
+	AssumeValid  bool
+	SkipWorktree bool
+	IntentToAdd  bool
+
+	Path string // slash separated and relative to the top of the work tree
+}
+
+func (e *Entry) extended() bool {
+	return e.SkipWorktree || e.IntentToAdd
+}
+
+// SetStat records the stat data of the file in the work tree
+func (e *Entry) SetStat(fi os.FileInfo) {
+	e.MTime = fi.ModTime()
+	e.CTime = e.MTime
+	e.Size = uint32(fi.Size())
+	e.Dev, e.Ino, e.UID, e.GID = 0, 0, 0, 0
+	fillStat(e, fi)
+}
+
+// NewEntry stages the file at path in the work tree as hash
+func NewEntry(path string, hash objectstore.Hash, fi os.FileInfo) *Entry {
+	e := &Entry{Path: path, Hash: hash, Mode: ModeFromFileInfo(fi)}
+	e.SetStat(fi)
+	return e
+}
+
+// ModeFromFileInfo is the mode git stages a file with, only the owner
+// executable bit is kept for regular files
+func ModeFromFileInfo(fi os.FileInfo) object.FileMode {
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return object.ModeSymlink
+	case fi.IsDir():
+		return object.ModeGitlink
+	case fi.Mode()&0o100 != 0:
+		return object.ModeExecutable
+	}
+	return object.ModeBlob
+}
+
+// Changed reports whether the stat data no longer matches the file, in which
+// case the content has to be hashed again to know if it was modified
+func (e *Entry) Changed(fi os.FileInfo) bool {
+	if e.Mode != ModeFromFileInfo(fi) && e.Mode != object.ModeGitlink {
+		return true
+	}
+	if !e.MTime.Equal(fi.ModTime()) || e.Size != uint32(fi.Size()) {
+		return true
+	}
+	other := Entry{}
+	fillStat(&other, fi)
+	if e.Ino != 0 && other.Ino != 0 && e.Ino != other.Ino {
+		return true
+	}
+	return !e.CTime.IsZero() && !other.CTime.IsZero() && !e.CTime.Equal(other.CTime)
+}
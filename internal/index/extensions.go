@@ -0,0 +1,190 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+// CacheTree is the TREE extension: the tree object each directory of the index
+// was last written as, so write-tree only has to hash what changed. Each node
+// is stored as
+//
+//	<name>\0<entry count> <number of subtrees>\n<20 byte hash>
+//
+// followed by its subtrees. An entry count of -1 marks a node as invalid, it
+// then has no hash
+type CacheTree struct {
+	Name       string // the directory name, empty for the root
+	EntryCount int    // index entries covered by this tree, -1 when invalid
+	Hash       objectstore.Hash
+	Children   []*CacheTree
+}
+
+func (t *CacheTree) Valid() bool {
+	return t.EntryCount >= 0
+}
+
+// Invalidate marks every tree containing path as out of date
+func (t *CacheTree) Invalidate(path string) {
+	t.EntryCount = -1
+	slash := strings.IndexByte(path, '/')
+	if slash < 0 {
+		return
+	}
+	if child := t.child(path[:slash]); child != nil {
+		child.Invalidate(path[slash+1:])
+	}
+}
+
+// Find returns the node for the slash separated directory, "" is the root
+func (t *CacheTree) Find(dir string) *CacheTree {
+	node := t
+	if dir == "" {
+		return node
+	}
+	for _, name := range strings.Split(dir, "/") {
+		if node = node.child(name); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func (t *CacheTree) child(name string) *CacheTree {
+	for _, c := range t.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func parseCacheTree(data []byte) (*CacheTree, error) {
+	t, rest, err := parseCacheTreeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("Trailing data after the TREE extension")
+	}
+	return t, nil
+}
+
+func parseCacheTreeNode(data []byte) (*CacheTree, []byte, error) {
+	nameEnd := bytes.IndexByte(data, 0)
+	lineEnd := bytes.IndexByte(data, '\n')
+	if nameEnd < 0 || lineEnd < nameEnd {
+		return nil, nil, errors.New("Malformed TREE extension")
+	}
+	counts := strings.Fields(string(data[nameEnd+1 : lineEnd]))
+	if len(counts) != 2 {
+		return nil, nil, errors.New("Malformed TREE extension")
+	}
+	entryCount, err1 := strconv.Atoi(counts[0])
+	subtrees, err2 := strconv.Atoi(counts[1])
+	if err1 != nil || err2 != nil || subtrees < 0 {
+		return nil, nil, errors.New("Malformed TREE extension")
+	}
+	t := &CacheTree{Name: string(data[:nameEnd]), EntryCount: entryCount}
+	data = data[lineEnd+1:]
+	if t.Valid() {
+		if len(data) < len(t.Hash) {
+			return nil, nil, errors.New("TREE extension is truncated")
+		}
+		copy(t.Hash[:], data)
+		data = data[len(t.Hash):]
+	}
+	for i := 0; i < subtrees; i++ {
+		var child *CacheTree
+		var err error
+		child, data, err = parseCacheTreeNode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Children = append(t.Children, child)
+	}
+	return t, data, nil
+}
+
+func (t *CacheTree) encode(buf []byte) []byte {
+	buf = append(buf, t.Name...)
+	buf = append(buf, 0)
+	buf = append(buf, fmt.Sprintf("%d %d\n", t.EntryCount, len(t.Children))...)
+	if t.Valid() {
+		buf = append(buf, t.Hash[:]...)
+	}
+	for _, c := range t.Children {
+		buf = c.encode(buf)
+	}
+	return buf
+}
+
+// ResolveUndo is a REUC record: the stages a conflicted path had before it
+// was resolved, a zero mode means the stage was missing
+type ResolveUndo struct {
+	Path   string
+	Modes  [3]object.FileMode // base, ours, theirs
+	Hashes [3]objectstore.Hash
+}
+
+// a record is "<path>\0" then three octal modes each ending in \0, then the
+// hashes of the stages whose mode is not zero
+func parseResolveUndo(data []byte) ([]ResolveUndo, error) {
+	var records []ResolveUndo
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, errors.New("Malformed REUC extension")
+		}
+		ru := ResolveUndo{Path: string(data[:end])}
+		data = data[end+1:]
+		for i := range ru.Modes {
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				return nil, errors.New("Malformed REUC extension")
+			}
+			mode, err := strconv.ParseUint(string(data[:end]), 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed mode in REUC extension: %v", err)
+			}
+			ru.Modes[i] = object.FileMode(mode)
+			data = data[end+1:]
+		}
+		for i, mode := range ru.Modes {
+			if mode == 0 {
+				continue
+			}
+			if len(data) < len(ru.Hashes[i]) {
+				return nil, errors.New("REUC extension is truncated")
+			}
+			copy(ru.Hashes[i][:], data)
+			data = data[len(ru.Hashes[i]):]
+		}
+		records = append(records, ru)
+	}
+	return records, nil
+}
+
+func encodeResolveUndo(records []ResolveUndo) []byte {
+	var buf []byte
+	for _, ru := range records {
+		buf = append(buf, ru.Path...)
+		buf = append(buf, 0)
+		for _, mode := range ru.Modes {
+			buf = strconv.AppendUint(buf, uint64(mode), 8)
+			buf = append(buf, 0)
+		}
+		for i, mode := range ru.Modes {
+			if mode != 0 {
+				buf = append(buf, ru.Hashes[i][:]...)
+			}
+		}
+	}
+	return buf
+}
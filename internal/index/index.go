@@ -0,0 +1,524 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+)
+
+// Index is the staging area kept in .git/index. On disk it is
+//
+//	"DIRC", version, number of entries
+//	entries sorted by path and stage
+//	extensions: 4 byte signature, 4 byte size, data
+//	sha1 of everything above
+type Index struct {
+	Version uint32
+	Entries []*Entry
+
+	// Cache is the TREE extension, nil when the index has none
+	Cache *CacheTree
+	// ResolveUndo is the REUC extension, the stages of conflicts that were resolved
+	ResolveUndo []ResolveUndo
+
+	// ModTime is when the file was last written, entries modified in that same
+	// instant are racy and have to be compared by content
+	ModTime time.Time
+
+	extensions []rawExtension // optional extensions we do not understand
+}
+
+type rawExtension struct {
+	signature string
+	data      []byte
+}
+
+const (
+	entryHeaderSize = 62 // stat data, hash and flags
+	defaultVersion  = 2
+)
+
+var indexMagic = []byte("DIRC")
+
+// staleExtensions describe the layout or state of the file they were read
+// from, they would be wrong after a rewrite so they are dropped
+var staleExtensions = map[string]bool{
+	"EOIE": true, // end of index entries
+	"IEOT": true, // index entry offset table
+	"UNTR": true, // untracked cache
+	"FSMN": true, // fsmonitor
+}
+
+func New() *Index {
+	return &Index{Version: defaultVersion}
+}
+
+// Read loads the index at path, a missing file is an empty index
+func Read(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Index file %s is corrupt: %v", path, err)
+	}
+	if fi, err := os.Stat(path); err == nil {
+		idx.ModTime = fi.ModTime()
+	}
+	return idx, nil
+}
+
+func Parse(data []byte) (*Index, error) {
+	if len(data) < 12+sha1.Size {
+		return nil, errors.New("Index is truncated")
+	}
+	body, trailer := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	// an all zero trailer is written when index.skipHash is set
+	checksum := sha1.Sum(body)
+	if !bytes.Equal(trailer, make([]byte, sha1.Size)) && !bytes.Equal(checksum[:], trailer) {
+		return nil, errors.New("Index checksum does not match its content")
+	}
+	if !bytes.HasPrefix(body, indexMagic) {
+		return nil, errors.New("Index signature is not DIRC")
+	}
+	idx := &Index{Version: binary.BigEndian.Uint32(body[4:8])}
+	if idx.Version < 2 || idx.Version > 4 {
+		return nil, fmt.Errorf("Unsupported index version %d", idx.Version)
+	}
+	count := int(binary.BigEndian.Uint32(body[8:12]))
+
+	pos := 12
+	previous := ""
+	for i := 0; i < count; i++ {
+		entry, next, err := idx.parseEntry(body, pos, previous)
+		if err != nil {
+			return nil, fmt.Errorf("Entry %d: %v", i, err)
+		}
+		idx.Entries = append(idx.Entries, entry)
+		previous = entry.Path
+		pos = next
+	}
+
+	for pos < len(body) {
+		if pos+8 > len(body) {
+			return nil, errors.New("Index extension header is truncated")
+		}
+		signature := string(body[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		pos += 8
+		if size > len(body)-pos {
+			return nil, fmt.Errorf("Index extension %s is truncated", signature)
+		}
+		ext := body[pos : pos+size]
+		pos += size
+
+		var err error
+		switch {
+		case signature == "TREE":
+			idx.Cache, err = parseCacheTree(ext)
+		case signature == "REUC":
+			idx.ResolveUndo, err = parseResolveUndo(ext)
+		case signature[0] >= 'A' && signature[0] <= 'Z':
+			if !staleExtensions[signature] {
+				idx.extensions = append(idx.extensions, rawExtension{signature, ext})
+			}
+		default:
+			// lower case extensions change how the entries must be read
+			err = fmt.Errorf("Unsupported mandatory extension %q", signature)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+func (idx *Index) parseEntry(data []byte, pos int, previous string) (*Entry, int, error) {
+	start := pos
+	if pos+entryHeaderSize > len(data) {
+		return nil, 0, errors.New("Entry is truncated")
+	}
+	field := func(i int) uint32 {
+		return binary.BigEndian.Uint32(data[pos+i*4:])
+	}
+	e := &Entry{
+		CTime: time.Unix(int64(field(0)), int64(field(1))),
+		MTime: time.Unix(int64(field(2)), int64(field(3))),
+		Dev:   field(4),
+		Ino:   field(5),
+		Mode:  object.FileMode(field(6)),
+		UID:   field(7),
+		GID:   field(8),
+		Size:  field(9),
+	}
+	copy(e.Hash[:], data[pos+40:pos+60])
+	flags := binary.BigEndian.Uint16(data[pos+60:])
+	pos += entryHeaderSize
+	e.AssumeValid = flags&flagAssumeValid != 0
+	e.Stage = int(flags&flagStageMask) >> flagStageShift
+
+	if flags&flagExtended != 0 {
+		if idx.Version < 3 {
+			return nil, 0, errors.New("Extended flags need index version 3")
+		}
+		if pos+2 > len(data) {
+			return nil, 0, errors.New("Entry is truncated")
+		}
+		extended := binary.BigEndian.Uint16(data[pos:])
+		pos += 2
+		e.SkipWorktree = extended&flagSkipWorktree != 0
+		e.IntentToAdd = extended&flagIntentToAdd != 0
+	}
+
+	if idx.Version == 4 {
+		// the path is the previous one minus strip bytes, plus a suffix
+		strip, n := readVarint(data[pos:])
+		if n == 0 || strip > uint64(len(previous)) {
+			return nil, 0, errors.New("Invalid path prefix compression")
+		}
+		pos += n
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return nil, 0, errors.New("Path is not terminated")
+		}
+		e.Path = previous[:len(previous)-int(strip)] + string(data[pos:pos+end])
+		return e, pos + end + 1, nil
+	}
+
+	end := bytes.IndexByte(data[pos:], 0)
+	if end < 0 {
+		return nil, 0, errors.New("Path is not terminated")
+	}
+	e.Path = string(data[pos : pos+end])
+	if nameLen := int(flags & flagNameMask); nameLen != flagNameMask && nameLen != len(e.Path) {
+		return nil, 0, fmt.Errorf("Path %q does not match its length %d", e.Path, nameLen)
+	}
+	// entries are NUL padded to a multiple of 8 bytes, with at least one NUL
+	next := start + paddedSize(pos-start+end)
+	if next > len(data) {
+		return nil, 0, errors.New("Entry is truncated")
+	}
+	return e, next, nil
+}
+
+func paddedSize(size int) int {
+	return (size + 8) &^ 7
+}
+
+// Encode serializes the index with its checksum, entries are written in
+// canonical order
+func (idx *Index) Encode() ([]byte, error) {
+	idx.Sort()
+	version := idx.Version
+	if version == 0 {
+		version = defaultVersion
+	}
+	for _, e := range idx.Entries {
+		if e.extended() && version < 3 {
+			version = 3
+		}
+	}
+	idx.Version = version
+
+	var buf bytes.Buffer
+	buf.Write(indexMagic)
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	previous := ""
+	for _, e := range idx.Entries {
+		if e.Path == "" || strings.IndexByte(e.Path, 0) >= 0 {
+			return nil, fmt.Errorf("Invalid path %q in index", e.Path)
+		}
+		start := buf.Len()
+		for _, t := range []time.Time{e.CTime, e.MTime} {
+			var sec, nsec uint32
+			if !t.IsZero() {
+				sec, nsec = uint32(t.Unix()), uint32(t.Nanosecond())
+			}
+			binary.Write(&buf, binary.BigEndian, [2]uint32{sec, nsec})
+		}
+		binary.Write(&buf, binary.BigEndian, [6]uint32{e.Dev, e.Ino, uint32(e.Mode), e.UID, e.GID, e.Size})
+		buf.Write(e.Hash[:])
+
+		flags := uint16(e.Stage<<flagStageShift) & flagStageMask
+		if len(e.Path) < flagNameMask {
+			flags |= uint16(len(e.Path))
+		} else {
+			flags |= flagNameMask
+		}
+		if e.AssumeValid {
+			flags |= flagAssumeValid
+		}
+		if e.extended() {
+			flags |= flagExtended
+		}
+		binary.Write(&buf, binary.BigEndian, flags)
+		if e.extended() {
+			var extended uint16
+			if e.SkipWorktree {
+				extended |= flagSkipWorktree
+			}
+			if e.IntentToAdd {
+				extended |= flagIntentToAdd
+			}
+			binary.Write(&buf, binary.BigEndian, extended)
+		}
+
+		if version == 4 {
+			common := commonPrefix(previous, e.Path)
+			buf.Write(appendVarint(nil, uint64(len(previous)-common)))
+			buf.WriteString(e.Path[common:])
+			buf.WriteByte(0)
+			previous = e.Path
+			continue
+		}
+		buf.WriteString(e.Path)
+		size := buf.Len() - start
+		buf.Write(make([]byte, paddedSize(size)-size))
+	}
+
+	if idx.Cache != nil {
+		writeExtension(&buf, "TREE", idx.Cache.encode(nil))
+	}
+	if len(idx.ResolveUndo) > 0 {
+		writeExtension(&buf, "REUC", encodeResolveUndo(idx.ResolveUndo))
+	}
+	for _, ext := range idx.extensions {
+		writeExtension(&buf, ext.signature, ext.data)
+	}
+
+	checksum := sha1.Sum(buf.Bytes())
+	buf.Write(checksum[:])
+	return buf.Bytes(), nil
+}
+
+func writeExtension(buf *bytes.Buffer, signature string, data []byte) {
+	buf.WriteString(signature)
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Write saves the index through path.lock so readers never see a partial file
+func (idx *Index) Write(path string) error {
+	data, err := idx.Encode()
+	if err != nil {
+		return err
+	}
+	lock := path + ".lock"
+	lockFile, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if os.IsExist(err) {
+		return fmt.Errorf("Unable to create '%s': File exists. Another git process seems to be running", lock)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = lockFile.Write(data)
+	if closeErr := lockFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lock)
+		return err
+	}
+	if err := os.Rename(lock, path); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		idx.ModTime = fi.ModTime()
+	}
+	return nil
+}
+
+// IsRacy reports whether the entry was modified too close to the index write
+// for its stat data to be trusted, a later change could keep the same size and
+// timestamp so its content has to be compared instead
+func (idx *Index) IsRacy(e *Entry) bool {
+	return !idx.ModTime.IsZero() && !e.MTime.Before(idx.ModTime)
+}
+
+func compareEntries(a *Entry, path string, stage int) int {
+	if c := strings.Compare(a.Path, path); c != 0 {
+		return c
+	}
+	return a.Stage - stage
+}
+
+// Sort puts entries in the order git requires: by path bytes, then stage
+func (idx *Index) Sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		return compareEntries(idx.Entries[i], idx.Entries[j].Path, idx.Entries[j].Stage) < 0
+	})
+}
+
+// search returns where path at stage is, or would be inserted
+func (idx *Index) search(path string, stage int) (int, bool) {
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return compareEntries(idx.Entries[i], path, stage) >= 0
+	})
+	return i, i < len(idx.Entries) && idx.Entries[i].Path == path && idx.Entries[i].Stage == stage
+}
+
+// Entry returns the merged entry for path, or nil
+func (idx *Index) Entry(path string) *Entry {
+	if i, ok := idx.search(path, StageMerged); ok {
+		return idx.Entries[i]
+	}
+	return nil
+}
+
+// Stages returns every entry for path, a single one unless it is unmerged
+func (idx *Index) Stages(path string) []*Entry {
+	i, _ := idx.search(path, StageMerged)
+	var entries []*Entry
+	for ; i < len(idx.Entries) && idx.Entries[i].Path == path; i++ {
+		entries = append(entries, idx.Entries[i])
+	}
+	return entries
+}
+
+// Unmerged returns the paths that still have conflict stages
+func (idx *Index) Unmerged() []string {
+	var paths []string
+	for _, e := range idx.Entries {
+		if e.Stage != StageMerged && (len(paths) == 0 || paths[len(paths)-1] != e.Path) {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// Add stages e as merged. Conflict stages of the path are recorded in
+// ResolveUndo, and a file where e needs a directory (or the files under a
+// directory where e is a file) are replaced
+func (idx *Index) Add(e *Entry) {
+	e.Stage = StageMerged
+	idx.removePath(e.Path, true)
+
+	// a/b replaces a file a, and a replaces everything under a/
+	for dir := e.Path; ; {
+		slash := strings.LastIndexByte(dir, '/')
+		if slash < 0 {
+			break
+		}
+		dir = dir[:slash]
+		idx.removePath(dir, false)
+	}
+	idx.RemoveDir(e.Path)
+
+	i, _ := idx.search(e.Path, StageMerged)
+	idx.Entries = append(idx.Entries, nil)
+	copy(idx.Entries[i+1:], idx.Entries[i:])
+	idx.Entries[i] = e
+	idx.invalidate(e.Path)
+}
+
+// Remove drops every stage of path and reports whether there was any
+func (idx *Index) Remove(path string) bool {
+	return idx.removePath(path, false)
+}
+
+// RemoveDir drops every entry under dir and returns how many there were
+func (idx *Index) RemoveDir(dir string) int {
+	prefix := dir + "/"
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].Path >= prefix
+	})
+	j := i
+	for j < len(idx.Entries) && strings.HasPrefix(idx.Entries[j].Path, prefix) {
+		idx.invalidate(idx.Entries[j].Path)
+		j++
+	}
+	idx.Entries = append(idx.Entries[:i], idx.Entries[j:]...)
+	return j - i
+}
+
+func (idx *Index) removePath(path string, recordConflict bool) bool {
+	entries := idx.Stages(path)
+	if len(entries) == 0 {
+		return false
+	}
+	if recordConflict && entries[len(entries)-1].Stage != StageMerged {
+		idx.recordResolveUndo(path, entries)
+	}
+	i, _ := idx.search(path, StageMerged)
+	idx.Entries = append(idx.Entries[:i], idx.Entries[i+len(entries):]...)
+	idx.invalidate(path)
+	return true
+}
+
+func (idx *Index) recordResolveUndo(path string, entries []*Entry) {
+	ru := ResolveUndo{Path: path}
+	for _, e := range entries {
+		if e.Stage != StageMerged {
+			ru.Modes[e.Stage-1] = e.Mode
+			ru.Hashes[e.Stage-1] = e.Hash
+		}
+	}
+	for i := range idx.ResolveUndo {
+		if idx.ResolveUndo[i].Path == path {
+			idx.ResolveUndo[i] = ru
+			return
+		}
+	}
+	idx.ResolveUndo = append(idx.ResolveUndo, ru)
+	sort.Slice(idx.ResolveUndo, func(i, j int) bool {
+		return idx.ResolveUndo[i].Path < idx.ResolveUndo[j].Path
+	})
+}
+
+func (idx *Index) invalidate(path string) {
+	if idx.Cache != nil {
+		idx.Cache.Invalidate(path)
+	}
+}
+
+// readVarint decodes git's offset varint, where each continuation byte adds
+// one before shifting, and returns the number of bytes used (0 if truncated)
+func readVarint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value := uint64(data[0] & 127)
+	n := 1
+	for data[n-1]&128 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | uint64(data[n]&127)
+		n++
+	}
+	return value, n
+}
+
+func appendVarint(buf []byte, value uint64) []byte {
+	var tmp [10]byte
+	pos := len(tmp) - 1
+	tmp[pos] = byte(value & 127)
+	for value >>= 7; value != 0; value >>= 7 {
+		value--
+		pos--
+		tmp[pos] = 128 | byte(value&127)
+	}
+	return append(buf, tmp[pos:]...)
+}
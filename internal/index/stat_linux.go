@@ -0,0 +1,21 @@
+//go:build linux
+
+package index
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func fillStat(e *Entry, fi os.FileInfo) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	e.CTime = time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	e.Dev = uint32(st.Dev)
+	e.Ino = uint32(st.Ino)
+	e.UID = st.Uid
+	e.GID = st.Gid
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package index
+
+import "os"
+
+// fillStat has nothing more than os.FileInfo to go on outside of linux
+func fillStat(e *Entry, fi os.FileInfo) {}
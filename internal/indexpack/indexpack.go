@@ -0,0 +1,89 @@
+package indexpack
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type IndexPack struct {
+	Fs       *flag.FlagSet
+	Repo     *repository.Repository // where --stdin stores the pack, may be nil otherwise
+	packPath string
+	idxPath  string
+	stdin    bool
+}
+
+func (ip *IndexPack) Initialize(args []string) error {
+	ip.Fs.StringVar(&ip.idxPath, "o", "", "Write the index into <index-file>")
+	ip.Fs.BoolVar(&ip.stdin, "stdin", false, "Read the pack from stdin and store it in .git/objects/pack")
+	err := ip.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	rest := ip.Fs.Args()
+	if ip.stdin {
+		if len(rest) > 0 {
+			return errors.New("No pack file is expected with --stdin")
+		}
+		return nil
+	}
+	if len(rest) != 1 {
+		return errors.New("Expected a single pack file")
+	}
+	ip.packPath = rest[0]
+	if !strings.HasSuffix(ip.packPath, ".pack") {
+		return fmt.Errorf("Packfile name '%s' does not end with '.pack'", ip.packPath)
+	}
+	if ip.idxPath == "" {
+		ip.idxPath = strings.TrimSuffix(ip.packPath, ".pack") + ".idx"
+	}
+	return nil
+}
+
+func (ip *IndexPack) Usage() string {
+	return "git index-pack [-o <index-file>] <pack-file> | --stdin : Build a pack index for a packfile"
+}
+
+func (ip *IndexPack) Run() error {
+	if ip.stdin {
+		packBytes, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if ip.Repo == nil {
+			return repository.ErrNotARepository
+		}
+		packs := objectstore.NewPackStore(ip.Repo.ObjectDir, ip.Repo.Objects)
+		packHash, err := packs.AddPack(packBytes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("pack\t%s\n", packHash)
+		return nil
+	}
+
+	packBytes, err := os.ReadFile(ip.packPath)
+	if err != nil {
+		return err
+	}
+	if len(packBytes) < 32 || !strings.HasPrefix(string(packBytes[:4]), "PACK") {
+		return fmt.Errorf("%s is not a packfile", ip.packPath)
+	}
+	pack := packextractor.NewPack(packBytes)
+	if err := pack.ExtractObjects(); err != nil {
+		return err
+	}
+	if err := packextractor.WriteIndexFile(pack, ip.idxPath); err != nil {
+		return err
+	}
+	fmt.Println(pack.Hash())
+	return nil
+}
@@ -0,0 +1,19 @@
+package object
+
+import "github.com/codecrafters-io/git-starter-go/internal/packextractor"
+
+type Blob struct {
+	Data []byte
+}
+
+func ParseBlob(data []byte) *Blob {
+	return &Blob{Data: data}
+}
+
+func (b *Blob) Type() packextractor.ObjectType {
+	return packextractor.OBJ_BLOB
+}
+
+func (b *Blob) Encode() []byte {
+	return b.Data
+}
@@ -0,0 +1,132 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// Commit headers are written in this order: tree, parent..., author,
+// committer, encoding and then anything else (mergetag, gpgsig, ...)
+type Commit struct {
+	Tree      objectstore.Hash
+	Parents   []objectstore.Hash
+	Author    Signature
+	Committer Signature
+	Encoding  string // empty means UTF-8
+	// ExtraHeaders keeps every other header in order, signatures included
+	ExtraHeaders []Header
+	Message      string
+
+	layout headerLayout
+}
+
+func ParseCommit(data []byte) (*Commit, error) {
+	headers, message, layout, err := splitHeaders(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &Commit{Message: message, layout: layout}
+	var hasTree, hasAuthor, hasCommitter, hasEncoding bool
+	for _, h := range headers {
+		key := h.Key
+		switch {
+		case key == "tree":
+			if hasTree {
+				return nil, fmt.Errorf("Commit has more than one tree")
+			}
+			c.Tree, err = objectstore.ParseHash(h.Value)
+			hasTree = true
+		case key == "parent":
+			var parent objectstore.Hash
+			parent, err = objectstore.ParseHash(h.Value)
+			c.Parents = append(c.Parents, parent)
+		// like git, an identity that does not read well does not make the
+		// commit unreadable, and the ones after the first are kept as is
+		case key == "author" && !hasAuthor:
+			c.Author, _ = ParseSignature(h.Value)
+			hasAuthor = true
+		case key == "committer" && !hasCommitter:
+			c.Committer, _ = ParseSignature(h.Value)
+			hasCommitter = true
+		case key == "encoding" && !hasEncoding:
+			c.Encoding = h.Value
+			hasEncoding = true
+		default:
+			c.ExtraHeaders = append(c.ExtraHeaders, h)
+			key = ""
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.layout.keys = append(c.layout.keys, key)
+	}
+	if !hasTree || !hasAuthor || !hasCommitter {
+		return nil, fmt.Errorf("Commit is missing its tree, author or committer")
+	}
+	return c, nil
+}
+
+func (c *Commit) Type() packextractor.ObjectType {
+	return packextractor.OBJ_COMMIT
+}
+
+func (c *Commit) Encode() []byte {
+	known := []Header{{"tree", c.Tree.String()}}
+	for _, parent := range c.Parents {
+		known = append(known, Header{"parent", parent.String()})
+	}
+	known = append(known, Header{"author", c.Author.String()}, Header{"committer", c.Committer.String()})
+	if c.Encoding != "" {
+		known = append(known, Header{"encoding", c.Encoding})
+	}
+	var buf bytes.Buffer
+	c.layout.write(&buf, known, c.ExtraHeaders)
+	c.layout.writeMessage(&buf, c.Message)
+	return buf.Bytes()
+}
+
+// Header returns the first extra header with the given key
+func (c *Commit) Header(key string) (string, bool) {
+	for _, h := range c.ExtraHeaders {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// GPGSignature returns the gpgsig header, the signature of the commit
+func (c *Commit) GPGSignature() (string, bool) {
+	return c.Header("gpgsig")
+}
+
+// MergeTags returns the tags of the merged branches embedded by "git merge <tag>"
+func (c *Commit) MergeTags() ([]*Tag, error) {
+	var tags []*Tag
+	for _, h := range c.ExtraHeaders {
+		if h.Key != "mergetag" {
+			continue
+		}
+		tag, err := ParseTag([]byte(h.Value))
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// Summary is the first line of the message
+func (c *Commit) Summary() string {
+	line, _, _ := bytes.Cut([]byte(c.Message), []byte("\n"))
+	return string(line)
+}
+
+// Subject is the first paragraph of the message on a single line, like %s in
+// the pretty formats of git log
+func (c *Commit) Subject() string {
+	return Subject(c.Message)
+}
@@ -0,0 +1,113 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Header is a "<key> <value>" line of a commit or tag. Values spanning several
+// lines, like gpgsig and mergetag, continue on lines starting with a space
+type Header struct {
+	Key   string
+	Value string
+}
+
+// headerLayout is how the headers of an object were laid out when read, so
+// that an object not changed since is written back byte for byte
+type headerLayout struct {
+	keys         []string // in the order read, "" for the extra headers
+	bare         bool     // no empty line and no message after the headers
+	unterminated bool     // not even a newline after the last header
+}
+
+// splitHeaders separates the header block from the message at the first empty line
+func splitHeaders(data []byte) ([]Header, string, headerLayout, error) {
+	var layout headerLayout
+	headerBlock, message, found := bytes.Cut(data, []byte("\n\n"))
+	if !found {
+		layout.bare = true
+		layout.unterminated = !bytes.HasSuffix(data, []byte("\n"))
+		headerBlock = bytes.TrimSuffix(data, []byte("\n"))
+	}
+	var headers []Header
+	for _, line := range strings.Split(string(headerBlock), "\n") {
+		if strings.HasPrefix(line, " ") {
+			if len(headers) == 0 {
+				return nil, "", layout, fmt.Errorf("Continuation line without a header")
+			}
+			headers[len(headers)-1].Value += "\n" + line[1:]
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		if key == "" {
+			return nil, "", layout, fmt.Errorf("Malformed header line %q", line)
+		}
+		headers = append(headers, Header{Key: key, Value: value})
+	}
+	return headers, string(message), layout, nil
+}
+
+// write writes the known headers, given in the usual order, and the extra
+// ones. They keep the order they were read in if they are still the same
+// kinds of headers, in the same numbers
+func (l headerLayout) write(buf *bytes.Buffer, known, extra []Header) {
+	if !l.fits(known, extra) {
+		for _, h := range known {
+			writeHeader(buf, h.Key, h.Value)
+		}
+		for _, h := range extra {
+			writeHeader(buf, h.Key, h.Value)
+		}
+		return
+	}
+	next := map[string][]Header{}
+	for _, h := range known {
+		next[h.Key] = append(next[h.Key], h)
+	}
+	next[""] = extra
+	for _, key := range l.keys {
+		h := next[key][0]
+		next[key] = next[key][1:]
+		writeHeader(buf, h.Key, h.Value)
+	}
+}
+
+func (l headerLayout) fits(known, extra []Header) bool {
+	if l.keys == nil {
+		return false
+	}
+	counts := map[string]int{"": len(extra)}
+	for _, h := range known {
+		counts[h.Key]++
+	}
+	for _, key := range l.keys {
+		counts[key]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeMessage writes the empty line and the message, which objects read
+// without either do not get
+func (l headerLayout) writeMessage(buf *bytes.Buffer, message string) {
+	if l.bare && message == "" {
+		if l.unterminated {
+			buf.Truncate(buf.Len() - 1)
+		}
+		return
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(message)
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteByte(' ')
+	buf.WriteString(strings.ReplaceAll(value, "\n", "\n "))
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,38 @@
+package object
+
+import "strings"
+
+// Subject is the first paragraph of a commit or tag message on a single
+// line, trailing whitespace removed from each of its lines
+func Subject(message string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimLeft(message, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
+
+// Body is what follows the first paragraph of a message, without the blank
+// lines that separate them
+func Body(message string) string {
+	rest := strings.TrimLeft(message, "\n")
+	for rest != "" {
+		line, after, _ := strings.Cut(rest, "\n")
+		rest = after
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+	for rest != "" {
+		line, after, found := strings.Cut(rest, "\n")
+		if strings.TrimSpace(line) != "" || !found {
+			break
+		}
+		rest = after
+	}
+	return rest
+}
@@ -0,0 +1,113 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// Object is a parsed git object. Encode returns the content without the
+// "<type> <size>\0" header and, for anything that was parsed, the exact bytes
+// it was parsed from so the object keeps its name
+type Object interface {
+	Type() packextractor.ObjectType
+	Encode() []byte
+}
+
+// Decode parses a stored object into a *Commit, *Tree, *Tag or *Blob
+func Decode(obj *objectstore.Object) (Object, error) {
+	switch obj.Type {
+	case packextractor.OBJ_COMMIT:
+		return ParseCommit(obj.Data)
+	case packextractor.OBJ_TREE:
+		return ParseTree(obj.Data)
+	case packextractor.OBJ_TAG:
+		return ParseTag(obj.Data)
+	case packextractor.OBJ_BLOB:
+		return ParseBlob(obj.Data), nil
+	}
+	return nil, fmt.Errorf("Cannot decode an object of type %s", obj.Type)
+}
+
+// Put encodes the object and writes it to the store
+func Put(store objectstore.ObjectStore, o Object) (objectstore.Hash, error) {
+	return store.Put(&objectstore.Object{Type: o.Type(), Data: o.Encode()})
+}
+
+// HashOf computes the name the object would be stored under
+func HashOf(o Object) objectstore.Hash {
+	return packextractor.HashObject(o.Type(), o.Encode())
+}
+
+func GetCommit(store objectstore.ObjectStore, hash objectstore.Hash) (*Commit, error) {
+	obj, err := objectstore.GetTyped(store, hash, packextractor.OBJ_COMMIT)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCommit(obj.Data)
+}
+
+func GetTree(store objectstore.ObjectStore, hash objectstore.Hash) (*Tree, error) {
+	obj, err := objectstore.GetTyped(store, hash, packextractor.OBJ_TREE)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTree(obj.Data)
+}
+
+func GetTag(store objectstore.ObjectStore, hash objectstore.Hash) (*Tag, error) {
+	obj, err := objectstore.GetTyped(store, hash, packextractor.OBJ_TAG)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTag(obj.Data)
+}
+
+func GetBlob(store objectstore.ObjectStore, hash objectstore.Hash) (*Blob, error) {
+	obj, err := objectstore.GetTyped(store, hash, packextractor.OBJ_BLOB)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBlob(obj.Data), nil
+}
+
+// Peel follows tags until it reaches an object that is not a tag
+func Peel(store objectstore.ObjectStore, hash objectstore.Hash) (*objectstore.Object, objectstore.Hash, error) {
+	for {
+		obj, err := store.Get(hash)
+		if err != nil {
+			return nil, hash, err
+		}
+		if obj.Type != packextractor.OBJ_TAG {
+			return obj, hash, nil
+		}
+		tag, err := ParseTag(obj.Data)
+		if err != nil {
+			return nil, hash, err
+		}
+		hash = tag.Object
+	}
+}
+
+// PeelToTree accepts a tree, a commit or a tag pointing at either and returns the tree
+func PeelToTree(store objectstore.ObjectStore, hash objectstore.Hash) (*Tree, objectstore.Hash, error) {
+	obj, hash, err := Peel(store, hash)
+	if err != nil {
+		return nil, hash, err
+	}
+	if obj.Type == packextractor.OBJ_COMMIT {
+		commit, err := ParseCommit(obj.Data)
+		if err != nil {
+			return nil, hash, err
+		}
+		hash = commit.Tree
+		tree, err := GetTree(store, hash)
+		return tree, hash, err
+	}
+	if obj.Type != packextractor.OBJ_TREE {
+		return nil, hash, fmt.Errorf("Object %s is a %s, not a tree", hash, obj.Type)
+	}
+	tree, err := ParseTree(obj.Data)
+	return tree, hash, err
+}
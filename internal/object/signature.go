@@ -0,0 +1,107 @@
+package object
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature is the identity on author, committer and tagger lines:
+//
+//	Arman Chhetri <armanchhetri44@gmail.com> 1712252028 +0545
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+
+	tz  string // the offset as written, "-0000" and friends do not survive time.Time
+	raw string // the line as read, written back as long as it says the same
+}
+
+func NewSignature(name, email string, when time.Time) Signature {
+	return Signature{Name: name, Email: email, When: when}
+}
+
+// ParseSignature reads an identity the way git does, keeping the line as
+// it is. What is missing or malformed is reported, but the signature is
+// still usable: a date that cannot be read is the epoch
+func ParseSignature(line string) (Signature, error) {
+	sig := Signature{When: time.Unix(0, 0).UTC(), raw: line}
+	open := strings.IndexByte(line, '<')
+	if open < 0 {
+		sig.Name = line
+		return sig, fmt.Errorf("Malformed identity %q", line)
+	}
+	closing := strings.IndexByte(line[open:], '>')
+	if closing < 0 {
+		sig.Name = strings.TrimRight(line[:open], " \t")
+		return sig, fmt.Errorf("Malformed identity %q", line)
+	}
+	sig.Name = strings.TrimRight(line[:open], " \t")
+	sig.Email = line[open+1 : open+closing]
+	// like git, the date follows the last '>'
+	fields := strings.Fields(line[strings.LastIndexByte(line, '>')+1:])
+	if len(fields) == 0 {
+		return sig, fmt.Errorf("Missing date in identity %q", line)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return sig, fmt.Errorf("Malformed date in identity %q", line)
+	}
+	sig.When = time.Unix(seconds, 0).UTC()
+	if len(fields) < 2 {
+		return sig, fmt.Errorf("Missing timezone in identity %q", line)
+	}
+	offset, err := ParseTimezone(fields[1])
+	if err != nil {
+		// git reads any number as hours and minutes, +01 is a minute east
+		hhmm, convErr := strconv.Atoi(fields[1])
+		if convErr != nil {
+			return sig, err
+		}
+		offset = hhmm/100*3600 + hhmm%100*60
+	}
+	sig.tz = fields[1]
+	sig.When = sig.When.In(time.FixedZone("", offset))
+	return sig, nil
+}
+
+// ParseTimezone converts "+0545" into an offset in seconds
+func ParseTimezone(tz string) (int, error) {
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return 0, fmt.Errorf("Malformed timezone %q", tz)
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:])
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("Malformed timezone %q", tz)
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// Timezone returns the offset the way git writes it, like +0545
+func (s Signature) Timezone() string {
+	if s.tz != "" {
+		if offset, err := ParseTimezone(s.tz); err == nil {
+			if _, current := s.When.Zone(); current == offset {
+				return s.tz
+			}
+		}
+	}
+	return s.When.Format("-0700")
+}
+
+func (s Signature) String() string {
+	if s.raw != "" {
+		read, _ := ParseSignature(s.raw)
+		if read.Name == s.Name && read.Email == s.Email && read.When.Equal(s.When) && read.Timezone() == s.Timezone() {
+			return s.raw
+		}
+	}
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.When.Unix(), s.Timezone())
+}
@@ -0,0 +1,75 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// Tag is an annotated tag. A signed tag carries its signature at the end of
+// the message
+type Tag struct {
+	Object     objectstore.Hash
+	TargetType packextractor.ObjectType
+	Name       string
+	Tagger     *Signature // very old tags have none
+	// ExtraHeaders keeps every other header in order
+	ExtraHeaders []Header
+	Message      string
+
+	layout headerLayout
+}
+
+func ParseTag(data []byte) (*Tag, error) {
+	headers, message, layout, err := splitHeaders(data)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tag{Message: message, layout: layout}
+	var hasObject, hasType, hasName bool
+	for _, h := range headers {
+		key := h.Key
+		switch {
+		case key == "object" && !hasObject:
+			t.Object, err = objectstore.ParseHash(h.Value)
+			hasObject = true
+		case key == "type" && !hasType:
+			t.TargetType, err = packextractor.ParseObjectType(h.Value)
+			hasType = true
+		case key == "tag" && !hasName:
+			t.Name = h.Value
+			hasName = true
+		case key == "tagger" && t.Tagger == nil:
+			tagger, _ := ParseSignature(h.Value)
+			t.Tagger = &tagger
+		default:
+			t.ExtraHeaders = append(t.ExtraHeaders, h)
+			key = ""
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.layout.keys = append(t.layout.keys, key)
+	}
+	if !hasObject || !hasType {
+		return nil, fmt.Errorf("Tag is missing its object or type")
+	}
+	return t, nil
+}
+
+func (t *Tag) Type() packextractor.ObjectType {
+	return packextractor.OBJ_TAG
+}
+
+func (t *Tag) Encode() []byte {
+	known := []Header{{"object", t.Object.String()}, {"type", t.TargetType.String()}, {"tag", t.Name}}
+	if t.Tagger != nil {
+		known = append(known, Header{"tagger", t.Tagger.String()})
+	}
+	var buf bytes.Buffer
+	t.layout.write(&buf, known, t.ExtraHeaders)
+	t.layout.writeMessage(&buf, t.Message)
+	return buf.Bytes()
+}
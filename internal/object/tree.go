@@ -0,0 +1,161 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+type FileMode uint32
+
+const (
+	ModeTree       FileMode = 0o40000
+	ModeBlob       FileMode = 0o100644
+	ModeExecutable FileMode = 0o100755
+	ModeSymlink    FileMode = 0o120000
+	ModeGitlink    FileMode = 0o160000
+)
+
+func (m FileMode) IsTree() bool {
+	return m&0o170000 == ModeTree
+}
+
+// ObjectType is the type of object an entry with this mode points to
+func (m FileMode) ObjectType() packextractor.ObjectType {
+	switch {
+	case m.IsTree():
+		return packextractor.OBJ_TREE
+	case m == ModeGitlink:
+		return packextractor.OBJ_COMMIT
+	}
+	return packextractor.OBJ_BLOB
+}
+
+// String is the mode as ls-tree prints it, always six digits
+func (m FileMode) String() string {
+	return fmt.Sprintf("%06o", uint32(m))
+}
+
+type TreeEntry struct {
+	Mode FileMode
+	Name string
+	Hash objectstore.Hash
+
+	rawMode string // the mode as written, some old trees zero pad it
+}
+
+// Tree entries are "<octal mode> <name>\0<20 byte hash>", with the mode not zero padded
+type Tree struct {
+	Entries []TreeEntry
+}
+
+func ParseTree(data []byte) (*Tree, error) {
+	t := &Tree{}
+	for len(data) > 0 {
+		modeEnd := bytes.IndexByte(data, ' ')
+		nameEnd := bytes.IndexByte(data, 0)
+		if modeEnd <= 0 || nameEnd < modeEnd || nameEnd+21 > len(data) {
+			return nil, fmt.Errorf("Malformed tree entry")
+		}
+		mode, err := strconv.ParseUint(string(data[:modeEnd]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Malformed tree entry mode %q", data[:modeEnd])
+		}
+		entry := TreeEntry{
+			Mode:    FileMode(mode),
+			Name:    string(data[modeEnd+1 : nameEnd]),
+			rawMode: string(data[:modeEnd]),
+		}
+		copy(entry.Hash[:], data[nameEnd+1:nameEnd+21])
+		t.Entries = append(t.Entries, entry)
+		data = data[nameEnd+21:]
+	}
+	return t, nil
+}
+
+func (t *Tree) Type() packextractor.ObjectType {
+	return packextractor.OBJ_TREE
+}
+
+func (t *Tree) Encode() []byte {
+	var buf bytes.Buffer
+	for _, entry := range t.Entries {
+		mode := strconv.FormatUint(uint64(entry.Mode), 8)
+		if entry.rawMode != "" {
+			if parsed, err := strconv.ParseUint(entry.rawMode, 8, 32); err == nil && FileMode(parsed) == entry.Mode {
+				mode = entry.rawMode
+			}
+		}
+		buf.WriteString(mode)
+		buf.WriteByte(' ')
+		buf.WriteString(entry.Name)
+		buf.WriteByte(0)
+		buf.Write(entry.Hash[:])
+	}
+	return buf.Bytes()
+}
+
+// Find returns the entry with the given name
+func (t *Tree) Find(name string) (TreeEntry, bool) {
+	for _, entry := range t.Entries {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return TreeEntry{}, false
+}
+
+// Sort puts the entries in the order git requires, where a tree sorts as if
+// its name ended with a slash: "a.txt" < "a/" < "a0"
+func (t *Tree) Sort() {
+	sort.SliceStable(t.Entries, func(i, j int) bool {
+		return CompareEntryNames(t.Entries[i].Name, t.Entries[i].Mode.IsTree(), t.Entries[j].Name, t.Entries[j].Mode.IsTree()) < 0
+	})
+}
+
+// CompareEntryNames compares two tree entry names in git's tree order
+func CompareEntryNames(a string, aIsTree bool, b string, bIsTree bool) int {
+	if aIsTree {
+		a += "/"
+	}
+	if bIsTree {
+		b += "/"
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// WalkTree calls fn with the slash separated path of every blob and gitlink
+// under the tree, in tree order. Gitlinks are not entered
+func WalkTree(store objectstore.ObjectStore, hash objectstore.Hash, fn func(path string, entry TreeEntry) error) error {
+	return walkTree(store, hash, "", fn)
+}
+
+func walkTree(store objectstore.ObjectStore, hash objectstore.Hash, prefix string, fn func(string, TreeEntry) error) error {
+	tree, err := GetTree(store, hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+		if entry.Mode.IsTree() {
+			if err := walkTree(store, entry.Hash, name+"/", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(name, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package objectstore
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// LooseStore keeps every object zlib compressed in <dir>/<2 chars>/<38 chars>
+type LooseStore struct {
+	Dir string
+}
+
+func NewLooseStore(dir string) *LooseStore {
+	return &LooseStore{Dir: dir}
+}
+
+func (ls *LooseStore) path(hash Hash) string {
+	hashStr := hash.String()
+	return filepath.Join(ls.Dir, hashStr[:2], hashStr[2:])
+}
+
+func (ls *LooseStore) Has(hash Hash) bool {
+	_, err := os.Stat(ls.path(hash))
+	return err == nil
+}
+
+func (ls *LooseStore) Get(hash Hash) (*Object, error) {
+	file, err := os.Open(ls.path(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := zlib.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Corrupt loose object %s: %v", hash, err)
+	}
+	return DecodeLoose(data)
+}
+
+// DecodeLoose splits "<type> <size>\0<content>" and checks the size
+func DecodeLoose(data []byte) (*Object, error) {
+	header, body, found := bytes.Cut(data, []byte{0})
+	typeName, sizeStr, ok := bytes.Cut(header, []byte(" "))
+	if !found || !ok {
+		return nil, fmt.Errorf("Corrupt loose object header")
+	}
+	objType, err := packextractor.ParseObjectType(string(typeName))
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(string(sizeStr))
+	if err != nil || size != len(body) {
+		return nil, fmt.Errorf("Corrupt loose object size")
+	}
+	return &Object{Type: objType, Data: body}, nil
+}
+
+func (ls *LooseStore) Put(obj *Object) (Hash, error) {
+	hash := obj.Hash()
+	objPath := ls.path(hash)
+	if _, err := os.Stat(objPath); err == nil {
+		return hash, nil
+	}
+	if _, err := os.Stat(ls.Dir); err != nil {
+		return hash, fmt.Errorf("Could not find valid git repository, Did you git init?")
+	}
+	err := os.MkdirAll(filepath.Dir(objPath), 0o755)
+	if err != nil {
+		return hash, err
+	}
+	var compressedData bytes.Buffer
+	w := zlib.NewWriter(&compressedData)
+	_, err = w.Write(obj.Encode())
+	if err != nil {
+		return hash, err
+	}
+	w.Close()
+
+	// write under a temporary name so a reader never sees half an object
+	tmp, err := os.CreateTemp(filepath.Dir(objPath), "tmp_obj_")
+	if err != nil {
+		return hash, err
+	}
+	_, err = tmp.Write(compressedData.Bytes())
+	tmp.Close()
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o444)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), objPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return hash, err
+	}
+	return hash, nil
+}
+
+func (ls *LooseStore) Iterate(fn func(hash Hash) error) error {
+	dirs, err := os.ReadDir(ls.Dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(ls.Dir, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			hash, err := ParseHash(dir.Name() + file.Name())
+			if err != nil {
+				continue // temporary files and other leftovers
+			}
+			if err := fn(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
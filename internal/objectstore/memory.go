@@ -0,0 +1,51 @@
+package objectstore
+
+import "sort"
+
+// MemoryStore keeps objects in a map, it is meant for tests and for callers
+// that build objects they may never write to disk
+type MemoryStore struct {
+	objects map[Hash]*Object
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[Hash]*Object)}
+}
+
+func (ms *MemoryStore) Has(hash Hash) bool {
+	_, ok := ms.objects[hash]
+	return ok
+}
+
+func (ms *MemoryStore) Get(hash Hash) (*Object, error) {
+	obj, ok := ms.objects[hash]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return obj, nil
+}
+
+func (ms *MemoryStore) Put(obj *Object) (Hash, error) {
+	hash := obj.Hash()
+	data := make([]byte, len(obj.Data))
+	copy(data, obj.Data)
+	ms.objects[hash] = &Object{Type: obj.Type, Data: data}
+	return hash, nil
+}
+
+// Iterate visits the objects in hash order so the output is stable
+func (ms *MemoryStore) Iterate(fn func(hash Hash) error) error {
+	hashes := make([]Hash, 0, len(ms.objects))
+	for hash := range ms.objects {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+	for _, hash := range hashes {
+		if err := fn(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
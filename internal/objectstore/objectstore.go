@@ -0,0 +1,127 @@
+package objectstore
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+var (
+	ErrObjectNotFound = errors.New("Object not found")
+	ErrReadOnly       = errors.New("Object store is read only")
+)
+
+// Hash is the binary SHA-1 name of an object
+type Hash [20]byte
+
+var ZeroHash Hash
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h Hash) IsZero() bool {
+	return h == ZeroHash
+}
+
+// ParseHash turns a full 40 character hex name into its binary form
+func ParseHash(name string) (Hash, error) {
+	var hash Hash
+	if len(name) != 40 {
+		return hash, fmt.Errorf("Not a valid object name %s", name)
+	}
+	if _, err := hex.Decode(hash[:], []byte(name)); err != nil {
+		return hash, fmt.Errorf("Not a valid object name %s", name)
+	}
+	return hash, nil
+}
+
+// Object is an object's type and content, without the "<type> <size>\0" header
+type Object struct {
+	Type packextractor.ObjectType
+	Data []byte
+}
+
+// Hash computes the name of the object
+func (o *Object) Hash() Hash {
+	return packextractor.HashObject(o.Type, o.Data)
+}
+
+// Encode returns the object the way it is hashed and stored loose: with its header
+func (o *Object) Encode() []byte {
+	header := fmt.Sprintf("%s %d\x00", o.Type, len(o.Data))
+	return append([]byte(header), o.Data...)
+}
+
+// ObjectStore is where objects are read from and written to. Every command
+// goes through one, so a library user can swap in a MemoryStore or their own
+type ObjectStore interface {
+	Has(hash Hash) bool
+	// Get returns ErrObjectNotFound when the object does not exist
+	Get(hash Hash) (*Object, error)
+	// Put stores the object and returns its name, storing an existing object is not an error
+	Put(obj *Object) (Hash, error)
+	// Iterate calls fn for every object in the store, stopping at the first error
+	Iterate(fn func(hash Hash) error) error
+}
+
+// GetTyped is Get that also checks the type of the object
+func GetTyped(store ObjectStore, hash Hash, want packextractor.ObjectType) (*Object, error) {
+	obj, err := store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != want {
+		return nil, fmt.Errorf("Object %s is a %s, not a %s", hash, obj.Type, want)
+	}
+	return obj, nil
+}
+
+// DiskStore is the object database of a repository: loose objects first,
+// then packs. New objects are always written loose
+type DiskStore struct {
+	Loose *LooseStore
+	Packs *PackStore
+}
+
+// Open returns the store for an objects directory, usually .git/objects
+func Open(objDir string) *DiskStore {
+	ds := &DiskStore{Loose: NewLooseStore(objDir)}
+	ds.Packs = NewPackStore(objDir, ds)
+	return ds
+}
+
+func (ds *DiskStore) Has(hash Hash) bool {
+	return ds.Loose.Has(hash) || ds.Packs.Has(hash)
+}
+
+func (ds *DiskStore) Get(hash Hash) (*Object, error) {
+	obj, err := ds.Loose.Get(hash)
+	if !errors.Is(err, ErrObjectNotFound) {
+		return obj, err
+	}
+	return ds.Packs.Get(hash)
+}
+
+func (ds *DiskStore) Put(obj *Object) (Hash, error) {
+	hash := obj.Hash()
+	if ds.Packs.Has(hash) {
+		return hash, nil
+	}
+	return ds.Loose.Put(obj)
+}
+
+// Iterate visits loose objects and then packed ones, an object that is both
+// loose and packed is visited twice
+func (ds *DiskStore) Iterate(fn func(hash Hash) error) error {
+	if err := ds.Loose.Iterate(fn); err != nil {
+		return err
+	}
+	return ds.Packs.Iterate(fn)
+}
+
+func (ds *DiskStore) Close() error {
+	return ds.Packs.Close()
+}
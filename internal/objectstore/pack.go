@@ -0,0 +1,138 @@
+package objectstore
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// PackStore reads objects from the packs in <dir>/pack. It is read only,
+// packs are added as a whole with AddPack
+type PackStore struct {
+	Dir string
+
+	// bases resolves REF_DELTA entries whose base is outside the pack
+	bases ObjectStore
+
+	once  sync.Once
+	packs []*packextractor.PackFile
+	err   error
+}
+
+// NewPackStore creates a store for the packs under dir, bases may be nil
+func NewPackStore(dir string, bases ObjectStore) *PackStore {
+	return &PackStore{Dir: dir, bases: bases}
+}
+
+func (ps *PackStore) load() error {
+	ps.once.Do(func() {
+		idxPaths, err := filepath.Glob(filepath.Join(ps.Dir, "pack", "pack-*.idx"))
+		if err != nil {
+			ps.err = err
+			return
+		}
+		for _, idxPath := range idxPaths {
+			if err := ps.open(idxPath); err != nil {
+				ps.err = err
+				return
+			}
+		}
+	})
+	return ps.err
+}
+
+func (ps *PackStore) open(idxPath string) error {
+	pack, err := packextractor.OpenPackFile(idxPath)
+	if err != nil {
+		return err
+	}
+	pack.Resolver = ps.resolveBase
+	ps.packs = append(ps.packs, pack)
+	return nil
+}
+
+func (ps *PackStore) resolveBase(hash [20]byte) (packextractor.ObjectType, []byte, error) {
+	var obj *Object
+	var err error
+	if ps.bases != nil {
+		obj, err = ps.bases.Get(hash)
+	} else {
+		obj, err = ps.Get(hash)
+	}
+	if err != nil {
+		return packextractor.OBJ_INVALID, nil, err
+	}
+	return obj.Type, obj.Data, nil
+}
+
+func (ps *PackStore) Has(hash Hash) bool {
+	if ps.load() != nil {
+		return false
+	}
+	for _, pack := range ps.packs {
+		if pack.Has(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps *PackStore) Get(hash Hash) (*Object, error) {
+	if err := ps.load(); err != nil {
+		return nil, err
+	}
+	for _, pack := range ps.packs {
+		if !pack.Has(hash) {
+			continue
+		}
+		objType, data, err := pack.ReadObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{Type: objType, Data: data}, nil
+	}
+	return nil, ErrObjectNotFound
+}
+
+func (ps *PackStore) Put(obj *Object) (Hash, error) {
+	return obj.Hash(), ErrReadOnly
+}
+
+func (ps *PackStore) Iterate(fn func(hash Hash) error) error {
+	if err := ps.load(); err != nil {
+		return err
+	}
+	for _, pack := range ps.packs {
+		for i := 0; i < pack.Index.Count(); i++ {
+			if err := fn(pack.Index.Name(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AddPack stores a fetched pack with its index and makes its objects
+// available, it returns the pack's checksum
+func (ps *PackStore) AddPack(packBytes []byte) (string, error) {
+	if err := ps.load(); err != nil {
+		return "", err
+	}
+	pack, err := packextractor.StorePack(filepath.Join(ps.Dir, "pack"), packBytes, ps.resolveBase)
+	if err != nil {
+		return "", err
+	}
+	return pack.Hash(), ps.open(filepath.Join(ps.Dir, "pack", "pack-"+pack.Hash()+".idx"))
+}
+
+func (ps *PackStore) Close() error {
+	var firstErr error
+	for _, pack := range ps.packs {
+		if err := pack.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	ps.packs = nil
+	return firstErr
+}
@@ -0,0 +1,49 @@
+package packextractor
+
+import "container/list"
+
+// deltaBaseCacheLimit bounds the bytes of the cached delta bases, the
+// default of git's core.deltaBaseCacheLimit
+const deltaBaseCacheLimit = 96 << 20
+
+// baseCache keeps the objects delta entries were applied to, by offset,
+// dropping the least recently used ones past the limit
+type baseCache struct {
+	limit int
+	size  int
+	order *list.List // of *cachedBase, least recently used first
+	items map[uint64]*list.Element
+}
+
+type cachedBase struct {
+	offset uint64
+	obj    *RawObject
+}
+
+func newBaseCache(limit int) *baseCache {
+	return &baseCache{limit: limit, order: list.New(), items: make(map[uint64]*list.Element)}
+}
+
+func (c *baseCache) get(offset uint64) (*RawObject, bool) {
+	elem, ok := c.items[offset]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToBack(elem)
+	return elem.Value.(*cachedBase).obj, true
+}
+
+// add caches a base, unless it is bigger than the whole cache
+func (c *baseCache) add(offset uint64, obj *RawObject) {
+	if _, ok := c.items[offset]; ok || len(obj.Data) > c.limit {
+		return
+	}
+	for c.size+len(obj.Data) > c.limit {
+		oldest := c.order.Front()
+		base := c.order.Remove(oldest).(*cachedBase)
+		delete(c.items, base.offset)
+		c.size -= len(base.obj.Data)
+	}
+	c.items[offset] = c.order.PushBack(&cachedBase{offset: offset, obj: obj})
+	c.size += len(obj.Data)
+}
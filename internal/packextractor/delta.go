@@ -1,14 +1,15 @@
 package packextractor
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
 )
 
 type OffDeltaObject struct {
 	size      uint64
 	deltaData []byte
-	offset    uint64
+	offset    uint64 // distance back from this entry to its base
 }
 
 type RefDeltaObject struct {
@@ -17,20 +18,39 @@ type RefDeltaObject struct {
 	refData []byte
 }
 
+// Raw returns the delta instructions, use Pack.ReadObject to get the
+// reconstructed object
 func (ofd *OffDeltaObject) Raw() ([]byte, error) {
 	return ofd.deltaData, nil
 }
 
+// Raw returns the delta instructions, use Pack.ReadObject to get the
+// reconstructed object
 func (ord *RefDeltaObject) Raw() ([]byte, error) {
 	return ord.refData, nil
 }
 
-func EmitAOffDelta(stream *bytes.Reader) (Object, error) {
+// extractOffset reads the negative offset of an OFS_DELTA entry. Unlike the
+// size varint, every continuation adds one before shifting so that there is
+// exactly one encoding for each offset
+func extractOffset(stream PackStream) (uint64, error) {
 	nextByte, err := stream.ReadByte()
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	offset := uint64(nextByte & 127)
+	for nextByte >= 128 {
+		nextByte, err = stream.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | uint64(nextByte&127)
 	}
-	offset, err := extractVarInt(nextByte, stream)
+	return offset, nil
+}
+
+func EmitAOffDelta(size uint64, stream PackStream) (Object, error) {
+	offset, err := extractOffset(stream)
 	if err != nil {
 		return nil, err
 	}
@@ -38,13 +58,15 @@ func EmitAOffDelta(stream *bytes.Reader) (Object, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("OffsetDelta: ", offset, string(data))
-	return &OffDeltaObject{deltaData: data, offset: offset}, nil
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("Size mismatch for OFS_DELTA, expected %d got %d", size, len(data))
+	}
+	return &OffDeltaObject{size: size, deltaData: data, offset: offset}, nil
 }
 
-func EmitARefDelta(stream *bytes.Reader) (Object, error) {
+func EmitARefDelta(size uint64, stream PackStream) (Object, error) {
 	var refName [20]byte
-	_, err := stream.Read(refName[:])
+	_, err := io.ReadFull(stream, refName[:])
 	if err != nil {
 		return nil, fmt.Errorf("Error while reading refdelta refName %v", err)
 	}
@@ -53,6 +75,99 @@ func EmitARefDelta(stream *bytes.Reader) (Object, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("RefsDelta: \n", string(data))
-	return &RefDeltaObject{refData: data, refName: refName}, nil
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("Size mismatch for REF_DELTA, expected %d got %d", size, len(data))
+	}
+	return &RefDeltaObject{size: size, refData: data, refName: refName}, nil
+}
+
+var errDeltaTruncated = errors.New("Delta data is truncated")
+
+// deltaHeaderSize reads the source and target sizes at the start of a delta,
+// these are plain little endian base 128 varints
+func deltaHeaderSize(delta []byte, pos int) (uint64, int, error) {
+	var size uint64
+	shift := 0
+	for {
+		if pos >= len(delta) {
+			return 0, pos, errDeltaTruncated
+		}
+		b := delta[pos]
+		pos++
+		size |= uint64(b&127) << shift
+		shift += 7
+		if b < 128 {
+			return size, pos, nil
+		}
+	}
+}
+
+// ApplyDelta reconstructs an object from its base and a git delta.
+// A delta is the source size, the target size and then a list of instructions:
+//
+//	1xxxxxxx  copy, the low 4 bits select which offset bytes follow and the
+//	          next 3 bits which size bytes follow (a size of 0 means 0x10000)
+//	0xxxxxxx  insert the next xxxxxxx bytes of the delta literally
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, pos, err := deltaHeaderSize(delta, 0)
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != uint64(len(base)) {
+		return nil, fmt.Errorf("Delta expects a base of %d bytes but got %d", srcSize, len(base))
+	}
+	targetSize, pos, err := deltaHeaderSize(delta, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+	for pos < len(delta) {
+		cmd := delta[pos]
+		pos++
+		switch {
+		case cmd&128 != 0:
+			var offset, size uint64
+			for i := 0; i < 4; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if pos >= len(delta) {
+					return nil, errDeltaTruncated
+				}
+				offset |= uint64(delta[pos]) << (8 * i)
+				pos++
+			}
+			for i := 0; i < 3; i++ {
+				if cmd&(16<<i) == 0 {
+					continue
+				}
+				if pos >= len(delta) {
+					return nil, errDeltaTruncated
+				}
+				size |= uint64(delta[pos]) << (8 * i)
+				pos++
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("Delta copies %d bytes at %d from a base of %d bytes", size, offset, len(base))
+			}
+			target = append(target, base[offset:offset+size]...)
+		case cmd != 0:
+			n := int(cmd)
+			if pos+n > len(delta) {
+				return nil, errDeltaTruncated
+			}
+			target = append(target, delta[pos:pos+n]...)
+			pos += n
+		default:
+			return nil, errors.New("Delta contains the reserved instruction 0")
+		}
+	}
+	if uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("Delta produced %d bytes, expected %d", len(target), targetSize)
+	}
+	return target, nil
 }
@@ -0,0 +1,128 @@
+package packextractor
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// idxMagic starts every version 2 (and later) pack index, version 1 has no header
+var idxMagic = []byte{0xff, 't', 'O', 'c'}
+
+const idxVersion = 2
+
+// WriteIndex writes a version 2 pack index for the pack. ExtractObjects must
+// have been called before. The layout is
+//
+//	magic, version
+//	fanout[256]   number of objects whose first hash byte is <= i
+//	names[n]      sorted object names
+//	crc32[n]      checksum of each packed entry
+//	offset32[n]   offset in the pack, or index into offset64 when the MSB is set
+//	offset64[m]   offsets that do not fit in 31 bits
+//	pack checksum, index checksum
+func (pck *Pack) WriteIndex(w io.Writer) error {
+	if len(pck.Entries) != int(pck.size) {
+		return errors.New("Pack objects have not been extracted")
+	}
+	objects := append([]PackEntry(nil), pck.Entries...)
+	sort.Slice(objects, func(i, j int) bool {
+		return bytes.Compare(objects[i].Hash[:], objects[j].Hash[:]) < 0
+	})
+
+	packChecksum, err := hex.DecodeString(pck.hash)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(idxMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(idxVersion))
+
+	var fanout [256]uint32
+	for _, obj := range objects {
+		fanout[obj.Hash[0]]++
+	}
+	for i := 1; i < len(fanout); i++ {
+		fanout[i] += fanout[i-1]
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+
+	for _, obj := range objects {
+		buf.Write(obj.Hash[:])
+	}
+	for _, obj := range objects {
+		binary.Write(&buf, binary.BigEndian, obj.CRC32)
+	}
+	var largeOffsets []uint64
+	for _, obj := range objects {
+		if obj.Offset < 1<<31 {
+			binary.Write(&buf, binary.BigEndian, uint32(obj.Offset))
+			continue
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(len(largeOffsets))|1<<31)
+		largeOffsets = append(largeOffsets, obj.Offset)
+	}
+	for _, offset := range largeOffsets {
+		binary.Write(&buf, binary.BigEndian, offset)
+	}
+	buf.Write(packChecksum)
+	idxChecksum := sha1.Sum(buf.Bytes())
+	buf.Write(idxChecksum[:])
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// StorePack parses packBytes, resolving deltas with resolver when it is not
+// nil, and saves it as pack-<checksum>.pack with the matching .idx in packDir.
+// The returned pack has its entries extracted
+func StorePack(packDir string, packBytes []byte, resolver ObjectResolver) (*Pack, error) {
+	if len(packBytes) < packHeaderSize+20 || !bytes.HasPrefix(packBytes, []byte("PACK")) {
+		return nil, errors.New("Not a packfile")
+	}
+	checksum := sha1.Sum(packBytes[:len(packBytes)-20])
+	if !bytes.Equal(checksum[:], packBytes[len(packBytes)-20:]) {
+		return nil, errors.New("Pack checksum does not match its content")
+	}
+	pack := NewPack(packBytes)
+	pack.Resolver = resolver
+	if err := pack.ExtractObjects(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		return nil, err
+	}
+	base := filepath.Join(packDir, "pack-"+pack.hash)
+	if _, err := os.Stat(base + ".pack"); os.IsNotExist(err) {
+		if err := os.WriteFile(base+".pack", packBytes, 0o444); err != nil {
+			return nil, err
+		}
+	}
+	if err := WriteIndexFile(pack, base+".idx"); err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// WriteIndexFile writes the index of an extracted pack to idxPath, the file is
+// written under a temporary name first so readers never see half an index
+func WriteIndexFile(pack *Pack, idxPath string) error {
+	var idx bytes.Buffer
+	if err := pack.WriteIndex(&idx); err != nil {
+		return err
+	}
+	tmp := idxPath + ".tmp"
+	os.Remove(tmp)
+	if err := os.WriteFile(tmp, idx.Bytes(), 0o444); err != nil {
+		return fmt.Errorf("Could not write pack index %v", err)
+	}
+	return os.Rename(tmp, idxPath)
+}
@@ -3,11 +3,13 @@ package packextractor
 import (
 	"bytes"
 	"compress/zlib"
+	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
-	"log/slog"
 )
 
 type ObjectType uint8
@@ -22,52 +24,74 @@ const (
 	OBJ_REF_DELTA ObjectType = 7
 )
 
-type Object interface {
-	Raw() ([]byte, error)
+// String returns the name git uses for the type in object headers
+func (t ObjectType) String() string {
+	switch t {
+	case OBJ_COMMIT:
+		return "commit"
+	case OBJ_TREE:
+		return "tree"
+	case OBJ_BLOB:
+		return "blob"
+	case OBJ_TAG:
+		return "tag"
+	case OBJ_OFS_DELTA:
+		return "ofs-delta"
+	case OBJ_REF_DELTA:
+		return "ref-delta"
+	}
+	return "invalid"
 }
 
-type ObjectCommit struct {
-	currentHash   string
-	parentHash    string
-	authorName    string
-	authorEmail   string
-	commiterName  string
-	commiterEmail string
-	timestamp     string
-	message       string
-	size          uint
+// ParseObjectType is the reverse of ObjectType.String for the four base types
+func ParseObjectType(name string) (ObjectType, error) {
+	switch name {
+	case "commit":
+		return OBJ_COMMIT, nil
+	case "tree":
+		return OBJ_TREE, nil
+	case "blob":
+		return OBJ_BLOB, nil
+	case "tag":
+		return OBJ_TAG, nil
+	}
+	return OBJ_INVALID, fmt.Errorf("Invalid object type %q", name)
 }
 
-func (oc *ObjectCommit) Raw() ([]byte, error) {
-	return []byte{}, nil
+type Object interface {
+	Raw() ([]byte, error)
 }
 
-type BlobObject struct {
-	content string
-	size    uint
+// RawObject is a fully reconstructed (non delta) object found in a pack
+type RawObject struct {
+	Type   ObjectType
+	Data   []byte
+	Hash   [20]byte
+	Offset uint64 // offset of the entry from the start of the packfile
 }
 
-type TreeLeaf struct {
-	filename string
-	sha1hash string
-	mode     string
+func (ro *RawObject) Raw() ([]byte, error) {
+	return ro.Data, nil
 }
 
-type ObjectTree = []TreeLeaf
+// HashObject computes the object name git gives to data of the given type
+func HashObject(objType ObjectType, data []byte) [20]byte {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objType, len(data))
+	h.Write(data)
+	var hash [20]byte
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
 
-type TagObject struct {
-	size        uint
-	tagType     string // "commit"| "blob"
-	name        string
-	sha1hash    string
-	taggerName  string
-	taggerEmail string
-	timestamp   int64
-	timezone    string
-	message     string
+// PackStream is what entries are decoded from, the byte reader lets zlib stop
+// exactly at the end of each entry instead of reading ahead
+type PackStream interface {
+	io.Reader
+	io.ByteReader
 }
 
-func EmitAGitObject(stream *bytes.Reader) (Object, error) {
+func EmitAGitObject(stream PackStream) (Object, error) {
 	nextByte, err := stream.ReadByte()
 	if err != nil {
 		return nil, err
@@ -78,24 +102,20 @@ func EmitAGitObject(stream *bytes.Reader) (Object, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error while extracting size of an object %v", err)
 	}
-	fmt.Printf("objType: %v, size: %v\n", objectType, size)
 
 	switch objectType {
 	case OBJ_COMMIT, OBJ_TREE, OBJ_BLOB, OBJ_TAG:
-		return EmitACommitObject(stream)
+		return EmitABaseObject(objectType, size, stream)
 	case OBJ_OFS_DELTA:
-		return EmitAOffDelta(stream)
+		return EmitAOffDelta(size, stream)
 
 	case OBJ_REF_DELTA:
-		return EmitARefDelta(stream)
-
-	default:
-		slog.Error("Invalid object type detected", objectType)
+		return EmitARefDelta(size, stream)
 	}
-	return nil, nil
+	return nil, fmt.Errorf("Invalid object type detected %d", objectType)
 }
 
-func extractVarInt(nextByte byte, stream *bytes.Reader) (uint64, error) {
+func extractVarInt(nextByte byte, stream PackStream) (uint64, error) {
 	var sizeMask uint8 = 15             // 0b00001111
 	size := uint64(nextByte & sizeMask) // assuming that an object size can be represented in 64bit uint
 	shift := 4
@@ -103,7 +123,7 @@ func extractVarInt(nextByte byte, stream *bytes.Reader) (uint64, error) {
 	// other 7 bits are prepended to first 4 while first bit is 1
 	// for eg: byte array [144 15 120] will form integer bit sequence of 0b11110000 = 240
 	var err error
-	for int(nextByte) > 128 {
+	for nextByte >= 128 {
 
 		nextByte, err = stream.ReadByte()
 		if err != nil {
@@ -113,13 +133,12 @@ func extractVarInt(nextByte byte, stream *bytes.Reader) (uint64, error) {
 		currSize := uint64(nextByte & msbMask)
 		size = size | currSize<<shift
 		shift += 7
-		// break
 	}
 
 	return size, nil
 }
 
-func zlibDeflate(stream *bytes.Reader) ([]byte, error) {
+func zlibDeflate(stream PackStream) ([]byte, error) {
 	r, err := zlib.NewReader(stream)
 	if err != nil {
 		return nil, err
@@ -127,33 +146,52 @@ func zlibDeflate(stream *bytes.Reader) ([]byte, error) {
 	defer r.Close()
 	data, err := io.ReadAll(r)
 	if err != nil {
-		slog.Error(err.Error())
 		return nil, fmt.Errorf("Error on deflating zlib data %v", err)
 	}
 	return data, nil
 }
 
-func EmitACommitObject(stream *bytes.Reader) (*ObjectCommit, error) {
-	_, err := zlibDeflate(stream)
+// EmitABaseObject inflates a commit, tree, blob or tag entry
+func EmitABaseObject(objType ObjectType, size uint64, stream PackStream) (*RawObject, error) {
+	data, err := zlibDeflate(stream)
 	if err != nil {
-		return nil, fmt.Errorf("Error on emit a commit object %v", err)
+		return nil, fmt.Errorf("Error on emit a %s object %v", objType, err)
 	}
-	// dataStr := string(data[:10])
-	// fmt.Println(dataStr)
-	// fmt.Println(len(data), stream.Size()-int64(stream.Len()))
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("Size mismatch for %s object, expected %d got %d", objType, size, len(data))
+	}
+	return &RawObject{Type: objType, Data: data, Hash: HashObject(objType, data)}, nil
+}
 
-	return &ObjectCommit{}, nil
+// ObjectResolver looks up an object that is not part of the pack, this is
+// needed by thin packs whose REF_DELTA entries point at objects we already have
+type ObjectResolver func(hash [20]byte) (ObjectType, []byte, error)
+
+// PackEntry is what the .idx keeps of each object of the pack
+type PackEntry struct {
+	Hash   [20]byte
+	Offset uint64 // offset of the entry from the start of the packfile
+	CRC32  uint32 // checksum of the entry as stored in the pack
 }
 
 type Pack struct {
 	Packreader *bytes.Reader
+	data       []byte // the whole pack, header and trailing checksum included
 	size       uint32
 	version    uint32
 	hash       string
-	Objects    []Object
+	// Entries has one entry per object in pack order once ExtractObjects succeeded
+	Entries  []PackEntry
+	resolved *deltaResolver // reads the objects back, once extracted
+
+	// Resolver, when set, is consulted for REF_DELTA bases missing from the pack
+	Resolver ObjectResolver
 }
 
+const packHeaderSize = 12
+
 func NewPack(packBytes []byte) *Pack {
+	data := packBytes
 	packBytes = packBytes[4:]
 
 	versionNum := binary.BigEndian.Uint32(packBytes[:4])
@@ -166,20 +204,160 @@ func NewPack(packBytes []byte) *Pack {
 
 	return &Pack{
 		Packreader: bytes.NewReader(packBytes),
+		data:       data,
 		size:       totalSize,
 		version:    versionNum,
 		hash:       hash,
 	}
 }
 
+// Hash returns the hex encoded trailing checksum of the pack
+func (pck *Pack) Hash() string {
+	return pck.hash
+}
+
+// ExtractObjects reads every entry of the pack and resolves deltas to learn
+// the names of their objects. Only what the .idx needs is kept of each
+// entry in Entries, the complete objects are given by ReadObject and
+// Objects, which read them again from the pack
 func (pck *Pack) ExtractObjects() error {
+	entries := make([]PackEntry, 0, pck.size)
+	var deltas []int
 	for pck.Packreader.Len() > 0 {
-		_, err := EmitAGitObject(pck.Packreader)
+		offset := uint64(pck.Packreader.Size()-int64(pck.Packreader.Len())) + packHeaderSize
+		entry, err := EmitAGitObject(pck.Packreader)
 		if err != nil {
-			fmt.Println(err)
+			return fmt.Errorf("Error reading pack entry at offset %d: %v", offset, err)
+		}
+		if base, ok := entry.(*RawObject); ok {
+			entries = append(entries, PackEntry{Hash: base.Hash, Offset: offset})
+		} else {
+			deltas = append(deltas, len(entries))
+			entries = append(entries, PackEntry{Offset: offset})
+		}
+	}
+	if uint32(len(entries)) != pck.size {
+		return fmt.Errorf("Pack declares %d objects but contains %d", pck.size, len(entries))
+	}
+	for i := range entries {
+		end := uint64(len(pck.data) - 20)
+		if i+1 < len(entries) {
+			end = entries[i+1].Offset
+		}
+		entries[i].CRC32 = crc32.ChecksumIEEE(pck.data[entries[i].Offset:end])
+	}
+
+	r := newDeltaResolver(bytes.NewReader(pck.data), entries, deltas, pck.Resolver)
+	for _, i := range deltas {
+		if err := r.resolve(i); err != nil {
 			return err
 		}
 	}
+	pck.Entries = entries
+	pck.resolved = r
+	return nil
+}
 
+// ReadObject returns a complete commit, tree, blob or tag of the pack, or
+// ErrObjectNotInPack
+func (pck *Pack) ReadObject(hash [20]byte) (*RawObject, error) {
+	if pck.resolved == nil {
+		return nil, errors.New("Pack objects have not been extracted")
+	}
+	i, ok := pck.resolved.byHash[hash]
+	if !ok {
+		return nil, ErrObjectNotInPack
+	}
+	return pck.resolved.reader.readAt(pck.Entries[i].Offset, 0, pck.Resolver)
+}
+
+// Objects calls fn with every object of the pack, complete, in pack order
+func (pck *Pack) Objects(fn func(obj *RawObject) error) error {
+	if pck.resolved == nil {
+		return errors.New("Pack objects have not been extracted")
+	}
+	for _, entry := range pck.Entries {
+		obj, err := pck.resolved.reader.readAt(entry.Offset, 0, pck.Resolver)
+		if err != nil {
+			return err
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errDeltaCycle = errors.New("Delta chain refers back to itself")
+
+// deltaResolver names the objects of delta entries. It reads them through
+// an entryReader, which only caches delta bases, up to a number of bytes
+type deltaResolver struct {
+	entries  []PackEntry
+	named    []bool
+	visiting []bool
+	byHash   map[[20]byte]int
+	reader   *entryReader
+	external ObjectResolver
+}
+
+func newDeltaResolver(pack io.ReaderAt, entries []PackEntry, deltas []int, external ObjectResolver) *deltaResolver {
+	r := &deltaResolver{
+		entries:  entries,
+		named:    make([]bool, len(entries)),
+		visiting: make([]bool, len(entries)),
+		byHash:   make(map[[20]byte]int, len(entries)),
+		external: external,
+	}
+	r.reader = newEntryReader(pack, r.find)
+	for i := range r.named {
+		r.named[i] = true
+	}
+	for _, i := range deltas {
+		r.named[i] = false
+	}
+	for i, entry := range entries {
+		if r.named[i] {
+			r.byHash[entry.Hash] = i
+		}
+	}
+	return r
+}
+
+func (r *deltaResolver) resolve(i int) error {
+	if r.named[i] {
+		return nil
+	}
+	if r.visiting[i] {
+		return errDeltaCycle
+	}
+	r.visiting[i] = true
+	defer func() { r.visiting[i] = false }()
+
+	obj, err := r.reader.readAt(r.entries[i].Offset, 0, r.external)
+	if err != nil {
+		return err
+	}
+	r.entries[i].Hash = obj.Hash
+	r.named[i] = true
+	r.byHash[obj.Hash] = i
 	return nil
 }
+
+// find gives the offset of a REF_DELTA base in the pack. The base may be a
+// delta not named yet, so the others are resolved until it shows up
+func (r *deltaResolver) find(hash [20]byte) (uint64, bool) {
+	if i, ok := r.byHash[hash]; ok {
+		return r.entries[i].Offset, true
+	}
+	for i := range r.entries {
+		if r.named[i] || r.visiting[i] {
+			continue
+		}
+		// an entry that cannot be resolved fails again on its own turn
+		if r.resolve(i) == nil && r.entries[i].Hash == hash {
+			return r.entries[i].Offset, true
+		}
+	}
+	return 0, false
+}
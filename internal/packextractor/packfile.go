@@ -0,0 +1,251 @@
+package packextractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var ErrObjectNotInPack = errors.New("Object is not in the pack")
+
+// PackIndex is a parsed .idx file, see Pack.WriteIndex for the layout
+type PackIndex struct {
+	version   uint32
+	fanout    [256]uint32
+	names     []byte // count * 20 bytes
+	crcs      []byte // count * 4 bytes, version 2 only
+	offsets   []byte // count * 4 bytes for version 2, count * 24 entries for version 1
+	offsets64 []byte
+}
+
+// ReadPackIndex parses a version 1 or version 2 pack index
+func ReadPackIndex(path string) (*PackIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePackIndex(data)
+}
+
+func ParsePackIndex(data []byte) (*PackIndex, error) {
+	idx := &PackIndex{version: 1}
+	if bytes.HasPrefix(data, idxMagic) {
+		if len(data) < 8 {
+			return nil, errors.New("Pack index is truncated")
+		}
+		idx.version = binary.BigEndian.Uint32(data[4:8])
+		if idx.version != idxVersion {
+			return nil, fmt.Errorf("Unsupported pack index version %d", idx.version)
+		}
+		data = data[8:]
+	}
+	if len(data) < 256*4+40 {
+		return nil, errors.New("Pack index is truncated")
+	}
+	for i := range idx.fanout {
+		idx.fanout[i] = binary.BigEndian.Uint32(data[i*4:])
+	}
+	data = data[256*4:]
+	count := int(idx.fanout[255])
+
+	if idx.version == 1 {
+		// version 1 stores "<4 byte offset><20 byte name>" pairs
+		if len(data) < count*24+40 {
+			return nil, errors.New("Pack index is truncated")
+		}
+		idx.offsets = data[:count*24]
+		return idx, nil
+	}
+
+	if len(data) < count*28+40 {
+		return nil, errors.New("Pack index is truncated")
+	}
+	idx.names = data[:count*20]
+	idx.crcs = data[count*20 : count*24]
+	idx.offsets = data[count*24 : count*28]
+	idx.offsets64 = data[count*28 : len(data)-40]
+	return idx, nil
+}
+
+// Count is the number of objects in the pack
+func (idx *PackIndex) Count() int {
+	return int(idx.fanout[255])
+}
+
+// Name returns the i-th object name in sorted order
+func (idx *PackIndex) Name(i int) [20]byte {
+	var name [20]byte
+	if idx.version == 1 {
+		copy(name[:], idx.offsets[i*24+4:])
+	} else {
+		copy(name[:], idx.names[i*20:])
+	}
+	return name
+}
+
+func (idx *PackIndex) offset(i int) uint64 {
+	if idx.version == 1 {
+		return uint64(binary.BigEndian.Uint32(idx.offsets[i*24:]))
+	}
+	offset := binary.BigEndian.Uint32(idx.offsets[i*4:])
+	if offset&(1<<31) == 0 {
+		return uint64(offset)
+	}
+	large := int(offset &^ (1 << 31))
+	return binary.BigEndian.Uint64(idx.offsets64[large*8:])
+}
+
+// Find binary searches the names between the fanout bounds of the first byte
+func (idx *PackIndex) Find(hash [20]byte) (uint64, bool) {
+	lo := 0
+	if hash[0] > 0 {
+		lo = int(idx.fanout[hash[0]-1])
+	}
+	hi := int(idx.fanout[hash[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		name := idx.Name(lo + i)
+		return bytes.Compare(name[:], hash[:]) >= 0
+	})
+	if i < hi && idx.Name(i) == hash {
+		return idx.offset(i), true
+	}
+	return 0, false
+}
+
+// PackFile gives random access to the objects of a pack through its index
+type PackFile struct {
+	Index *PackIndex
+	pack  *os.File
+
+	// Resolver, when set, is consulted for REF_DELTA bases that live outside the pack
+	Resolver ObjectResolver
+
+	entries *entryReader
+}
+
+// OpenPackFile opens pack-X.pack given the path of pack-X.idx
+func OpenPackFile(idxPath string) (*PackFile, error) {
+	idx, err := ReadPackIndex(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	pack, err := os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return &PackFile{Index: idx, pack: pack, entries: newEntryReader(pack, idx.Find)}, nil
+}
+
+func (p *PackFile) Close() error {
+	return p.pack.Close()
+}
+
+// Has reports whether the pack contains the object
+func (p *PackFile) Has(hash [20]byte) bool {
+	_, ok := p.Index.Find(hash)
+	return ok
+}
+
+// ReadObject returns the reconstructed object, or ErrObjectNotInPack
+func (p *PackFile) ReadObject(hash [20]byte) (ObjectType, []byte, error) {
+	offset, ok := p.Index.Find(hash)
+	if !ok {
+		return OBJ_INVALID, nil, ErrObjectNotInPack
+	}
+	obj, err := p.entries.readAt(offset, 0, p.Resolver)
+	if err != nil {
+		return OBJ_INVALID, nil, err
+	}
+	return obj.Type, obj.Data, nil
+}
+
+// maxDeltaDepth guards against corrupt packs whose chains never end
+const maxDeltaDepth = 10000
+
+// entryReader reconstructs the entries of a pack at their offsets
+type entryReader struct {
+	pack io.ReaderAt
+	// find gives the offset of a REF_DELTA base that is in the pack
+	find func(hash [20]byte) (uint64, bool)
+
+	// bases caches the objects deltas were applied to, other objects are
+	// read once and not kept
+	bases *baseCache
+}
+
+func newEntryReader(pack io.ReaderAt, find func(hash [20]byte) (uint64, bool)) *entryReader {
+	return &entryReader{pack: pack, find: find, bases: newBaseCache(deltaBaseCacheLimit)}
+}
+
+// readAt reconstructs the entry at offset, resolver is consulted for
+// REF_DELTA bases that are not in the pack
+func (r *entryReader) readAt(offset uint64, depth int, resolver ObjectResolver) (*RawObject, error) {
+	if cached, ok := r.bases.get(offset); ok {
+		return cached, nil
+	}
+	if depth > maxDeltaDepth {
+		return nil, errDeltaCycle
+	}
+	stream := bufio.NewReader(io.NewSectionReader(r.pack, int64(offset), 1<<62))
+	entry, err := EmitAGitObject(stream)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading pack entry at offset %d: %v", offset, err)
+	}
+
+	var obj *RawObject
+	switch entry := entry.(type) {
+	case *RawObject:
+		obj = entry
+	case *OffDeltaObject:
+		if entry.offset > offset {
+			return nil, fmt.Errorf("OFS_DELTA at %d points before the start of the pack", offset)
+		}
+		base, err := r.readAt(offset-entry.offset, depth+1, resolver)
+		if err != nil {
+			return nil, err
+		}
+		r.bases.add(offset-entry.offset, base)
+		obj, err = applyDelta(base.Type, base.Data, entry.deltaData)
+		if err != nil {
+			return nil, fmt.Errorf("Error applying delta at offset %d: %v", offset, err)
+		}
+	case *RefDeltaObject:
+		var baseType ObjectType
+		var baseData []byte
+		if baseOffset, ok := r.find(entry.refName); ok {
+			base, err := r.readAt(baseOffset, depth+1, resolver)
+			if err != nil {
+				return nil, err
+			}
+			r.bases.add(baseOffset, base)
+			baseType, baseData = base.Type, base.Data
+		} else if resolver != nil {
+			baseType, baseData, err = resolver(entry.refName)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("REF_DELTA base %x not found", entry.refName)
+		}
+		obj, err = applyDelta(baseType, baseData, entry.refData)
+		if err != nil {
+			return nil, fmt.Errorf("Error applying delta at offset %d: %v", offset, err)
+		}
+	}
+	obj.Offset = offset
+	return obj, nil
+}
+
+func applyDelta(baseType ObjectType, base, delta []byte) (*RawObject, error) {
+	data, err := ApplyDelta(base, delta)
+	if err != nil {
+		return nil, err
+	}
+	return &RawObject{Type: baseType, Data: data, Hash: HashObject(baseType, data)}, nil
+}
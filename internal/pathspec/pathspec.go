@@ -0,0 +1,149 @@
+package pathspec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Pathspec selects paths of the work tree from command line arguments. A
+// plain argument matches the path itself and everything under it, one with
+// wildcards is matched against whole paths where "*" also crosses slashes
+type Pathspec struct {
+	items []*item
+}
+
+type item struct {
+	original string
+	path     string // slash separated and relative to the top, "" is everything
+	glob     *regexp.Regexp
+	matched  bool
+}
+
+// Parse resolves args given relative to the current directory against workTree
+func Parse(workTree string, args []string) (*Pathspec, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	ps := &Pathspec{}
+	for _, arg := range args {
+		if arg == "" {
+			return nil, fmt.Errorf("Empty string is not a valid pathspec")
+		}
+		abs := arg
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(cwd, arg)
+		}
+		rel, err := filepath.Rel(workTree, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: '%s' is outside repository at '%s'", arg, arg, workTree)
+		}
+		it := &item{original: arg, path: filepath.ToSlash(rel)}
+		if it.path == "." {
+			it.path = ""
+		}
+		if strings.ContainsAny(it.path, "*?[") {
+			it.glob = regexp.MustCompile("^" + globToRegexp(it.path) + "$")
+		}
+		ps.items = append(ps.items, it)
+	}
+	return ps, nil
+}
+
+// globToRegexp translates the wildcards of a pathspec, unlike a shell glob
+// "*" and "?" match slashes too
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// Empty is true when no pathspec was given, it then matches everything
+func (ps *Pathspec) Empty() bool {
+	return len(ps.items) == 0
+}
+
+// Match reports whether the slash separated path is selected, and remembers
+// which items matched something for Unmatched
+func (ps *Pathspec) Match(name string) bool {
+	if ps.Empty() {
+		return true
+	}
+	matched := false
+	for _, it := range ps.items {
+		if it.match(name) {
+			it.matched = true
+			matched = true
+		}
+	}
+	return matched
+}
+
+// MatchExact is like Match but a plain item only matches the path it names,
+// not what is under it
+func (ps *Pathspec) MatchExact(name string) bool {
+	for _, it := range ps.items {
+		if it.glob == nil && it.path == name || it.glob != nil && it.glob.MatchString(name) {
+			return true
+		}
+	}
+	return ps.Empty()
+}
+
+func (it *item) match(name string) bool {
+	if it.glob != nil {
+		return it.glob.MatchString(name)
+	}
+	return it.path == "" || name == it.path || strings.HasPrefix(name, it.path+"/")
+}
+
+// Literals returns the plain items as slash separated paths
+func (ps *Pathspec) Literals() []string {
+	var paths []string
+	for _, it := range ps.items {
+		if it.glob == nil {
+			paths = append(paths, it.path)
+		}
+	}
+	return paths
+}
+
+// Unmatched returns the arguments that did not match any path given to Match
+func (ps *Pathspec) Unmatched() []string {
+	var args []string
+	for _, it := range ps.items {
+		if !it.matched {
+			args = append(args, it.original)
+		}
+	}
+	return args
+}
@@ -0,0 +1,259 @@
+package refs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/flags"
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+const defaultFormat = "%(objectname) %(objecttype)\t%(refname)"
+
+type ForEachRef struct {
+	Fs       *flag.FlagSet
+	Repo     *repository.Repository
+	format   string
+	sortKeys flags.StringList
+	count    int
+	pointsAt string
+	quote    quoteStyle
+	patterns []string
+}
+
+func (f *ForEachRef) Initialize(args []string) error {
+	var shell, perl, python, tcl bool
+	f.Fs.StringVar(&f.format, "format", defaultFormat, "Interpolate %(fieldname) from each ref shown")
+	f.Fs.Var(&f.sortKeys, "sort", "Sort by this field, - in front reverses it. The last key given is the primary one")
+	f.Fs.IntVar(&f.count, "count", 0, "Stop after showing this many refs")
+	f.Fs.StringVar(&f.pointsAt, "points-at", "", "Only show the refs pointing at the given object")
+	f.Fs.BoolVar(&shell, "shell", false, "Quote the field values for the shell")
+	f.Fs.BoolVar(&perl, "perl", false, "Quote the field values for Perl")
+	f.Fs.BoolVar(&python, "python", false, "Quote the field values for Python")
+	f.Fs.BoolVar(&tcl, "tcl", false, "Quote the field values for Tcl")
+	err := f.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	styles := 0
+	for style, set := range map[quoteStyle]bool{quoteShell: shell, quotePerl: perl, quotePython: python, quoteTcl: tcl} {
+		if set {
+			f.quote = style
+			styles++
+		}
+	}
+	if styles > 1 {
+		return errors.New("--shell, --perl, --python and --tcl are mutually exclusive")
+	}
+	if f.count < 0 {
+		return fmt.Errorf("Invalid --count argument: `%d'", f.count)
+	}
+	f.patterns = f.Fs.Args()
+	return nil
+}
+
+func (f *ForEachRef) Usage() string {
+	return "git for-each-ref [--count=<count>] [--shell|--perl|--python|--tcl] [(--sort=<key>)...] [--format=<format>] [--points-at=<object>] [<pattern>...] : Output information on each ref"
+}
+
+func (f *ForEachRef) Run() error {
+	store := NewStore(f.Repo)
+	format, err := parseFormat(f.format)
+	if err != nil {
+		return err
+	}
+	var keys []sortKey
+	for _, key := range f.sortKeys {
+		k, err := parseSortKey(key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+
+	var pointsAt objectstore.Hash
+	if f.pointsAt != "" {
+		if pointsAt, err = store.resolveValue(f.pointsAt); err != nil {
+			return fmt.Errorf("Malformed object name %s", f.pointsAt)
+		}
+	}
+
+	all, err := store.List("refs/")
+	if err != nil {
+		return err
+	}
+	head, _ := store.HeadBranch()
+	var items []*refItem
+	for _, ref := range all {
+		if !matchPatterns(f.patterns, ref.Name) {
+			continue
+		}
+		item := &refItem{Ref: ref, store: store, head: head}
+		if !pointsAt.IsZero() {
+			peeled, _ := store.Peel(ref)
+			if ref.Hash != pointsAt && peeled != pointsAt {
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+
+	if err := sortItems(items, keys); err != nil {
+		return err
+	}
+	if f.count > 0 && len(items) > f.count {
+		items = items[:f.count]
+	}
+	for _, item := range items {
+		line, err := format.expand(item, f.quote)
+		if err != nil {
+			return err
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// matchPatterns is true when there are no patterns, or when one of them is
+// a leading part of name ending at a slash, or matches it as a glob
+func matchPatterns(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(name, pattern) &&
+			(len(name) == len(pattern) || strings.HasSuffix(pattern, "/") || name[len(pattern)] == '/') {
+			return true
+		}
+		if ignore.Wildmatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortKey is an atom to sort by, optionally in reverse
+type sortKey struct {
+	atom    atom
+	reverse bool
+	version bool // compare the numbers inside the values by their value
+}
+
+func parseSortKey(key string) (sortKey, error) {
+	var k sortKey
+	if strings.HasPrefix(key, "-") {
+		k.reverse = true
+		key = key[1:]
+	}
+	for _, prefix := range []string{"version:", "v:"} {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			k.version, key = true, rest
+			break
+		}
+	}
+	a, err := parseAtom(key)
+	if err != nil {
+		return k, err
+	}
+	k.atom = a
+	return k, nil
+}
+
+// sortItems orders items by the keys, the last one first, and then by name
+func sortItems(items []*refItem, keys []sortKey) error {
+	var failed error
+	compare := func(a, b *refItem) int {
+		for i := len(keys) - 1; i >= 0; i-- {
+			k := keys[i]
+			c, err := compareAtom(a, b, k)
+			if err != nil && failed == nil {
+				failed = err
+			}
+			if k.reverse {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.Name, b.Name)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return compare(items[i], items[j]) < 0
+	})
+	return failed
+}
+
+func compareAtom(a, b *refItem, k sortKey) (int, error) {
+	if k.atom.isDate() || k.atom.name == "objectsize" || k.atom.name == "numparent" {
+		x, err := a.number(k.atom)
+		if err != nil {
+			return 0, err
+		}
+		y, err := b.number(k.atom)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	x, err := a.value(k.atom)
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.value(k.atom)
+	if err != nil {
+		return 0, err
+	}
+	if k.version {
+		return compareVersions(x, y), nil
+	}
+	return strings.Compare(x, y), nil
+}
+
+// compareVersions compares the runs of digits in a and b as numbers and
+// everything else byte by byte, so that v1.10 comes after v1.9
+func compareVersions(a, b string) int {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			i, j := 0, 0
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			x, y := strings.TrimLeft(a[:i], "0"), strings.TrimLeft(b[:j], "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			a, b = a[i:], b[j:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,386 @@
+package refs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// atom is a %(name:modifier) field of a for-each-ref format. deref is set
+// for %(*name), which looks at the object an annotated tag points to
+type atom struct {
+	name     string
+	modifier string
+	deref    bool
+}
+
+var knownAtoms = map[string]bool{
+	"refname": true, "symref": true, "HEAD": true,
+	"objectname": true, "objecttype": true, "objectsize": true,
+	"tree": true, "parent": true, "numparent": true,
+	"object": true, "type": true, "tag": true,
+	"author": true, "authorname": true, "authoremail": true, "authordate": true,
+	"committer": true, "committername": true, "committeremail": true, "committerdate": true,
+	"tagger": true, "taggername": true, "taggeremail": true, "taggerdate": true,
+	"creator": true, "creatordate": true,
+	"subject": true, "body": true, "contents": true,
+}
+
+func parseAtom(field string) (atom, error) {
+	a := atom{}
+	if rest, ok := strings.CutPrefix(field, "*"); ok {
+		a.deref, field = true, rest
+	}
+	a.name, a.modifier, _ = strings.Cut(field, ":")
+	if !knownAtoms[a.name] {
+		return a, fmt.Errorf("Unknown field name: %s", field)
+	}
+	return a, nil
+}
+
+func (a atom) isDate() bool {
+	return strings.HasSuffix(a.name, "date")
+}
+
+// formatPart is either literal text or an atom
+type formatPart struct {
+	literal string
+	atom    *atom
+}
+
+type format []formatPart
+
+// parseFormat splits a format into its text and %(atom) fields. %% is a
+// percent sign and %xx the byte with that hexadecimal value
+func parseFormat(s string) (format, error) {
+	var parts format
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 >= len(s) {
+			literal.WriteByte(s[i])
+			continue
+		}
+		switch {
+		case s[i+1] == '%':
+			literal.WriteByte('%')
+			i++
+		case s[i+1] == '(':
+			end := strings.IndexByte(s[i:], ')')
+			if end < 0 {
+				return nil, fmt.Errorf("Malformed format string %s", s[i:])
+			}
+			a, err := parseAtom(s[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			if literal.Len() > 0 {
+				parts = append(parts, formatPart{literal: literal.String()})
+				literal.Reset()
+			}
+			parts = append(parts, formatPart{atom: &a})
+			i += end
+		default:
+			if i+2 < len(s) {
+				if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+					literal.WriteByte(byte(b))
+					i += 2
+					continue
+				}
+			}
+			literal.WriteByte('%')
+		}
+	}
+	if literal.Len() > 0 {
+		parts = append(parts, formatPart{literal: literal.String()})
+	}
+	return parts, nil
+}
+
+func (f format) expand(item *refItem, quote quoteStyle) (string, error) {
+	var b strings.Builder
+	for _, part := range f {
+		if part.atom == nil {
+			b.WriteString(part.literal)
+			continue
+		}
+		value, err := item.value(*part.atom)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(quote.apply(value))
+	}
+	return b.String(), nil
+}
+
+// refItem is a ref being formatted, its object and the one an annotated tag
+// points to are only read when a field needs them
+type refItem struct {
+	*Ref
+	store *Store
+	head  string // the branch HEAD is on
+
+	loaded  bool
+	object  *objectstore.Object
+	derefed *objectstore.Object
+}
+
+func (r *refItem) load(deref bool) (*objectstore.Object, error) {
+	if !r.loaded {
+		obj, err := r.store.repo.Objects.Get(r.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("Missing object %s for %s", r.Hash, r.Name)
+		}
+		r.object, r.loaded = obj, true
+		if obj.Type == packextractor.OBJ_TAG {
+			tag, err := object.ParseTag(obj.Data)
+			if err != nil {
+				return nil, err
+			}
+			if r.derefed, err = r.store.repo.Objects.Get(tag.Object); err != nil {
+				return nil, fmt.Errorf("Missing object %s for %s", tag.Object, r.Name)
+			}
+		}
+	}
+	if deref {
+		return r.derefed, nil
+	}
+	return r.object, nil
+}
+
+// value is the text of a field for this ref, empty when the field does not
+// apply to the kind of object
+func (r *refItem) value(a atom) (string, error) {
+	switch a.name {
+	case "refname":
+		return r.refName(r.Name, a.modifier)
+	case "symref":
+		return r.refName(r.Target, a.modifier)
+	case "HEAD":
+		if r.Name == r.head {
+			return "*", nil
+		}
+		return " ", nil
+	}
+
+	obj, err := r.load(a.deref)
+	if err != nil || obj == nil {
+		return "", err
+	}
+	switch a.name {
+	case "objectname":
+		return abbreviate(obj.Hash(), a.modifier)
+	case "objecttype":
+		return obj.Type.String(), nil
+	case "objectsize":
+		return strconv.Itoa(len(obj.Data)), nil
+	}
+
+	var commit *object.Commit
+	var tag *object.Tag
+	var message string
+	switch obj.Type {
+	case packextractor.OBJ_COMMIT:
+		if commit, err = object.ParseCommit(obj.Data); err != nil {
+			return "", err
+		}
+		message = commit.Message
+	case packextractor.OBJ_TAG:
+		if tag, err = object.ParseTag(obj.Data); err != nil {
+			return "", err
+		}
+		message = tag.Message
+	default:
+		return "", nil
+	}
+
+	switch a.name {
+	case "tree", "parent", "numparent":
+		if commit == nil {
+			return "", nil
+		}
+		if a.name == "tree" {
+			return abbreviate(commit.Tree, a.modifier)
+		}
+		if a.name == "numparent" {
+			return strconv.Itoa(len(commit.Parents)), nil
+		}
+		var parents []string
+		for _, p := range commit.Parents {
+			s, err := abbreviate(p, a.modifier)
+			if err != nil {
+				return "", err
+			}
+			parents = append(parents, s)
+		}
+		return strings.Join(parents, " "), nil
+	case "object", "type", "tag":
+		if tag == nil {
+			return "", nil
+		}
+		switch a.name {
+		case "object":
+			return abbreviate(tag.Object, a.modifier)
+		case "type":
+			return tag.TargetType.String(), nil
+		}
+		return tag.Name, nil
+	case "subject":
+		return object.Subject(message), nil
+	case "body":
+		return object.Body(message), nil
+	case "contents":
+		switch a.modifier {
+		case "":
+			return message, nil
+		case "subject":
+			return object.Subject(message), nil
+		case "body":
+			return object.Body(message), nil
+		}
+		return "", fmt.Errorf("Unrecognized %%(contents) argument: %s", a.modifier)
+	}
+	return signatureField(a, commit, tag)
+}
+
+// signatureField is one of the author, committer, tagger and creator
+// fields, the creator being the tagger of a tag and the committer otherwise
+func signatureField(a atom, commit *object.Commit, tag *object.Tag) (string, error) {
+	var sig *object.Signature
+	role := ""
+	for _, r := range []string{"author", "committer", "tagger", "creator"} {
+		if strings.HasPrefix(a.name, r) {
+			role = r
+		}
+	}
+	switch {
+	case role == "author" && commit != nil:
+		sig = &commit.Author
+	case (role == "committer" || role == "creator") && commit != nil:
+		sig = &commit.Committer
+	case (role == "tagger" || role == "creator") && tag != nil:
+		sig = tag.Tagger
+	}
+	if sig == nil {
+		return "", nil
+	}
+	switch strings.TrimPrefix(a.name, role) {
+	case "":
+		return sig.String(), nil
+	case "name":
+		return sig.Name, nil
+	case "email":
+		switch a.modifier {
+		case "":
+			return "<" + sig.Email + ">", nil
+		case "trim":
+			return sig.Email, nil
+		case "localpart":
+			local, _, _ := strings.Cut(sig.Email, "@")
+			return local, nil
+		}
+		return "", fmt.Errorf("Unrecognized email option: %s", a.modifier)
+	}
+	return identity.FormatDate(*sig, a.modifier)
+}
+
+// number is the value of a field compared numerically when sorting, dates
+// compare by their timestamp
+func (r *refItem) number(a atom) (int64, error) {
+	if a.isDate() {
+		raw, err := r.value(atom{name: a.name, modifier: "unix", deref: a.deref})
+		if err != nil || raw == "" {
+			return 0, err
+		}
+		return strconv.ParseInt(raw, 10, 64)
+	}
+	value, err := r.value(a)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// refName applies the short, lstrip and rstrip modifiers to a ref name
+func (r *refItem) refName(name, modifier string) (string, error) {
+	if modifier == "" || name == "" {
+		return name, nil
+	}
+	if modifier == "short" {
+		return r.store.Shorten(name), nil
+	}
+	kind, value, _ := strings.Cut(modifier, "=")
+	n, err := strconv.Atoi(value)
+	if err != nil || (kind != "lstrip" && kind != "strip" && kind != "rstrip") {
+		return "", fmt.Errorf("Unrecognized %%(refname) argument: %s", modifier)
+	}
+	parts := strings.Split(name, "/")
+	// a negative count keeps that many components instead
+	if n < 0 {
+		n = len(parts) + n
+		if n < 0 {
+			n = 0
+		}
+	}
+	if n >= len(parts) {
+		return "", nil
+	}
+	if kind == "rstrip" {
+		return strings.Join(parts[:len(parts)-n], "/"), nil
+	}
+	return strings.Join(parts[n:], "/"), nil
+}
+
+// abbreviate applies the short and short=<n> modifiers to an object name
+func abbreviate(hash objectstore.Hash, modifier string) (string, error) {
+	s := hash.String()
+	switch {
+	case modifier == "":
+		return s, nil
+	case modifier == "short":
+		return s[:7], nil
+	case strings.HasPrefix(modifier, "short="):
+		n, err := strconv.Atoi(strings.TrimPrefix(modifier, "short="))
+		if err != nil {
+			return "", fmt.Errorf("Positive value expected '%s'", modifier)
+		}
+		if n < 4 {
+			n = 4
+		}
+		if n > len(s) {
+			n = len(s)
+		}
+		return s[:n], nil
+	}
+	return "", fmt.Errorf("Unrecognized %%(objectname) argument: %s", modifier)
+}
+
+// quoteStyle makes field values safe to paste into a script
+type quoteStyle int
+
+const (
+	quoteNone quoteStyle = iota
+	quoteShell
+	quotePerl
+	quotePython
+	quoteTcl
+)
+
+func (q quoteStyle) apply(s string) string {
+	switch q {
+	case quoteShell:
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	case quotePerl:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	case quotePython:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`).Replace(s) + "'"
+	case quoteTcl:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "[", `\[`, "]", `\]`, "$", `\$`,
+			"{", `\{`, "}", `\}`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "\f", `\f`, "\v", `\v`).Replace(s) + `"`
+	}
+	return s
+}
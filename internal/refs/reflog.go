@@ -0,0 +1,288 @@
+package refs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+// ReflogEntry is one line of logs/<ref>, a change of the ref from Old to New:
+//
+//	<old> <new> A U Thor <author@example.com> 1700000000 +0100	commit: Add the parser
+type ReflogEntry struct {
+	Old, New objectstore.Hash
+	Who      object.Signature
+	Message  string
+}
+
+func (e *ReflogEntry) String() string {
+	line := fmt.Sprintf("%s %s %s", e.Old, e.New, e.Who)
+	if e.Message != "" {
+		line += "\t" + e.Message
+	}
+	return line
+}
+
+func parseReflogEntry(line string) (*ReflogEntry, error) {
+	line, message, _ := strings.Cut(line, "\t")
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("Malformed reflog line %q", line)
+	}
+	e := &ReflogEntry{Message: message}
+	var err error
+	if e.Old, err = objectstore.ParseHash(fields[0]); err != nil {
+		return nil, err
+	}
+	if e.New, err = objectstore.ParseHash(fields[1]); err != nil {
+		return nil, err
+	}
+	if e.Who, err = object.ParseSignature(fields[2]); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *Store) reflogPath(name string) string {
+	return s.repo.Path("logs", filepath.FromSlash(name))
+}
+
+func (s *Store) HasReflog(name string) bool {
+	fi, err := os.Stat(s.reflogPath(name))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Reflog reads the entries of the reflog of name, oldest first. A ref
+// without a reflog has none. Lines that cannot be parsed are skipped
+func (s *Store) Reflog(name string) ([]*ReflogEntry, error) {
+	f, err := os.Open(s.reflogPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*ReflogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if e, err := parseReflogEntry(scanner.Text()); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// WriteReflog replaces the reflog of name with entries, oldest first. The
+// ref is locked meanwhile so that no update is lost. With updateRef a ref
+// that is not symbolic is also set to the value of the newest entry
+func (s *Store) WriteReflog(name string, entries []*ReflogEntry, updateRef bool) error {
+	ref, err := s.lock(name)
+	if err != nil {
+		return err
+	}
+	defer ref.release()
+	lock, err := lockPath(s.reflogPath(name))
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintln(&buf, e)
+	}
+	if err := lock.commit(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if !updateRef || len(entries) == 0 || entries[len(entries)-1].New.IsZero() {
+		return nil
+	}
+	if current, err := s.Read(name); err == nil && current.IsSymbolic() {
+		return nil
+	}
+	return ref.commit([]byte(entries[len(entries)-1].New.String() + "\n"))
+}
+
+// ReflogNames lists every ref that has a reflog, HEAD first and then in
+// the order of their names
+func (s *Store) ReflogNames() ([]string, error) {
+	var names []string
+	if s.HasReflog("HEAD") {
+		names = append(names, "HEAD")
+	}
+	root := s.repo.Path("logs")
+	err := filepath.WalkDir(filepath.Join(root, "refs"), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	return names, err
+}
+
+// appendReflog adds a line to logs/<ref> when core.logAllRefUpdates or
+// create asks for it, or when the ref already has a reflog. The committer
+// is recorded as the one making the change
+func (s *Store) appendReflog(ref string, oldHash, newHash objectstore.Hash, message string, create bool) error {
+	path := s.reflogPath(ref)
+	cfg, err := s.repo.Config()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if !create && !s.logsByDefault(cfg, ref) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+	}
+	who, err := identity.Committer(cfg)
+	if err != nil {
+		return err
+	}
+
+	// the message is a single line with its whitespace collapsed
+	message = strings.Join(strings.Fields(message), " ")
+	e := &ReflogEntry{Old: oldHash, New: newHash, Who: who, Message: message}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, e)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// logsByDefault is whether core.logAllRefUpdates starts a reflog for ref.
+// Unset it means true outside of bare repositories, and true only logs HEAD,
+// branches, remote-tracking branches and notes
+func (s *Store) logsByDefault(cfg *config.Config, ref string) bool {
+	mode, ok := cfg.Get("core.logallrefupdates")
+	if !ok && !s.repo.IsBare() {
+		mode = "true"
+	}
+	if strings.EqualFold(mode, "always") {
+		return true
+	}
+	enabled, _ := config.ParseBool(mode)
+	return enabled && (ref == "HEAD" || strings.HasPrefix(ref, "refs/heads/") ||
+		strings.HasPrefix(ref, "refs/remotes/") || strings.HasPrefix(ref, "refs/notes/"))
+}
+
+// ReflogName is the ref whose reflog <name>@{...} reads. An empty name is
+// the current branch, or HEAD when it is detached, and short names are
+// expanded like for refs
+func (s *Store) ReflogName(name string) (string, error) {
+	if name == "" {
+		branch, err := s.HeadBranch()
+		if err != nil {
+			return "", err
+		}
+		if branch == "" {
+			return "HEAD", nil
+		}
+		return branch, nil
+	}
+	for _, rule := range revParseRules {
+		full := fmt.Sprintf(rule, name)
+		if CheckName(full) != nil {
+			continue
+		}
+		ref, err := s.Resolve(full)
+		if err != nil {
+			continue
+		}
+		if s.HasReflog(full) {
+			return full, nil
+		}
+		if ref.Name != full && s.HasReflog(ref.Name) {
+			return ref.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no reflog for %s", ErrNotFound, name)
+}
+
+// ResolveReflog is the value of a ref according to its reflog, for
+// <name>@{<selector>}: the selector is n for the value n changes ago, or a
+// date for the value the ref had then. A date older than the reflog gives
+// the oldest value known, with a warning
+func (s *Store) ResolveReflog(name, selector string) (objectstore.Hash, error) {
+	full, err := s.ReflogName(name)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	// messages name the ref as given, the branch or HEAD without one
+	label := name
+	if label == "" {
+		label = strings.TrimPrefix(full, "refs/heads/")
+	}
+	entries, err := s.Reflog(full)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+
+	if n, err := strconv.Atoi(selector); err == nil && n >= 0 {
+		if n == 0 {
+			if len(entries) == 0 {
+				return s.Hash(full)
+			}
+			return entries[len(entries)-1].New, nil
+		}
+		if n <= len(entries) && !entries[len(entries)-n].Old.IsZero() {
+			return entries[len(entries)-n].Old, nil
+		}
+		return objectstore.ZeroHash, fmt.Errorf("Log for '%s' only has %d entries", label, len(entries))
+	}
+
+	when, err := identity.ApproxDate(selector, time.Now())
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	if len(entries) == 0 {
+		return objectstore.ZeroHash, fmt.Errorf("Log for %s is empty", full)
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Who.When.After(when) {
+			continue
+		}
+		// the newest entry is the current value of the ref
+		if i == len(entries)-1 {
+			return s.Hash(full)
+		}
+		return entries[i].New, nil
+	}
+	oldest := entries[0]
+	fmt.Fprintf(os.Stderr, "warning: log for '%s' only goes back to %s\n", label,
+		oldest.Who.When.Format("Mon, 2 Jan 2006 15:04:05 ")+oldest.Who.Timezone())
+	if !oldest.Old.IsZero() {
+		return oldest.Old, nil
+	}
+	return oldest.New, nil
+}
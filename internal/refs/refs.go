@@ -0,0 +1,330 @@
+package refs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+var ErrNotFound = errors.New("Reference not found")
+
+// maxDepth is how many symbolic refs are followed before giving up, like git
+const maxDepth = 5
+
+// Ref is a single reference. A symbolic ref has a Target, its Hash is what
+// the target resolves to and stays zero when the target does not exist
+type Ref struct {
+	Name   string
+	Hash   objectstore.Hash
+	Target string
+	Peeled objectstore.Hash // from packed-refs, what an annotated tag points to
+}
+
+func (r *Ref) IsSymbolic() bool {
+	return r.Target != ""
+}
+
+// Store reads and writes the refs of a repository, as loose files under the
+// git directory and as lines of packed-refs. Loose refs win over packed ones
+type Store struct {
+	repo *repository.Repository
+}
+
+func NewStore(repo *repository.Repository) *Store {
+	return &Store{repo: repo}
+}
+
+func (s *Store) path(name string) string {
+	return s.repo.Path(filepath.FromSlash(name))
+}
+
+// Read looks up name without following it when it is symbolic
+func (s *Store) Read(name string) (*Ref, error) {
+	ref, err := s.readLoose(name)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return ref, err
+	}
+	packed, err := s.readPacked()
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range packed {
+		if ref.Name == name {
+			return ref, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+func (s *Store) readLoose(name string) (*Ref, error) {
+	data, err := os.ReadFile(s.path(name))
+	// a file on the way, like refs/heads/main for refs/heads/main/x, or a
+	// directory in place of the ref just mean there is no such ref
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || isDirError(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(string(data))
+	if target, ok := strings.CutPrefix(value, "ref:"); ok {
+		return &Ref{Name: name, Target: strings.TrimSpace(target)}, nil
+	}
+	hash, err := objectstore.ParseHash(value)
+	if err != nil {
+		return nil, fmt.Errorf("Broken ref %s: %v", name, err)
+	}
+	return &Ref{Name: name, Hash: hash}, nil
+}
+
+func isDirError(err error) bool {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		fi, statErr := os.Stat(pathErr.Path)
+		return statErr == nil && fi.IsDir()
+	}
+	return false
+}
+
+// readPacked parses packed-refs, where a ^ line carries the peeled value of
+// the tag before it:
+//
+//	# pack-refs with: peeled fully-peeled sorted
+//	<hash> refs/tags/v1.0
+//	^<hash>
+func (s *Store) readPacked() ([]*Ref, error) {
+	f, err := os.Open(s.repo.Path("packed-refs"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var refs []*Ref
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if len(refs) == 0 {
+				return nil, errors.New("Unexpected line in packed-refs: " + line)
+			}
+			peeled, err := objectstore.ParseHash(line[1:])
+			if err != nil {
+				return nil, fmt.Errorf("Unexpected line in packed-refs: %s", line)
+			}
+			refs[len(refs)-1].Peeled = peeled
+		default:
+			value, name, ok := strings.Cut(line, " ")
+			hash, err := objectstore.ParseHash(value)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("Unexpected line in packed-refs: %s", line)
+			}
+			refs = append(refs, &Ref{Name: name, Hash: hash})
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// Resolve follows name through symbolic refs and returns the ref it ends at,
+// named after the last ref on the way. When that ref does not exist, like
+// the branch of a new repository, the error wraps ErrNotFound and the ref
+// is still returned with a zero Hash
+func (s *Store) Resolve(name string) (*Ref, error) {
+	for depth := 0; depth <= maxDepth; depth++ {
+		ref, err := s.Read(name)
+		if errors.Is(err, ErrNotFound) && depth > 0 {
+			return &Ref{Name: name}, err
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ref.IsSymbolic() {
+			return ref, nil
+		}
+		name = ref.Target
+	}
+	return nil, fmt.Errorf("Symbolic ref %s is nested too deeply", name)
+}
+
+// Hash is the object name resolves to
+func (s *Store) Hash(name string) (objectstore.Hash, error) {
+	ref, err := s.Resolve(name)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	return ref.Hash, nil
+}
+
+// Exists is true for a ref that resolves to an object
+func (s *Store) Exists(name string) bool {
+	_, err := s.Hash(name)
+	return err == nil
+}
+
+// Head is the commit HEAD points to
+func (s *Store) Head() (objectstore.Hash, error) {
+	return s.Hash("HEAD")
+}
+
+// HeadBranch is the branch HEAD refers to, like refs/heads/main, even when
+// it has no commit yet. It is empty when HEAD is detached
+func (s *Store) HeadBranch() (string, error) {
+	ref, err := s.Read("HEAD")
+	if err != nil {
+		return "", err
+	}
+	return ref.Target, nil
+}
+
+// List returns the refs under refs/ whose name starts with prefix, sorted by
+// name. Symbolic refs are resolved, the ones that lead nowhere are left out
+func (s *Store) List(prefix string) ([]*Ref, error) {
+	byName := make(map[string]*Ref)
+	packed, err := s.readPacked()
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range packed {
+		byName[ref.Name] = ref
+	}
+
+	root := s.repo.Path("refs")
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(root), path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) || CheckName(name) != nil {
+			return nil
+		}
+		ref, err := s.readLoose(name)
+		if err != nil {
+			// like git, a ref file that cannot be parsed is skipped
+			return nil
+		}
+		byName[name] = ref
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*Ref
+	for name, ref := range byName {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if ref.IsSymbolic() {
+			resolved, err := s.Resolve(name)
+			if err != nil {
+				continue
+			}
+			ref.Hash = resolved.Hash
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+	return refs, nil
+}
+
+// Peel is what the annotated tag ref points to in the end, taken from
+// packed-refs when it is known there. It is false for refs to other objects
+func (s *Store) Peel(ref *Ref) (objectstore.Hash, bool) {
+	if !ref.Peeled.IsZero() {
+		return ref.Peeled, true
+	}
+	obj, err := s.repo.Objects.Get(ref.Hash)
+	if err != nil || obj.Type != packextractor.OBJ_TAG {
+		return objectstore.ZeroHash, false
+	}
+	_, peeled, err := object.Peel(s.repo.Objects, ref.Hash)
+	return peeled, err == nil
+}
+
+// revParseRules are the places a short ref name is looked for, in order
+var revParseRules = []string{
+	"%s",
+	"refs/%s",
+	"refs/tags/%s",
+	"refs/heads/%s",
+	"refs/remotes/%s",
+	"refs/remotes/%s/HEAD",
+}
+
+// Expand is the full name of the ref a short name like main or origin/main
+// refers to, using the first rule that finds an existing ref
+func (s *Store) Expand(short string) (string, bool) {
+	for _, rule := range revParseRules {
+		name := fmt.Sprintf(rule, short)
+		if CheckName(name) == nil && s.Exists(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Shorten is the shortest name that still refers to the ref name without
+// ambiguity, like main for refs/heads/main unless a tag main exists too
+func (s *Store) Shorten(name string) string {
+	// like git, refs/remotes/origin/HEAD stays origin/HEAD rather than origin,
+	// so the last rule is never used to shorten
+	for i := len(revParseRules) - 2; i > 0; i-- {
+		short, ok := matchRule(revParseRules[i], name)
+		if !ok {
+			continue
+		}
+		ambiguous := false
+		for j, rule := range revParseRules {
+			if j != i && s.refExists(fmt.Sprintf(rule, short)) {
+				ambiguous = true
+				break
+			}
+		}
+		if !ambiguous {
+			return short
+		}
+	}
+	return name
+}
+
+// refExists is true for a ref that can be read, even one that leads nowhere
+func (s *Store) refExists(name string) bool {
+	_, err := s.Read(name)
+	return err == nil
+}
+
+// matchRule extracts the short name from name when it has the form of rule
+func matchRule(rule, name string) (string, bool) {
+	before, after, _ := strings.Cut(rule, "%s")
+	if len(name) <= len(before)+len(after) || !strings.HasPrefix(name, before) || !strings.HasSuffix(name, after) {
+		return "", false
+	}
+	return name[len(before) : len(name)-len(after)], true
+}
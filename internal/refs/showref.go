@@ -0,0 +1,169 @@
+package refs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type ShowRef struct {
+	Fs          *flag.FlagSet
+	Repo        *repository.Repository
+	head        bool // show HEAD too
+	dereference bool // show what annotated tags point to as <tag>^{}
+	hashOnly    bool
+	abbrev      int
+	tags, heads bool
+	verify      bool // the patterns are exact ref names
+	quiet       bool
+	patterns    []string
+	store       *Store
+}
+
+func (s *ShowRef) Initialize(args []string) error {
+	s.Fs.BoolVar(&s.head, "head", false, "Show the HEAD reference, even if it would be filtered out")
+	s.Fs.BoolVar(&s.dereference, "d", false, "Dereference tags into object IDs as well")
+	s.Fs.BoolVar(&s.dereference, "dereference", false, "Same as -d")
+	s.Fs.BoolVar(&s.hashOnly, "s", false, "Only show the object name, not the ref name")
+	s.Fs.BoolVar(&s.hashOnly, "hash", false, "Same as -s, --hash=<n> also abbreviates to n characters")
+	s.Fs.IntVar(&s.abbrev, "abbrev", 0, "Abbreviate the object names to n characters, 7 without a value")
+	s.Fs.BoolVar(&s.tags, "tags", false, "Limit to tags")
+	s.Fs.BoolVar(&s.heads, "heads", false, "Limit to branches")
+	s.Fs.BoolVar(&s.verify, "verify", false, "Require an exact ref name")
+	s.Fs.BoolVar(&s.quiet, "q", false, "Only set the exit status")
+	s.Fs.BoolVar(&s.quiet, "quiet", false, "Same as -q")
+	err := s.Fs.Parse(abbrevValues(args))
+	if err != nil {
+		return err
+	}
+	s.patterns = s.Fs.Args()
+	if s.verify && len(s.patterns) == 0 {
+		return errors.New("--verify requires a reference")
+	}
+	return nil
+}
+
+// abbrevValues rewrites --hash=<n> and --abbrev, whose value is optional
+func abbrevValues(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return append(result, args[i:]...)
+		case arg == "--abbrev":
+			result = append(result, "--abbrev=7")
+		case strings.HasPrefix(arg, "--hash="):
+			result = append(result, "--hash", "--abbrev="+strings.TrimPrefix(arg, "--hash="))
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func (s *ShowRef) Usage() string {
+	return "git show-ref [--head] [-d] [-s | --hash[=<n>]] [--abbrev[=<n>]] [--tags] [--heads] [--verify] [-q] [<pattern>...] : List references in a local repository"
+}
+
+func (s *ShowRef) Run() error {
+	s.store = NewStore(s.Repo)
+	if s.verify {
+		return s.runVerify()
+	}
+
+	found := false
+	if s.head {
+		if hash, err := s.store.Head(); err == nil {
+			found = true
+			s.show(&Ref{Name: "HEAD", Hash: hash})
+		}
+	}
+	refs, err := s.store.List("refs/")
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if !s.matches(ref.Name) {
+			continue
+		}
+		found = true
+		s.show(ref)
+	}
+	if !found {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// matches filters by --heads and --tags, then by the patterns which match
+// whole trailing components of the name
+func (s *ShowRef) matches(name string) bool {
+	if s.heads || s.tags {
+		if !(s.heads && strings.HasPrefix(name, "refs/heads/") || s.tags && strings.HasPrefix(name, "refs/tags/")) {
+			return false
+		}
+	}
+	if len(s.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range s.patterns {
+		if name == pattern || strings.HasSuffix(name, "/"+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ShowRef) runVerify() error {
+	for _, name := range s.patterns {
+		var hash objectstore.Hash
+		var err error
+		if strings.HasPrefix(name, "refs/") || name == "HEAD" {
+			hash, err = s.store.Hash(name)
+		} else {
+			err = ErrNotFound
+		}
+		if err != nil {
+			if s.quiet {
+				os.Exit(1)
+			}
+			return fmt.Errorf("'%s' - not a valid ref", name)
+		}
+		s.show(&Ref{Name: name, Hash: hash})
+	}
+	return nil
+}
+
+func (s *ShowRef) show(ref *Ref) {
+	if s.quiet {
+		return
+	}
+	s.print(ref.Name, ref.Hash)
+	if !s.dereference {
+		return
+	}
+	if peeled, ok := s.store.Peel(ref); ok {
+		s.print(ref.Name+"^{}", peeled)
+	}
+}
+
+func (s *ShowRef) print(name string, hash objectstore.Hash) {
+	value := hash.String()
+	if s.abbrev > 0 && s.abbrev < len(value) {
+		// like git, never shorter than 4 characters
+		if s.abbrev < 4 {
+			s.abbrev = 4
+		}
+		value = value[:s.abbrev]
+	}
+	if s.hashOnly {
+		fmt.Println(value)
+		return
+	}
+	fmt.Printf("%s %s\n", value, name)
+}
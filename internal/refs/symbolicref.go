@@ -0,0 +1,88 @@
+package refs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type SymbolicRef struct {
+	Fs      *flag.FlagSet
+	Repo    *repository.Repository
+	message string
+	quiet   bool // fail silently when the ref is not symbolic
+	short   bool
+	delete  bool
+	args    []string
+}
+
+func (s *SymbolicRef) Initialize(args []string) error {
+	s.Fs.StringVar(&s.message, "m", "", "Reason for the update, recorded in the reflog")
+	s.Fs.BoolVar(&s.quiet, "q", false, "Do not complain when the ref is not symbolic")
+	s.Fs.BoolVar(&s.quiet, "quiet", false, "Same as -q")
+	s.Fs.BoolVar(&s.short, "short", false, "Show the target as a short name, like main")
+	s.Fs.BoolVar(&s.delete, "d", false, "Delete the symbolic ref")
+	s.Fs.BoolVar(&s.delete, "delete", false, "Same as -d")
+	err := s.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	s.args = s.Fs.Args()
+	switch {
+	case s.delete && len(s.args) != 1:
+		return errors.New("Expected the symbolic ref to delete")
+	case len(s.args) < 1 || len(s.args) > 2:
+		return errors.New("Expected a symbolic ref and an optional target")
+	}
+	return nil
+}
+
+func (s *SymbolicRef) Usage() string {
+	return "git symbolic-ref [-m <reason>] <name> <ref> | [-q] [--short] <name> | --delete [-q] <name> : Read, modify and delete symbolic refs"
+}
+
+func (s *SymbolicRef) Run() error {
+	store := NewStore(s.Repo)
+	name := s.args[0]
+	if len(s.args) == 2 {
+		target := s.args[1]
+		if name == "HEAD" && !strings.HasPrefix(target, "refs/") {
+			return errors.New("Refusing to point HEAD outside of refs/")
+		}
+		if CheckName(target) != nil {
+			return fmt.Errorf("Refusing to set '%s' to invalid ref '%s'", name, target)
+		}
+		return store.SetSymbolic(name, target, s.message)
+	}
+
+	ref, err := store.Read(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if ref == nil || !ref.IsSymbolic() {
+		if s.quiet {
+			os.Exit(1)
+		}
+		if s.delete {
+			return fmt.Errorf("Cannot delete %s, not a symbolic ref", name)
+		}
+		return fmt.Errorf("Ref %s is not a symbolic ref", name)
+	}
+
+	if s.delete {
+		if name == "HEAD" {
+			return fmt.Errorf("Deleting '%s' is not allowed", name)
+		}
+		return store.Delete(name, UpdateOptions{NoDeref: true, Message: s.message})
+	}
+	target := ref.Target
+	if s.short {
+		target = store.Shorten(target)
+	}
+	fmt.Println(target)
+	return nil
+}
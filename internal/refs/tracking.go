@@ -0,0 +1,67 @@
+package refs
+
+import (
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+)
+
+// Upstream is the ref the branch (like refs/heads/main) tracks, found from
+// branch.<name>.remote and branch.<name>.merge mapped through the fetch
+// refspecs of the remote. It is empty when the branch has no upstream
+func Upstream(cfg *config.Config, branch string) string {
+	name := strings.TrimPrefix(branch, "refs/heads/")
+	remote, ok := cfg.Get("branch." + name + ".remote")
+	if !ok {
+		return ""
+	}
+	merge, ok := cfg.Get("branch." + name + ".merge")
+	if !ok {
+		return ""
+	}
+	if remote == "." {
+		return merge
+	}
+	for _, refspec := range cfg.GetAll("remote." + remote + ".fetch") {
+		src, dst, ok := strings.Cut(strings.TrimPrefix(refspec, "+"), ":")
+		if !ok {
+			continue
+		}
+		if mapped, ok := mapRefspec(src, dst, merge); ok {
+			return mapped
+		}
+	}
+	return ""
+}
+
+// mapRefspec maps name through one side of a refspec to the other, a '*'
+// matches any part of the name
+func mapRefspec(src, dst, name string) (string, bool) {
+	prefix, suffix, glob := strings.Cut(src, "*")
+	if !glob {
+		return dst, name == src
+	}
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) || len(name) < len(prefix)+len(suffix) {
+		return "", false
+	}
+	matched := name[len(prefix) : len(name)-len(suffix)]
+	return strings.Replace(dst, "*", matched, 1), true
+}
+
+// TrackedBranch is the remote and the ref on it that a remote-tracking ref
+// (like refs/remotes/origin/main) is fetched from, for branch.<name>.remote
+// and branch.<name>.merge. It is false when no fetch refspec gives ref
+func TrackedBranch(cfg *config.Config, ref string) (string, string, bool) {
+	for _, remote := range cfg.Subsections("remote") {
+		for _, refspec := range cfg.GetAll("remote." + remote + ".fetch") {
+			src, dst, ok := strings.Cut(strings.TrimPrefix(refspec, "+"), ":")
+			if !ok {
+				continue
+			}
+			if merge, ok := mapRefspec(dst, src, ref); ok {
+				return remote, merge, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,534 @@
+package refs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// UpdateOptions tune Update and Delete
+type UpdateOptions struct {
+	// Old is the value the ref must have before the change, the zero hash
+	// meaning that it must not exist. Nil skips the check
+	Old *objectstore.Hash
+	// NoDeref changes a symbolic ref itself instead of the ref it points to
+	NoDeref bool
+	// Message is recorded in the reflog
+	Message string
+	// CreateReflog starts a reflog for the ref even when core.logAllRefUpdates
+	// would not
+	CreateReflog bool
+}
+
+// CheckName applies git's rules for ref names, one level names like HEAD or
+// FETCH_HEAD included
+func CheckName(name string) error {
+	bad := func() error {
+		return fmt.Errorf("'%s' is not a valid ref name", name)
+	}
+	if name == "" || name == "@" || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "@{") || strings.Contains(name, "..") {
+		return bad()
+	}
+	for _, component := range strings.Split(name, "/") {
+		if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".lock") {
+			return bad()
+		}
+	}
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return bad()
+		}
+	}
+	return nil
+}
+
+// Update points name at hash. Symbolic refs are followed to the ref they
+// end at unless opts.NoDeref is set. The ref is locked while it is checked
+// against opts.Old and written, and the change goes to its reflog and to the
+// one of HEAD when HEAD points to it
+func (s *Store) Update(name string, hash objectstore.Hash, opts UpdateOptions) error {
+	if CheckName(name) != nil {
+		return fmt.Errorf("Refusing to update ref with bad name '%s'", name)
+	}
+	target, err := s.target(name, opts.NoDeref)
+	if err != nil {
+		return err
+	}
+	if err := s.checkObject(target, hash); err != nil {
+		return err
+	}
+	if !s.refExists(target) {
+		if err := s.checkConflicts(target, ""); err != nil {
+			return err
+		}
+	}
+
+	lock, err := s.lock(target)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	current, err := s.current(target, opts.Old)
+	if err != nil {
+		return err
+	}
+	ref, _ := s.Read(target)
+	replacing := ref != nil && ref.IsSymbolic()
+	// like git, a ref that keeps its value is not written or logged, but the
+	// update is still logged through HEAD. A symbolic ref is always replaced,
+	// as when HEAD is detached at the commit it was on
+	if current != hash || replacing {
+		if err := lock.commit([]byte(hash.String() + "\n")); err != nil {
+			return err
+		}
+		if err := s.appendReflog(target, current, hash, opts.Message, opts.CreateReflog); err != nil {
+			return err
+		}
+	}
+	return s.logThroughHead(target, current, hash, opts.Message)
+}
+
+// Delete removes name, loose and packed, together with its reflog. It
+// follows symbolic refs like Update does
+func (s *Store) Delete(name string, opts UpdateOptions) error {
+	if CheckName(name) != nil {
+		return fmt.Errorf("Refusing to update ref with bad name '%s'", name)
+	}
+	target, err := s.target(name, opts.NoDeref)
+	if err != nil {
+		return err
+	}
+	lock, err := s.lock(target)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	current, err := s.current(target, opts.Old)
+	if err != nil {
+		return err
+	}
+	if err := s.removePacked(target); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(target)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.logThroughHead(target, current, objectstore.ZeroHash, opts.Message); err != nil {
+		return err
+	}
+	os.Remove(s.reflogPath(target))
+	// the lock is in the directory of the ref, which it would keep
+	lock.release()
+	s.pruneDirs(filepath.Dir(s.path(target)), s.repo.Path("refs"))
+	s.pruneDirs(filepath.Dir(s.reflogPath(target)), s.repo.Path("logs", "refs"))
+	return nil
+}
+
+// Rename moves the ref old to new together with its reflog, which gets an
+// entry for the move. Like git, the reflog of HEAD records a deletion and a
+// creation when HEAD points to old, making HEAD point to new is left to the
+// caller. An existing new ref is replaced. Both refs are locked, and old is
+// only removed once new is written
+func (s *Store) Rename(old, new, message string) error {
+	if CheckName(new) != nil {
+		return fmt.Errorf("Refusing to update ref with bad name '%s'", new)
+	}
+	ref, err := s.Read(old)
+	if err != nil {
+		return err
+	}
+	if ref.IsSymbolic() {
+		return fmt.Errorf("Refusing to rename symbolic ref '%s'", old)
+	}
+	if err := s.checkConflicts(new, old); err != nil {
+		return err
+	}
+	log, err := os.ReadFile(s.reflogPath(old))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	hasLog := err == nil
+	head, _ := s.HeadBranch()
+
+	oldLock, err := s.lock(old)
+	if err != nil {
+		return err
+	}
+	defer oldLock.release()
+	if old == new {
+		// git deletes and creates the ref again, which only shows in the logs
+		if err := s.appendReflog(new, ref.Hash, ref.Hash, message, false); err != nil {
+			return err
+		}
+		if head != old {
+			return nil
+		}
+		if err := s.appendReflog("HEAD", ref.Hash, objectstore.ZeroHash, message, false); err != nil {
+			return err
+		}
+		return s.appendReflog("HEAD", ref.Hash, ref.Hash, message, false)
+	}
+	// when one name is a directory of the other, the files of old are in
+	// the way of new: they go first, and come back if new cannot be written
+	nested := strings.HasPrefix(new, old+"/") || strings.HasPrefix(old, new+"/")
+	if nested {
+		if err := s.removeLoose(old); err != nil {
+			return err
+		}
+		oldLock.release()
+		s.pruneDirs(filepath.Dir(s.path(old)), s.repo.Path("refs"))
+		s.pruneDirs(filepath.Dir(s.reflogPath(old)), s.repo.Path("logs", "refs"))
+	}
+	if err := s.writeRenamed(new, ref.Hash, log, hasLog, message); err != nil {
+		if nested {
+			s.restore(old, ref.Hash, log, hasLog)
+		}
+		return err
+	}
+
+	if !nested {
+		if err := s.removeLoose(old); err != nil {
+			return err
+		}
+	}
+	if err := s.removePacked(old); err != nil {
+		return err
+	}
+	oldLock.release()
+	if !nested {
+		s.pruneDirs(filepath.Dir(s.path(old)), s.repo.Path("refs"))
+		s.pruneDirs(filepath.Dir(s.reflogPath(old)), s.repo.Path("logs", "refs"))
+	}
+	if head != old {
+		return nil
+	}
+	if err := s.appendReflog("HEAD", ref.Hash, objectstore.ZeroHash, message, false); err != nil {
+		return err
+	}
+	return s.appendReflog("HEAD", objectstore.ZeroHash, ref.Hash, message, false)
+}
+
+// writeRenamed writes the ref a rename creates, with the reflog of the
+// renamed one if it has one
+func (s *Store) writeRenamed(name string, hash objectstore.Hash, log []byte, hasLog bool, message string) error {
+	lock, err := s.lock(name)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	if err := s.writeReflogFile(name, log, hasLog); err != nil {
+		return err
+	}
+	if err := lock.commit([]byte(hash.String() + "\n")); err != nil {
+		return err
+	}
+	return s.appendReflog(name, hash, hash, message, false)
+}
+
+// restore puts back a ref removed by a rename that failed
+func (s *Store) restore(name string, hash objectstore.Hash, log []byte, hasLog bool) {
+	lock, err := s.lock(name)
+	if err != nil {
+		return
+	}
+	defer lock.release()
+	if s.writeReflogFile(name, log, hasLog) == nil {
+		lock.commit([]byte(hash.String() + "\n"))
+	}
+}
+
+// writeReflogFile replaces the reflog of name with log, or removes it
+func (s *Store) writeReflogFile(name string, log []byte, hasLog bool) error {
+	path := s.reflogPath(name)
+	if !hasLog {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		removeEmptyDirs(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	lock, err := lockPath(path)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	return lock.commit(log)
+}
+
+// removeLoose removes the loose file of name and its reflog
+func (s *Store) removeLoose(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.reflogPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// SetSymbolic makes name a symbolic ref to target. A non empty message is
+// logged in the reflog of name when target exists
+func (s *Store) SetSymbolic(name, target, message string) error {
+	if CheckName(name) != nil {
+		return fmt.Errorf("Refusing to update ref with bad name '%s'", name)
+	}
+	lock, err := s.lock(name)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	old, _ := s.Hash(name)
+	if err := lock.commit([]byte("ref: " + target + "\n")); err != nil {
+		return err
+	}
+	if message == "" {
+		return nil
+	}
+	hash, err := s.Hash(target)
+	if err != nil {
+		return nil
+	}
+	return s.appendReflog(name, old, hash, message, false)
+}
+
+// target is the ref an update of name changes
+func (s *Store) target(name string, noDeref bool) (string, error) {
+	if noDeref {
+		return name, nil
+	}
+	ref, err := s.Resolve(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	if ref == nil {
+		return name, nil
+	}
+	return ref.Name, nil
+}
+
+// current is the value of name, checked against old when it is given
+func (s *Store) current(name string, old *objectstore.Hash) (objectstore.Hash, error) {
+	var current objectstore.Hash
+	ref, err := s.Read(name)
+	switch {
+	case err == nil && ref.IsSymbolic():
+		// replaced with NoDeref, its value is the one it resolves to
+		current, _ = s.Hash(name)
+	case err == nil:
+		current = ref.Hash
+	case !errors.Is(err, ErrNotFound):
+		return current, err
+	}
+	if old == nil || *old == current {
+		return current, nil
+	}
+	switch {
+	case old.IsZero():
+		return current, fmt.Errorf("Cannot lock ref '%s': reference already exists", name)
+	case current.IsZero():
+		return current, fmt.Errorf("Cannot lock ref '%s': unable to resolve reference '%s'", name, name)
+	}
+	return current, fmt.Errorf("Cannot lock ref '%s': is at %s but expected %s", name, current, *old)
+}
+
+// checkObject refuses values that do not exist, and branches that would
+// point at something else than a commit
+func (s *Store) checkObject(name string, hash objectstore.Hash) error {
+	obj, err := s.repo.Objects.Get(hash)
+	if err != nil {
+		return fmt.Errorf("Cannot update ref '%s': trying to write ref '%s' with nonexistent object %s", name, name, hash)
+	}
+	if obj.Type != packextractor.OBJ_COMMIT && strings.HasPrefix(name, "refs/heads/") {
+		return fmt.Errorf("Cannot update ref '%s': trying to write non-commit object %s to branch '%s'", name, hash, name)
+	}
+	return nil
+}
+
+// checkConflicts refuses a new ref whose name is a directory of another ref,
+// or which would be the directory of one. The ref skip, about to go away,
+// is not one
+func (s *Store) checkConflicts(name, skip string) error {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		parent := strings.Join(parts[:i], "/")
+		if parent != "refs" && parent != skip && s.refExists(parent) {
+			return fmt.Errorf("Cannot lock ref '%s': '%s' exists; cannot create '%s'", name, parent, name)
+		}
+	}
+	children, err := s.List(name + "/")
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child.Name != skip {
+			return fmt.Errorf("Cannot lock ref '%s': '%s' exists; cannot create '%s'", name, child.Name, name)
+		}
+	}
+	return nil
+}
+
+// logThroughHead adds the update of a branch to the reflog of HEAD when HEAD
+// points to it
+func (s *Store) logThroughHead(name string, oldHash, newHash objectstore.Hash, message string) error {
+	if name == "HEAD" {
+		return nil
+	}
+	head, err := s.Resolve("HEAD")
+	if head == nil || head.Name != name {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.appendReflog("HEAD", oldHash, newHash, message, false)
+}
+
+// removePacked rewrites packed-refs without name, keeping everything else
+// as it was. The file is locked before it is read so that no concurrent
+// change is lost
+func (s *Store) removePacked(name string) error {
+	lock, err := s.lock("packed-refs")
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	data, err := os.ReadFile(s.repo.Path("packed-refs"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var kept bytes.Buffer
+	found, skipping := false, false
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "^") {
+			if !skipping {
+				kept.WriteString(line)
+			}
+			continue
+		}
+		_, ref, _ := strings.Cut(strings.TrimRight(line, "\n"), " ")
+		skipping = !strings.HasPrefix(line, "#") && ref == name
+		if skipping {
+			found = true
+			continue
+		}
+		kept.WriteString(line)
+	}
+	if !found {
+		return nil
+	}
+	return lock.commit(kept.Bytes())
+}
+
+// pruneDirs removes the directories from dir up to stop that became empty
+func (s *Store) pruneDirs(dir, stop string) {
+	for dir != stop && strings.HasPrefix(dir, stop) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// lockFile is a <name>.lock file next to the file it replaces once committed
+type lockFile struct {
+	file *os.File
+	path string
+	done bool // committed or released
+}
+
+// lock takes the lock of a ref. Like git, empty directories left where the
+// ref goes are removed
+func (s *Store) lock(name string) (*lockFile, error) {
+	path := s.path(name)
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() && removeEmptyDirs(path) != nil {
+		return nil, fmt.Errorf("Cannot lock ref '%s': there is a non-empty directory '%s' blocking reference '%s'", name, path, name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("Cannot lock ref '%s': %v", name, err)
+	}
+	lock, err := lockPath(path)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("Cannot lock ref '%s': Unable to create '%s.lock': File exists.\n\n"+
+			"Another git process seems to be running in this repository, or the lock file may be stale", name, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot lock ref '%s': %v", name, err)
+	}
+	return lock, nil
+}
+
+// removeEmptyDirs removes dir when it holds nothing but empty directories
+func removeEmptyDirs(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return fmt.Errorf("'%s' is not empty", dir)
+		}
+		if err := removeEmptyDirs(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(dir)
+}
+
+// lockPath takes the lock for any file, the error is the one of creating
+// <path>.lock
+func lockPath(path string) (*lockFile, error) {
+	f, err := os.OpenFile(path+".lock", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &lockFile{file: f, path: path}, nil
+}
+
+// commit writes data to the lock and renames it over the locked file
+func (l *lockFile) commit(data []byte) error {
+	if l.done {
+		return fmt.Errorf("the lock of '%s' was already given up", l.path)
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.file.Name(), l.path); err != nil {
+		return err
+	}
+	l.done = true
+	return nil
+}
+
+// release drops the lock when it was not committed, it may be called again
+func (l *lockFile) release() {
+	if l.done {
+		return
+	}
+	l.done = true
+	l.file.Close()
+	os.Remove(l.file.Name())
+}
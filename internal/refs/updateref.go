@@ -0,0 +1,107 @@
+package refs
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type UpdateRef struct {
+	Fs           *flag.FlagSet
+	Repo         *repository.Repository
+	message      string
+	delete       bool
+	noDeref      bool // change a symbolic ref itself instead of its target
+	createReflog bool
+	args         []string
+}
+
+func (u *UpdateRef) Initialize(args []string) error {
+	u.Fs.StringVar(&u.message, "m", "", "Reason for the update, recorded in the reflog")
+	u.Fs.BoolVar(&u.delete, "d", false, "Delete the ref, after checking its old value if given")
+	u.Fs.BoolVar(&u.noDeref, "no-deref", false, "Update the ref itself rather than the ref it points to")
+	u.Fs.BoolVar(&u.createReflog, "create-reflog", false, "Create a reflog for the ref even if core.logAllRefUpdates would not")
+	err := u.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	u.args = u.Fs.Args()
+	switch {
+	case u.delete && (len(u.args) < 1 || len(u.args) > 2):
+		return errors.New("Expected a ref and an optional old value")
+	case !u.delete && (len(u.args) < 2 || len(u.args) > 3):
+		return errors.New("Expected a ref, a new value and an optional old value")
+	case u.delete && u.createReflog:
+		return errors.New("--create-reflog does not make sense with -d")
+	}
+	return nil
+}
+
+func (u *UpdateRef) Usage() string {
+	return "git update-ref [-m <reason>] [--no-deref] (-d <ref> [<old-value>] | [--create-reflog] <ref> <new-value> [<old-value>]) : Update the object name stored in a ref safely"
+}
+
+func (u *UpdateRef) Run() error {
+	store := NewStore(u.Repo)
+	opts := UpdateOptions{NoDeref: u.noDeref, Message: u.message, CreateReflog: u.createReflog}
+	name, rest := u.args[0], u.args[1:]
+
+	var value objectstore.Hash
+	if !u.delete {
+		var err error
+		if value, err = store.resolveValue(rest[0]); err != nil {
+			return fmt.Errorf("%s: not a valid SHA1", rest[0])
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		// an empty old value means the ref must not exist yet
+		old := objectstore.ZeroHash
+		if rest[0] != "" {
+			var err error
+			if old, err = store.resolveValue(rest[0]); err != nil {
+				return fmt.Errorf("%s: not a valid old SHA1", rest[0])
+			}
+		}
+		opts.Old = &old
+	}
+
+	var err error
+	// setting the null object name is another way to delete
+	if u.delete || value.IsZero() {
+		if value.IsZero() && opts.Old != nil && opts.Old.IsZero() {
+			return errors.New("Refusing to delete a ref that must not exist")
+		}
+		err = store.Delete(name, opts)
+	} else {
+		err = store.Update(name, value, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("update_ref failed for ref '%s': %v", name, err)
+	}
+	return nil
+}
+
+// resolveValue accepts a full object name, the name of a ref that is
+// expanded like git does for short names, or <ref>@{n} and <ref>@{date} to
+// look in the reflog
+func (s *Store) resolveValue(arg string) (objectstore.Hash, error) {
+	if hash, err := objectstore.ParseHash(arg); err == nil {
+		return hash, nil
+	}
+	if i := strings.Index(arg, "@{"); i >= 0 && strings.HasSuffix(arg, "}") {
+		return s.ResolveReflog(arg[:i], arg[i+2:len(arg)-1])
+	}
+	if arg == "HEAD" {
+		return s.Head()
+	}
+	name, ok := s.Expand(arg)
+	if !ok {
+		return objectstore.ZeroHash, fmt.Errorf("%w: %s", ErrNotFound, arg)
+	}
+	return s.Hash(name)
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+var ErrNotARepository = errors.New("Not a git repository (or any of the parent directories): .git")
+
+// Repository locates the parts of a repository every command works with
+type Repository struct {
+	GitDir    string // the .git directory, or the repository itself when bare
+	CommonDir string // what linked worktrees share with the main one, GitDir otherwise
+	WorkTree  string // empty for a bare repository
+	ObjectDir string
+
+	// Objects is where the repository reads and writes objects, it defaults to
+	// the store on disk under ObjectDir and may be replaced by library users
+	Objects objectstore.ObjectStore
+
+	config *config.Config
+}
+
+// New describes a repository whose paths are already known, objectDir may be
+// empty to use the objects of the common directory
+func New(gitDir, workTree, objectDir string) *Repository {
+	commonDir := commonDirOf(gitDir)
+	if objectDir == "" {
+		objectDir = filepath.Join(commonDir, "objects")
+	}
+	return &Repository{
+		GitDir:    gitDir,
+		CommonDir: commonDir,
+		WorkTree:  workTree,
+		ObjectDir: objectDir,
+		Objects:   objectstore.Open(objectDir),
+	}
+}
+
+// commonDirOf is the directory a linked worktree's git directory names in
+// its commondir file, GIT_COMMON_DIR overrides it
+func commonDirOf(gitDir string) string {
+	if envDir := os.Getenv("GIT_COMMON_DIR"); envDir != "" {
+		cwd, _ := os.Getwd()
+		return absolute(cwd, envDir)
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	return absolute(gitDir, strings.TrimSpace(string(data)))
+}
+
+// Discover finds the repository the way git does: GIT_DIR when it is set,
+// otherwise the first directory from the current one upwards that has a .git
+// directory or file, or that is itself a bare repository. GIT_WORK_TREE and
+// GIT_OBJECT_DIRECTORY override what was found
+func Discover() (*Repository, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var gitDir, workTree string
+	if envDir := os.Getenv("GIT_DIR"); envDir != "" {
+		gitDir = absolute(cwd, envDir)
+		if !isGitDir(gitDir) {
+			return nil, fmt.Errorf("Not a git repository: '%s'", envDir)
+		}
+		// with GIT_DIR the current directory is the top of the work tree
+		workTree = cwd
+	} else {
+		gitDir, workTree, err = walkUp(cwd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if envTree := os.Getenv("GIT_WORK_TREE"); envTree != "" {
+		workTree = absolute(cwd, envTree)
+	}
+	objectDir := ""
+	if envObjects := os.Getenv("GIT_OBJECT_DIRECTORY"); envObjects != "" {
+		objectDir = absolute(cwd, envObjects)
+	}
+	return New(gitDir, workTree, objectDir), nil
+}
+
+// Open is the repository whose work tree is exactly dir, like a nested
+// repository or a submodule
+func Open(dir string) (*Repository, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return nil, ErrNotARepository
+	}
+	gitDir := dotGit
+	if !info.IsDir() {
+		gitDir, err = readGitFile(dotGit)
+		if err != nil {
+			return nil, err
+		}
+	} else if !isGitDir(gitDir) {
+		return nil, ErrNotARepository
+	}
+	return New(gitDir, dir, ""), nil
+}
+
+func walkUp(dir string) (string, string, error) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		if err == nil && info.IsDir() && isGitDir(dotGit) {
+			return dotGit, dir, nil
+		}
+		if err == nil && !info.IsDir() {
+			gitDir, err := readGitFile(dotGit)
+			if err != nil {
+				return "", "", err
+			}
+			return gitDir, dir, nil
+		}
+		if isGitDir(dir) {
+			return dir, "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", ErrNotARepository
+		}
+		dir = parent
+	}
+}
+
+// readGitFile follows a .git file, used by worktrees and submodules:
+//
+//	gitdir: ../.git/modules/lib
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return "", fmt.Errorf("Invalid gitfile format: %s", path)
+	}
+	gitDir := absolute(filepath.Dir(path), target)
+	if !isGitDir(gitDir) {
+		return "", fmt.Errorf("Not a git repository: %s", target)
+	}
+	return gitDir, nil
+}
+
+// isGitDir is a cheap check that dir has the HEAD and objects of a
+// repository, the objects of a linked worktree are in its common directory
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	if os.Getenv("GIT_OBJECT_DIRECTORY") != "" {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(commonDirOf(dir), "objects"))
+	return err == nil && info.IsDir()
+}
+
+func absolute(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// commonPaths are the parts of the git directory a linked worktree shares
+// through the common directory, like git's common_list. Under a shared
+// directory, the paths marked not shared stay with the worktree
+var commonPaths = []struct {
+	path   string
+	dir    bool
+	shared bool
+}{
+	{"branches", true, true},
+	{"common", true, true},
+	{"config", false, true},
+	{"hooks", true, true},
+	{"info", true, true},
+	{"info/sparse-checkout", false, false},
+	{"logs", true, true},
+	{"logs/HEAD", false, false},
+	{"logs/refs/bisect", true, false},
+	{"logs/refs/rewritten", true, false},
+	{"logs/refs/worktree", true, false},
+	{"lost-found", true, true},
+	{"objects", true, true},
+	{"packed-refs", false, true},
+	{"refs", true, true},
+	{"refs/bisect", true, false},
+	{"refs/rewritten", true, false},
+	{"refs/worktree", true, false},
+	{"remotes", true, true},
+	{"rr-cache", true, true},
+	{"shallow", false, true},
+	{"svn", true, true},
+	{"worktrees", true, true},
+}
+
+// Path returns a path inside the git directory, or inside the common
+// directory for what linked worktrees share
+func (r *Repository) Path(elem ...string) string {
+	dir := r.GitDir
+	if r.CommonDir != "" && r.CommonDir != r.GitDir && isShared(filepath.ToSlash(filepath.Join(elem...))) {
+		dir = r.CommonDir
+	}
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// isShared looks name up in commonPaths, the longest match decides
+func isShared(name string) bool {
+	shared, longest := false, -1
+	for _, p := range commonPaths {
+		if (name == p.path || p.dir && strings.HasPrefix(name, p.path+"/")) && len(p.path) > longest {
+			shared, longest = p.shared, len(p.path)
+		}
+	}
+	return shared
+}
+
+func (r *Repository) IsBare() bool {
+	return r.WorkTree == ""
+}
+
+// IndexPath is the staging index, GIT_INDEX_FILE overrides .git/index
+func (r *Repository) IndexPath() string {
+	if path := os.Getenv("GIT_INDEX_FILE"); path != "" {
+		return path
+	}
+	return r.Path("index")
+}
+
+// ReadIndex loads the staging index, a repository without one has an empty index
+func (r *Repository) ReadIndex() (*index.Index, error) {
+	return index.Read(r.IndexPath())
+}
+
+func (r *Repository) WriteIndex(idx *index.Index) error {
+	return idx.Write(r.IndexPath())
+}
+
+// RequireWorkTree fails for bare repositories, for the commands that need files
+func (r *Repository) RequireWorkTree() error {
+	if r.IsBare() {
+		return errors.New("This operation must be run in a work tree")
+	}
+	return nil
+}
+
+// Config returns the merged system, global and repository configuration, it
+// is read once and cached
+func (r *Repository) Config() (*config.Config, error) {
+	if r.config != nil {
+		return r.config, nil
+	}
+	c, err := config.Load(r.CommonDir)
+	if err != nil {
+		return nil, err
+	}
+	r.config = c
+	return c, nil
+}
+
+// ReloadConfig drops the cached configuration after a command changed it
+func (r *Repository) ReloadConfig() {
+	r.config = nil
+}
@@ -0,0 +1,334 @@
+package reset
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/codecrafters-io/git-starter-go/internal/checkout"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+)
+
+// Reset modes, what is brought to the target besides HEAD
+const (
+	Soft  = "soft"  // nothing
+	Mixed = "mixed" // the index
+	Hard  = "hard"  // the index and the work tree, dropping local changes
+	Keep  = "keep"  // the index and the work tree, keeping local changes
+)
+
+// modeFlag sets the mode it names, so that like git the last one given wins
+type modeFlag struct {
+	mode *string
+	name string
+}
+
+func (m modeFlag) String() string   { return "" }
+func (m modeFlag) IsBoolFlag() bool { return true }
+
+func (m modeFlag) Set(value string) error {
+	if value != "true" {
+		return fmt.Errorf("--%s takes no value", m.name)
+	}
+	*m.mode = m.name
+	return nil
+}
+
+type Reset struct {
+	Fs       *flag.FlagSet
+	Repo     *repository.Repository
+	mode     string // empty when not given, which is mixed
+	quiet    bool
+	args     []string
+	paths    []string // after --
+	dashDash bool
+	store    *refs.Store
+	resolver *revision.Resolver
+}
+
+func (r *Reset) Initialize(args []string) error {
+	r.Fs.Var(modeFlag{&r.mode, Soft}, Soft, "Only move HEAD, keeping the index and the working tree")
+	r.Fs.Var(modeFlag{&r.mode, Mixed}, Mixed, "Reset the index but not the working tree, the default")
+	r.Fs.Var(modeFlag{&r.mode, Hard}, Hard, "Reset the index and the working tree, dropping local changes")
+	r.Fs.Var(modeFlag{&r.mode, Keep}, Keep, "Reset the index and the working tree, keeping local changes")
+	r.Fs.BoolVar(&r.quiet, "q", false, "Only report errors")
+	r.Fs.BoolVar(&r.quiet, "quiet", false, "Same as -q")
+	args, r.paths, r.dashDash = revision.SplitDashDash(args)
+	if err := r.Fs.Parse(args); err != nil {
+		return err
+	}
+	r.args = r.Fs.Args()
+	return nil
+}
+
+func (r *Reset) Usage() string {
+	return "git reset [--soft | --mixed | --hard | --keep] [-q] [<commit>] | " +
+		"git reset [-q] [<tree-ish>] [--] <pathspec>... : Reset current HEAD to the specified state"
+}
+
+func (r *Reset) Run() error {
+	if err := r.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	r.store = refs.NewStore(r.Repo)
+	r.resolver = revision.NewResolver(r.Repo)
+
+	rev, paths, err := r.split()
+	if err != nil {
+		return err
+	}
+	if len(paths) > 0 || r.dashDash && rev == "" {
+		switch r.mode {
+		case Soft, Hard, Keep:
+			return fmt.Errorf("Cannot do %s reset with paths.", r.mode)
+		case Mixed:
+			fmt.Fprintln(os.Stderr, "warning: --mixed with paths is deprecated; use 'git reset -- <paths>' instead.")
+		}
+		return r.resetPaths(rev, paths)
+	}
+	return r.resetHead(rev)
+}
+
+// split tells the revision from the paths. Without --, the first argument is
+// a revision when it names one and paths follow it, otherwise every argument
+// must be a file of the work tree
+func (r *Reset) split() (string, []string, error) {
+	if r.dashDash {
+		switch len(r.args) {
+		case 0:
+			return "", r.paths, nil
+		case 1:
+			return r.args[0], r.paths, nil
+		}
+		return "", nil, fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.", r.args[1])
+	}
+	if len(r.args) == 0 {
+		return "", nil, nil
+	}
+	if _, err := r.resolver.Resolve(r.args[0]); err == nil || r.args[0] == "HEAD" {
+		if len(r.args) == 1 && revision.InWorkTree(r.args[0]) {
+			return "", nil, revision.AmbiguousError(r.args[0])
+		}
+		return r.args[0], r.args[1:], nil
+	}
+	for _, arg := range r.args {
+		if !revision.InWorkTree(arg) {
+			return "", nil, revision.UnknownArgError(arg)
+		}
+	}
+	return "", r.args, nil
+}
+
+// head is the commit HEAD is at, zero when its branch is unborn
+func (r *Reset) head() (objectstore.Hash, error) {
+	head, err := r.store.Head()
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return objectstore.ZeroHash, err
+	}
+	return head, nil
+}
+
+// resetPaths sets the index entries of the paths matching args to what the
+// tree of rev has, or removes them when it has nothing there. HEAD and the
+// work tree are left alone
+func (r *Reset) resetPaths(rev string, args []string) error {
+	var tree objectstore.Hash
+	if rev == "" || rev == "HEAD" {
+		head, err := r.head()
+		if err != nil {
+			return err
+		}
+		tree = head
+	} else {
+		hash, err := r.resolver.Resolve(rev)
+		if err != nil {
+			return fmt.Errorf("Failed to resolve '%s' as a valid tree.", rev)
+		}
+		if _, tree, err = object.PeelToTree(r.Repo.Objects, hash); err != nil {
+			return fmt.Errorf("Failed to resolve '%s' as a valid tree.", rev)
+		}
+	}
+	ps, err := pathspec.Parse(r.Repo.WorkTree, args)
+	if err != nil {
+		return err
+	}
+	idx, err := r.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if err := r.readTree(idx, tree, ps); err != nil {
+		return err
+	}
+	if err := r.Repo.WriteIndex(idx); err != nil {
+		return err
+	}
+	return r.printUnstaged()
+}
+
+// resetHead moves HEAD, or the branch it is on, to the commit rev names and
+// brings the index and the work tree along as the mode says
+func (r *Reset) resetHead(rev string) error {
+	mode := r.mode
+	if mode == "" {
+		mode = Mixed
+	}
+	head, err := r.head()
+	if err != nil {
+		return err
+	}
+	target := head
+	if rev == "" {
+		rev = "HEAD"
+	}
+	unborn := rev == "HEAD" && head.IsZero()
+	if !unborn {
+		if target, err = r.resolver.ResolveType(rev, packextractor.OBJ_COMMIT); err != nil {
+			if _, resolveErr := r.resolver.Resolve(rev); resolveErr == nil {
+				return fmt.Errorf("Could not parse object '%s'.", rev)
+			}
+			return fmt.Errorf("Failed to resolve '%s' as a valid revision.", rev)
+		}
+	}
+
+	idx, err := r.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if mode == Soft || mode == Keep {
+		if _, err := os.Stat(r.Repo.Path("MERGE_HEAD")); err == nil || len(idx.Unmerged()) > 0 {
+			return fmt.Errorf("Cannot do a %s reset in the middle of a merge.", mode)
+		}
+	}
+
+	switch mode {
+	case Mixed:
+		err = r.readTree(idx, target, nil)
+	case Hard:
+		err = checkout.Reset(r.Repo, idx, target)
+	case Keep:
+		err = checkout.Update(r.Repo, idx, head, target)
+		var conflicts *checkout.ConflictError
+		if errors.As(err, &conflicts) {
+			// like git, only the first path in the way is told
+			fmt.Fprintln(os.Stderr, "error: "+conflicts.Lines()[0])
+			return fmt.Errorf("Could not reset index file to revision '%s'.", rev)
+		}
+		if err == nil {
+			// like git, what the files keep is then unstaged
+			err = r.readTree(idx, target, nil)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if mode != Soft {
+		if err := r.Repo.WriteIndex(idx); err != nil {
+			return err
+		}
+	}
+
+	if !unborn {
+		if !head.IsZero() {
+			if err := r.store.Update("ORIG_HEAD", head, refs.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		if err := r.store.Update("HEAD", target, refs.UpdateOptions{Message: "reset: moving to " + rev}); err != nil {
+			return err
+		}
+	}
+	if mode != Soft {
+		for _, name := range []string{"MERGE_HEAD", "MERGE_MSG", "MERGE_MODE", "SQUASH_MSG"} {
+			os.Remove(r.Repo.Path(name))
+		}
+	}
+
+	switch {
+	case r.quiet:
+	case mode == Hard && !unborn:
+		commit, err := object.GetCommit(r.Repo.Objects, target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("HEAD is now at %s %s\n", revision.Abbreviate(r.Repo.Objects, target, 7), commit.Subject())
+	case mode == Mixed:
+		return r.printUnstaged()
+	}
+	return nil
+}
+
+// readTree makes the index entries matching ps, or all of them when it is
+// nil, the files of the tree of treeish. Entries that do not change keep
+// their stat data, the others get none as their files are not looked at
+func (r *Reset) readTree(idx *index.Index, treeish objectstore.Hash, ps *pathspec.Pathspec) error {
+	files, err := status.TreeFiles(r.Repo.Objects, treeish)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, e := range idx.Entries {
+		if !seen[e.Path] {
+			seen[e.Path] = true
+			names = append(names, e.Path)
+		}
+	}
+	for name := range files {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if ps != nil && !ps.Match(name) {
+			continue
+		}
+		entry, ok := files[name]
+		if !ok {
+			idx.Remove(name)
+			continue
+		}
+		if e := idx.Entry(name); e != nil && !e.IntentToAdd && e.Mode == entry.Mode && e.Hash == entry.Hash {
+			continue
+		}
+		idx.Add(&index.Entry{Path: name, Mode: entry.Mode, Hash: entry.Hash})
+	}
+	return nil
+}
+
+// printUnstaged lists the tracked paths whose files differ from the index,
+// like git does after resetting it
+func (r *Reset) printUnstaged() error {
+	if r.quiet {
+		return nil
+	}
+	st, err := status.Compute(r.Repo, status.Options{Untracked: status.UntrackedNo})
+	if err != nil {
+		return err
+	}
+	header := false
+	for _, e := range st.Entries {
+		letter := e.Unstaged
+		if e.Unmerged() {
+			letter = 'U'
+		}
+		if letter == ' ' {
+			continue
+		}
+		if !header {
+			fmt.Println("Unstaged changes after reset:")
+			header = true
+		}
+		fmt.Printf("%c\t%s\n", letter, e.Path)
+	}
+	return nil
+}
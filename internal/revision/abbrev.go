@@ -0,0 +1,153 @@
+package revision
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+// minAbbrev is the shortest prefix git accepts as an object name
+const minAbbrev = 4
+
+// errFound stops the iteration over the objects once a prefix is ambiguous
+var errFound = errors.New("found")
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// FindPrefix is the only object whose name starts with prefix. It fails
+// when no object or more than one has that prefix
+func FindPrefix(store objectstore.ObjectStore, prefix string) (objectstore.Hash, error) {
+	prefix = strings.ToLower(prefix)
+	var found objectstore.Hash
+	matches := 0
+	err := store.Iterate(func(hash objectstore.Hash) error {
+		if !strings.HasPrefix(hash.String(), prefix) || hash == found {
+			return nil
+		}
+		found = hash
+		matches++
+		if matches > 1 {
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && err != errFound {
+		return objectstore.ZeroHash, err
+	}
+	switch matches {
+	case 0:
+		return objectstore.ZeroHash, fmt.Errorf("%w %s", ErrInvalidName, prefix)
+	case 1:
+		return found, nil
+	}
+	return objectstore.ZeroHash, fmt.Errorf("Short object ID %s is ambiguous", prefix)
+}
+
+// Abbreviate is the shortest prefix of hash, at least length characters
+// long, that no other object of the store starts with
+func Abbreviate(store objectstore.ObjectStore, hash objectstore.Hash, length int) string {
+	name := hash.String()
+	if length < minAbbrev {
+		length = minAbbrev
+	}
+	if length >= len(name) {
+		return name
+	}
+	store.Iterate(func(other objectstore.Hash) error {
+		if other == hash {
+			return nil
+		}
+		s := other.String()
+		common := 0
+		for common < len(s) && s[common] == name[common] {
+			common++
+		}
+		if common >= length {
+			length = common + 1
+		}
+		return nil
+	})
+	if length > len(name) {
+		length = len(name)
+	}
+	return name[:length]
+}
+
+// Abbreviator abbreviates many names, like Abbreviate, against the names of
+// the objects of a store read and sorted once, when it is first used
+type Abbreviator struct {
+	store  objectstore.ObjectStore
+	hashes []objectstore.Hash
+	loaded bool
+}
+
+func NewAbbreviator(store objectstore.ObjectStore) *Abbreviator {
+	return &Abbreviator{store: store}
+}
+
+// Abbreviate is the shortest prefix of hash, at least length characters
+// long, that no other object of the store starts with. Only the names
+// next to hash in order can share a longer prefix with it
+func (a *Abbreviator) Abbreviate(hash objectstore.Hash, length int) string {
+	name := hash.String()
+	if length < minAbbrev {
+		length = minAbbrev
+	}
+	if length >= len(name) {
+		return name
+	}
+	if !a.loaded {
+		a.loaded = true
+		a.store.Iterate(func(other objectstore.Hash) error {
+			a.hashes = append(a.hashes, other)
+			return nil
+		})
+		sort.Slice(a.hashes, func(i, j int) bool {
+			return bytes.Compare(a.hashes[i][:], a.hashes[j][:]) < 0
+		})
+	}
+	i := sort.Search(len(a.hashes), func(i int) bool {
+		return bytes.Compare(a.hashes[i][:], hash[:]) >= 0
+	})
+	// the same object may be in more than one pack
+	before, after := i-1, i
+	for after < len(a.hashes) && a.hashes[after] == hash {
+		after++
+	}
+	for _, j := range []int{before, after} {
+		if j < 0 || j >= len(a.hashes) {
+			continue
+		}
+		if common := commonDigits(a.hashes[j], hash); common >= length {
+			length = common + 1
+		}
+	}
+	if length > len(name) {
+		length = len(name)
+	}
+	return name[:length]
+}
+
+// commonDigits is how many hexadecimal digits a and b start with in common
+func commonDigits(a, b objectstore.Hash) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i]>>4 == b[i]>>4 {
+				return 2*i + 1
+			}
+			return 2 * i
+		}
+	}
+	return 2 * len(a)
+}
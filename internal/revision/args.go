@@ -0,0 +1,38 @@
+package revision
+
+import (
+	"fmt"
+	"os"
+)
+
+const separateHint = "Use '--' to separate paths from revisions, like this:\n" +
+	"'git <command> [<revision>...] -- [<file>...]'"
+
+// SplitDashDash cuts args at the first "--", which the flag package drops
+// and which tells the paths after it from the revisions before it
+func SplitDashDash(args []string) (revs, paths []string, dashDash bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:], true
+		}
+	}
+	return args, nil, false
+}
+
+// InWorkTree is whether arg, relative to the current directory, exists
+func InWorkTree(arg string) bool {
+	_, err := os.Lstat(arg)
+	return err == nil
+}
+
+// AmbiguousError is for an argument without "--" that is both a revision
+// and a file of the work tree
+func AmbiguousError(arg string) error {
+	return fmt.Errorf("ambiguous argument '%s': both revision and filename\n%s", arg, separateHint)
+}
+
+// UnknownArgError is for an argument without "--" that is neither a
+// revision nor a file of the work tree
+func UnknownArgError(arg string) error {
+	return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.\n%s", arg, separateHint)
+}
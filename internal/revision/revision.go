@@ -0,0 +1,463 @@
+package revision
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// ErrInvalidName is wrapped by the errors for names that do not resolve to
+// anything, as opposed to a path missing from a tree or a short reflog
+var ErrInvalidName = errors.New("Not a valid object name")
+
+// peelTypes are the types <rev>^{<type>} can ask for
+var peelTypes = map[string]packextractor.ObjectType{
+	"commit": packextractor.OBJ_COMMIT,
+	"tree":   packextractor.OBJ_TREE,
+	"blob":   packextractor.OBJ_BLOB,
+	"tag":    packextractor.OBJ_TAG,
+}
+
+// Resolver turns revisions like main~2, v1.0^{tree}, HEAD:README or
+// @{upstream} into object names, the way git does
+type Resolver struct {
+	repo *repository.Repository
+	refs *refs.Store
+}
+
+func NewResolver(repo *repository.Repository) *Resolver {
+	return &Resolver{repo: repo, refs: refs.NewStore(repo)}
+}
+
+func invalid(name string) error {
+	return fmt.Errorf("%w %s", ErrInvalidName, name)
+}
+
+// Resolve is the object name refers to. Besides a revision it may be
+// <rev>:<path> for an entry of a tree, or :<path> and :<stage>:<path> for
+// one of the index
+func (r *Resolver) Resolve(name string) (objectstore.Hash, error) {
+	if strings.HasPrefix(name, ":") {
+		return r.resolveIndexPath(name[1:])
+	}
+	if i := pathSeparator(name); i >= 0 {
+		return r.resolveTreePath(name[:i], name[i+1:])
+	}
+	return r.resolveRev(name)
+}
+
+// ResolveType is Resolve followed by peeling to an object of type want,
+// like <name>^{<type>}
+func (r *Resolver) ResolveType(name string, want packextractor.ObjectType) (objectstore.Hash, error) {
+	hash, err := r.Resolve(name)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	return r.peel(name, hash, want)
+}
+
+// pathSeparator is the position of the colon between a revision and a path,
+// those inside braces like HEAD@{10:00} do not count
+func pathSeparator(name string) int {
+	depth := 0
+	for i, c := range name {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == ':' && depth == 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveRev handles the suffixes of a revision, the last one first:
+// ^{<type>} and ^{} peel, ^<n> is the n-th parent and ~<n> the n-th first
+// parent generation
+func (r *Resolver) resolveRev(name string) (objectstore.Hash, error) {
+	if strings.HasSuffix(name, "}") {
+		if i := strings.LastIndex(name, "^{"); i >= 0 {
+			hash, err := r.resolveRev(name[:i])
+			if err != nil {
+				return objectstore.ZeroHash, err
+			}
+			return r.peelTo(name, hash, name[i+2:len(name)-1])
+		}
+	}
+
+	end := len(name)
+	for end > 0 && name[end-1] >= '0' && name[end-1] <= '9' {
+		end--
+	}
+	if end == 0 || (name[end-1] != '~' && name[end-1] != '^') {
+		return r.basic(name)
+	}
+	n := 1
+	if end < len(name) {
+		var err error
+		if n, err = strconv.Atoi(name[end:]); err != nil {
+			return objectstore.ZeroHash, invalid(name)
+		}
+	}
+	base, err := r.resolveRev(name[:end-1])
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	hash, err := r.peel(name, base, packextractor.OBJ_COMMIT)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+
+	if name[end-1] == '^' {
+		if n == 0 {
+			return hash, nil
+		}
+		commit, err := object.GetCommit(r.repo.Objects, hash)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+		if n > len(commit.Parents) {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		return commit.Parents[n-1], nil
+	}
+	for ; n > 0; n-- {
+		commit, err := object.GetCommit(r.repo.Objects, hash)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+		if len(commit.Parents) == 0 {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		hash = commit.Parents[0]
+	}
+	return hash, nil
+}
+
+// basic resolves a name without suffixes: a full object name, a ref or
+// <ref>@{...}, and last an abbreviated object name
+func (r *Resolver) basic(name string) (objectstore.Hash, error) {
+	if len(name) == 40 && isHex(name) {
+		return objectstore.ParseHash(strings.ToLower(name))
+	}
+	if name == "@" {
+		name = "HEAD"
+	}
+	if strings.HasSuffix(name, "}") {
+		if i := strings.Index(name, "@{"); i >= 0 {
+			return r.resolveAt(name, name[:i], name[i+2:len(name)-1])
+		}
+	}
+	if full, ok := r.refs.Expand(name); ok {
+		return r.refs.Hash(full)
+	}
+	if len(name) >= minAbbrev && isHex(name) {
+		return FindPrefix(r.repo.Objects, name)
+	}
+	return objectstore.ZeroHash, invalid(name)
+}
+
+// resolveAt handles <ref>@{<selector>}: @{-n} is the branch checked out n
+// switches ago, @{upstream} the branch a branch tracks, and the reflog
+// answers @{n} and @{<date>}
+func (r *Resolver) resolveAt(name, base, selector string) (objectstore.Hash, error) {
+	if strings.HasPrefix(selector, "-") {
+		n, err := strconv.Atoi(selector[1:])
+		if err != nil || n < 1 || base != "" {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		previous, err := r.previousBranch(n)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+		return r.basic(previous)
+	}
+	if isUpstream(selector) {
+		upstream, err := r.upstream(base)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+		return r.refs.Hash(upstream)
+	}
+	hash, err := r.refs.ResolveReflog(base, selector)
+	if errors.Is(err, refs.ErrNotFound) {
+		return objectstore.ZeroHash, invalid(name)
+	}
+	return hash, err
+}
+
+func isUpstream(selector string) bool {
+	switch strings.ToLower(selector) {
+	case "u", "upstream":
+		return true
+	}
+	return false
+}
+
+// upstream is the ref the branch named by base tracks, base being empty or
+// HEAD for the current branch
+func (r *Resolver) upstream(base string) (string, error) {
+	var branch string
+	if base == "" || base == "HEAD" {
+		head, err := r.refs.HeadBranch()
+		if err != nil {
+			return "", err
+		}
+		if head == "" {
+			return "", errors.New("HEAD does not point to a branch")
+		}
+		branch = head
+	} else {
+		full, ok := r.refs.Expand(base)
+		if !ok || !strings.HasPrefix(full, "refs/heads/") {
+			return "", fmt.Errorf("No such branch: '%s'", base)
+		}
+		branch = full
+	}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return "", err
+	}
+	short := strings.TrimPrefix(branch, "refs/heads/")
+	upstream := refs.Upstream(cfg, branch)
+	if upstream == "" {
+		return "", fmt.Errorf("No upstream configured for branch '%s'", short)
+	}
+	if !r.refs.Exists(upstream) {
+		return "", fmt.Errorf("Upstream branch '%s' not stored as a remote-tracking branch", upstream)
+	}
+	return upstream, nil
+}
+
+// previousBranch is the branch, or commit when HEAD was detached, that was
+// left by the n-th newest switch recorded in the reflog of HEAD
+func (r *Resolver) previousBranch(n int) (string, error) {
+	entries, err := r.refs.Reflog("HEAD")
+	if err != nil {
+		return "", err
+	}
+	left := n
+	for i := len(entries) - 1; i >= 0; i-- {
+		rest, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		from, _, ok := strings.Cut(rest, " to ")
+		if !ok {
+			continue
+		}
+		if left--; left == 0 {
+			return from, nil
+		}
+	}
+	return "", invalid(fmt.Sprintf("@{-%d}", n))
+}
+
+// RefName is the full name of the ref a revision without suffixes stands
+// for, like refs/heads/main for main, HEAD or @{-1} on main, and HEAD when
+// it is detached. It is false for anything that is not a ref
+func (r *Resolver) RefName(name string) (string, bool) {
+	if name == "@" {
+		name = "HEAD"
+	}
+	if strings.HasSuffix(name, "}") {
+		i := strings.Index(name, "@{")
+		if i < 0 {
+			return "", false
+		}
+		base, selector := name[:i], name[i+2:len(name)-1]
+		if n, err := strconv.Atoi(strings.TrimPrefix(selector, "-")); err == nil && strings.HasPrefix(selector, "-") && base == "" {
+			previous, err := r.previousBranch(n)
+			if err != nil {
+				return "", false
+			}
+			return r.RefName(previous)
+		}
+		if isUpstream(selector) {
+			upstream, err := r.upstream(base)
+			return upstream, err == nil
+		}
+		return "", false
+	}
+	full, ok := r.refs.Expand(name)
+	if !ok {
+		return "", false
+	}
+	ref, err := r.refs.Resolve(full)
+	if err != nil {
+		return full, true
+	}
+	return ref.Name, true
+}
+
+// peelTo handles ^{<type>}, ^{} for whatever a tag finally points to and
+// ^{object} for any object that exists
+func (r *Resolver) peelTo(name string, hash objectstore.Hash, kind string) (objectstore.Hash, error) {
+	switch kind {
+	case "":
+		_, peeled, err := object.Peel(r.repo.Objects, hash)
+		if err != nil {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		return peeled, nil
+	case "object":
+		if !r.repo.Objects.Has(hash) {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		return hash, nil
+	}
+	want, ok := peelTypes[kind]
+	if !ok {
+		return objectstore.ZeroHash, invalid(name)
+	}
+	return r.peel(name, hash, want)
+}
+
+// peel follows tags, and commits to their tree, until it reaches an object
+// of type want. Like git, a commit asked to be a blob fails as a tree
+func (r *Resolver) peel(name string, hash objectstore.Hash, want packextractor.ObjectType) (objectstore.Hash, error) {
+	for {
+		obj, err := r.repo.Objects.Get(hash)
+		if err != nil {
+			return objectstore.ZeroHash, invalid(name)
+		}
+		if obj.Type == want {
+			return hash, nil
+		}
+		switch {
+		case obj.Type == packextractor.OBJ_TAG:
+			tag, err := object.ParseTag(obj.Data)
+			if err != nil {
+				return objectstore.ZeroHash, err
+			}
+			hash = tag.Object
+		case obj.Type == packextractor.OBJ_COMMIT:
+			commit, err := object.ParseCommit(obj.Data)
+			if err != nil {
+				return objectstore.ZeroHash, err
+			}
+			hash = commit.Tree
+		default:
+			return objectstore.ZeroHash, fmt.Errorf("%s: expected %s type, but the object dereferences to %s type", name, want, obj.Type)
+		}
+	}
+}
+
+// resolveTreePath is the entry at path in the tree of rev, or the tree
+// itself for an empty path
+func (r *Resolver) resolveTreePath(rev, p string) (objectstore.Hash, error) {
+	hash, err := r.ResolveType(rev, packextractor.OBJ_TREE)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	full, err := r.fullPath(p)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	if full == "" {
+		return hash, nil
+	}
+	for _, component := range strings.Split(full, "/") {
+		tree, err := object.GetTree(r.repo.Objects, hash)
+		if err != nil {
+			return objectstore.ZeroHash, r.missingPath(full, "'"+rev+"'")
+		}
+		entry, ok := tree.Find(component)
+		if !ok {
+			return objectstore.ZeroHash, r.missingPath(full, "'"+rev+"'")
+		}
+		hash = entry.Hash
+	}
+	return hash, nil
+}
+
+// resolveIndexPath is the staged blob of :<path>, or :<stage>:<path> for a
+// stage of a conflict
+func (r *Resolver) resolveIndexPath(p string) (objectstore.Hash, error) {
+	stage := 0
+	if len(p) >= 2 && p[0] >= '0' && p[0] <= '3' && p[1] == ':' {
+		stage, p = int(p[0]-'0'), p[2:]
+	}
+	full, err := r.fullPath(p)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	idx, err := r.repo.ReadIndex()
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	entries := idx.Stages(full)
+	for _, e := range entries {
+		if e.Stage == stage {
+			return e.Hash, nil
+		}
+	}
+	if len(entries) > 0 {
+		return objectstore.ZeroHash, fmt.Errorf("Path '%s' is in the index, but not at stage %d", full, stage)
+	}
+	return objectstore.ZeroHash, r.missingPath(full, "the index")
+}
+
+// missingPath tells apart a path that is only missing from where it was
+// looked up from one that does not exist at all
+func (r *Resolver) missingPath(p, where string) error {
+	if r.repo.WorkTree != "" {
+		if _, err := os.Lstat(filepath.Join(r.repo.WorkTree, filepath.FromSlash(p))); err == nil {
+			return fmt.Errorf("Path '%s' exists on disk, but not in %s", p, where)
+		}
+	}
+	if where == "the index" {
+		return fmt.Errorf("Path '%s' does not exist (neither on disk nor in the index)", p)
+	}
+	return fmt.Errorf("Path '%s' does not exist in %s", p, where)
+}
+
+// fullPath makes a path of <rev>:<path> relative to the top of the work
+// tree. Like git, only paths starting with ./ or ../ are taken relative to
+// the current directory
+func (r *Resolver) fullPath(p string) (string, error) {
+	if p != "." && p != ".." && !strings.HasPrefix(p, "./") && !strings.HasPrefix(p, "../") {
+		return strings.TrimSuffix(p, "/"), nil
+	}
+	prefix, err := Prefix(r.repo)
+	if err != nil {
+		return "", err
+	}
+	full := path.Join(prefix, p)
+	if full == ".." || strings.HasPrefix(full, "../") {
+		return "", fmt.Errorf("'%s' is outside repository", p)
+	}
+	if full == "." {
+		full = ""
+	}
+	return full, nil
+}
+
+// Prefix is the current directory relative to the top of the work tree,
+// with slashes and empty at the top or outside a work tree
+func Prefix(repo *repository.Repository) (string, error) {
+	if repo.WorkTree == "" {
+		return "", nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(repo.WorkTree, cwd)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
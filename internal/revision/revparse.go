@@ -0,0 +1,246 @@
+package revision
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type RevParse struct {
+	Fs               *flag.FlagSet
+	Repo             *repository.Repository
+	verify           bool // exactly one revision that must resolve
+	quiet            bool
+	short            int
+	abbrevRef        bool
+	symbolicFullName bool
+	queries          []string // questions about the repository, in the order asked
+	args             []string
+	resolver         *Resolver
+}
+
+func (rp *RevParse) Initialize(args []string) error {
+	rp.Fs.BoolVar(&rp.verify, "verify", false, "Require exactly one revision that can be resolved")
+	rp.Fs.BoolVar(&rp.quiet, "q", false, "With --verify, only set the exit status")
+	rp.Fs.BoolVar(&rp.quiet, "quiet", false, "Same as -q")
+	rp.Fs.IntVar(&rp.short, "short", 0, "Like --verify, but abbreviate the object name to n characters, 7 without a value")
+	rp.Fs.BoolVar(&rp.abbrevRef, "abbrev-ref", false, "Show the short name of refs, like main")
+	rp.Fs.BoolVar(&rp.symbolicFullName, "symbolic-full-name", false, "Show the full name of refs, like refs/heads/main")
+	for name, usage := range repositoryQueries {
+		rp.Fs.Var(&query{name: name, queries: &rp.queries}, name, usage)
+	}
+	// like git, options may follow the revisions
+	rest := shortValues(args)
+	for len(rest) > 0 {
+		if err := rp.Fs.Parse(rest); err != nil {
+			return err
+		}
+		parsed := len(rest) - rp.Fs.NArg()
+		if parsed > 0 && rest[parsed-1] == "--" {
+			rp.args = append(rp.args, rest[parsed-1:]...)
+			break
+		}
+		rest = rp.Fs.Args()
+		if len(rest) > 0 {
+			rp.args = append(rp.args, rest[0])
+			rest = rest[1:]
+		}
+	}
+	if rp.short > 0 {
+		rp.verify = true
+	}
+	return nil
+}
+
+// shortValues rewrites --short, whose value is optional
+func shortValues(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		switch arg {
+		case "--":
+			return append(result, args[i:]...)
+		case "--short":
+			result = append(result, "--short=7")
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// repositoryQueries are the options asking about the repository itself
+var repositoryQueries = map[string]string{
+	"git-dir":             "Show the path of the repository",
+	"show-toplevel":       "Show the path of the top of the work tree",
+	"show-prefix":         "Show the current directory relative to the top of the work tree",
+	"is-inside-work-tree": "Show whether the current directory is inside the work tree",
+	"is-bare-repository":  "Show whether the repository is bare",
+}
+
+// query is a boolean flag that records when it was given
+type query struct {
+	name    string
+	queries *[]string
+}
+
+func (q *query) String() string {
+	return ""
+}
+
+func (q *query) Set(string) error {
+	*q.queries = append(*q.queries, q.name)
+	return nil
+}
+
+func (q *query) IsBoolFlag() bool {
+	return true
+}
+
+func (rp *RevParse) Usage() string {
+	return "git rev-parse [--verify [-q] | --short[=<n>]] [--abbrev-ref | --symbolic-full-name] [--git-dir] [--show-toplevel] [--show-prefix] [--is-inside-work-tree] [--is-bare-repository] [<rev>...] [-- <path>...] : Pick out and massage revisions"
+}
+
+func (rp *RevParse) Run() error {
+	rp.resolver = NewResolver(rp.Repo)
+	if err := rp.showRepository(); err != nil {
+		return err
+	}
+	if rp.verify {
+		return rp.runVerify()
+	}
+
+	for i, arg := range rp.args {
+		if arg == "--" {
+			for _, p := range rp.args[i:] {
+				fmt.Println(p)
+			}
+			break
+		}
+		if err := rp.showArg(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rp *RevParse) runVerify() error {
+	var hash objectstore.Hash
+	err := errors.New("Needed a single revision")
+	if len(rp.args) == 1 {
+		var resolveErr error
+		if hash, resolveErr = rp.resolver.Resolve(rp.args[0]); resolveErr == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		if rp.quiet {
+			os.Exit(1)
+		}
+		return err
+	}
+	rp.show("", rp.args[0], hash)
+	return nil
+}
+
+// showArg prints what one argument stands for: ^<rev> excludes a revision,
+// <a>..<b> is b and ^a with HEAD for a missing side, and a file is printed
+// as is
+func (rp *RevParse) showArg(arg string) error {
+	if from, to, ok := strings.Cut(arg, ".."); ok && !strings.HasPrefix(to, ".") {
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		fromHash, err := rp.resolver.Resolve(from)
+		if err == nil {
+			var toHash objectstore.Hash
+			if toHash, err = rp.resolver.Resolve(to); err == nil {
+				rp.show("", to, toHash)
+				rp.show("^", from, fromHash)
+				return nil
+			}
+		}
+	}
+
+	prefix, name := "", arg
+	if strings.HasPrefix(arg, "^") {
+		prefix, name = "^", arg[1:]
+	}
+	hash, err := rp.resolver.Resolve(name)
+	if errors.Is(err, ErrInvalidName) {
+		if InWorkTree(arg) {
+			fmt.Println(arg)
+			return nil
+		}
+		return fmt.Errorf("Ambiguous argument '%s': unknown revision or path not in the working tree.", arg)
+	}
+	if err != nil {
+		return err
+	}
+	rp.show(prefix, name, hash)
+	return nil
+}
+
+// show prints a resolved revision, as a ref name with --abbrev-ref and
+// --symbolic-full-name, which print nothing for other revisions
+func (rp *RevParse) show(prefix, name string, hash objectstore.Hash) {
+	if rp.abbrevRef || rp.symbolicFullName {
+		full, ok := rp.resolver.RefName(name)
+		if !ok {
+			return
+		}
+		if rp.abbrevRef {
+			full = refs.NewStore(rp.Repo).Shorten(full)
+		}
+		fmt.Println(prefix + full)
+		return
+	}
+	value := hash.String()
+	if rp.short > 0 {
+		value = Abbreviate(rp.Repo.Objects, hash, rp.short)
+	}
+	fmt.Println(prefix + value)
+}
+
+// showRepository answers the questions about the repository itself
+func (rp *RevParse) showRepository() error {
+	for _, query := range rp.queries {
+		switch query {
+		case "git-dir":
+			dir := rp.Repo.GitDir
+			if cwd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(cwd, dir); err == nil && rel == ".git" {
+					dir = rel
+				}
+			}
+			fmt.Println(dir)
+		case "show-toplevel":
+			if !rp.Repo.IsBare() {
+				fmt.Println(rp.Repo.WorkTree)
+			}
+		case "show-prefix":
+			prefix, err := Prefix(rp.Repo)
+			if err != nil {
+				return err
+			}
+			if prefix != "" {
+				prefix += "/"
+			}
+			fmt.Println(prefix)
+		case "is-inside-work-tree":
+			fmt.Println(!rp.Repo.IsBare())
+		case "is-bare-repository":
+			fmt.Println(rp.Repo.IsBare())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,626 @@
+package revwalk
+
+import (
+	"io"
+	"strings"
+)
+
+// graphState is what the next line of the graph draws
+type graphState int
+
+const (
+	graphPadding    graphState = iota // branch lines carry on unchanged
+	graphSkip                         // "...", commits were left out since the last one
+	graphPreCommit                    // room made for an octopus merge
+	graphCommit                       // the line of the commit itself
+	graphPostMerge                    // the edges out of a merge
+	graphCollapsing                   // branch lines moving left to their columns
+)
+
+// Graph draws the history beside log output with ASCII lines, the way git
+// log --graph does. It follows git's graph.c: each commit gets a column,
+// branch lines going to the same parent collapse into one, and a merge fans
+// out into a line for each parent
+type Graph struct {
+	walker *Walker
+	out    io.Writer
+	// LeftRight marks commits with their side of a symmetric range
+	LeftRight bool
+
+	commit          *Commit
+	numParents      int
+	width           int
+	expansionRow    int
+	state           graphState
+	prevState       graphState
+	commitIndex     int
+	prevCommitIndex int
+	mergeLayout     int
+	edgesAdded      int
+	prevEdgesAdded  int
+
+	columns    []*Commit
+	newColumns []*Commit
+	// mapping has where each screen column of the line being drawn goes,
+	// the index of a column in newColumns or -1
+	mapping     []int
+	mappingSize int
+	// oldMapping is the mapping of the line before, its size with it
+	oldMapping     []int
+	oldMappingSize int
+}
+
+// NewGraph draws the graph of the commits walker shows to out
+func NewGraph(walker *Walker, out io.Writer) *Graph {
+	return &Graph{walker: walker, out: out, state: graphPadding, prevState: graphPadding}
+}
+
+// interestingParents are the parents of the commit that are shown, only
+// the first with FirstParent
+func (g *Graph) interestingParents() []*Commit {
+	var parents []*Commit
+	for _, p := range g.commit.Parents {
+		if show, err := g.walker.Shows(p); err == nil && show {
+			parents = append(parents, p)
+		}
+		if g.walker.FirstParent {
+			break
+		}
+	}
+	return parents
+}
+
+// Update moves the graph to the next commit shown, before it is printed
+func (g *Graph) Update(c *Commit) {
+	g.commit = c
+	g.numParents = len(g.interestingParents())
+	g.prevCommitIndex = g.commitIndex
+	g.updateColumns()
+	g.expansionRow = 0
+
+	switch {
+	case g.state != graphPadding:
+		g.state = graphSkip
+	case g.needsPreCommitLine():
+		g.state = graphPreCommit
+	default:
+		g.state = graphCommit
+	}
+}
+
+func (g *Graph) updateState(s graphState) {
+	g.prevState = g.state
+	g.state = s
+}
+
+func (g *Graph) findNewColumn(c *Commit) int {
+	for i, col := range g.newColumns {
+		if col == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateColumns works out the columns after the commit and the mapping of
+// the screen columns from the line of the commit to them
+func (g *Graph) updateColumns() {
+	g.columns, g.newColumns = g.newColumns, g.columns[:0]
+	maxNewColumns := len(g.columns) + g.numParents
+
+	// the mapping the last line drew stays, for the line of the commit to
+	// smooth branch lines that were collapsing
+	g.mapping, g.oldMapping = g.oldMapping, g.mapping
+	g.oldMappingSize = g.mappingSize
+	g.mappingSize = 2 * maxNewColumns
+	if len(g.mapping) < g.mappingSize {
+		g.mapping = grow(g.mapping, g.mappingSize)
+		g.oldMapping = grow(g.oldMapping, g.mappingSize)
+	}
+	for i := 0; i < g.mappingSize; i++ {
+		g.mapping[i] = -1
+	}
+
+	g.width = 0
+	g.prevEdgesAdded = g.edgesAdded
+	g.edgesAdded = 0
+
+	seenThis := false
+	for i := 0; i <= len(g.columns); i++ {
+		var colCommit *Commit
+		if i == len(g.columns) {
+			if seenThis {
+				break
+			}
+			colCommit = g.commit
+		} else {
+			colCommit = g.columns[i]
+		}
+
+		if colCommit == g.commit {
+			seenThis = true
+			g.commitIndex = i
+			g.mergeLayout = -1
+			for _, p := range g.interestingParents() {
+				g.insertIntoNewColumns(p, i)
+			}
+			// the commit takes up 2 screen columns, even without parents
+			if g.numParents == 0 {
+				g.width += 2
+			}
+		} else {
+			g.insertIntoNewColumns(colCommit, -1)
+		}
+	}
+
+	for g.mappingSize > 1 && g.mapping[g.mappingSize-1] < 0 {
+		g.mappingSize--
+	}
+}
+
+// grow makes a mapping longer, keeping what it has
+func grow(mapping []int, size int) []int {
+	grown := make([]int, size)
+	for i := range grown {
+		grown[i] = -1
+	}
+	copy(grown, mapping)
+	return grown
+}
+
+// insertIntoNewColumns adds the column of c unless it has one already,
+// and maps the screen column of the edge to it. idx is the column of the
+// commit for its parents, -1 for the other branch lines
+func (g *Graph) insertIntoNewColumns(c *Commit, idx int) {
+	i := g.findNewColumn(c)
+	if i < 0 {
+		i = len(g.newColumns)
+		g.newColumns = append(g.newColumns, c)
+	}
+
+	var mappingIdx int
+	switch {
+	case g.numParents > 1 && idx > -1 && g.mergeLayout == -1:
+		// the first parent of a merge picks how the merge is drawn, from
+		// whether it is left of the merge
+		dist := idx - i
+		shift := 1
+		if dist > 1 {
+			shift = 2*dist - 3
+		}
+		g.mergeLayout = 1
+		if dist > 0 {
+			g.mergeLayout = 0
+		}
+		g.edgesAdded = g.numParents + g.mergeLayout - 2
+		mappingIdx = g.width + (g.mergeLayout-1)*shift
+		g.width += 2 * g.mergeLayout
+	case g.edgesAdded > 0 && g.width >= 2 && i == g.mapping[g.width-2]:
+		// the edge joins the column just left of it at once
+		mappingIdx = g.width - 2
+		g.edgesAdded = -1
+	default:
+		mappingIdx = g.width
+		g.width += 2
+	}
+	g.mapping[mappingIdx] = i
+}
+
+func (g *Graph) numDashedParents() int {
+	return g.numParents + g.mergeLayout - 3
+}
+
+func (g *Graph) numExpansionRows() int {
+	return g.numDashedParents() * 2
+}
+
+func (g *Graph) needsPreCommitLine() bool {
+	return g.numParents >= 3 && g.commitIndex < len(g.columns)-1 && g.expansionRow < g.numExpansionRows()
+}
+
+// isMappingCorrect is whether every branch line is in its column already
+func (g *Graph) isMappingCorrect() bool {
+	for i := 0; i < g.mappingSize; i++ {
+		if target := g.mapping[i]; target >= 0 && target != i/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// isCommitFinished is whether all the lines of the commit were drawn
+func (g *Graph) isCommitFinished() bool {
+	return g.state == graphPadding
+}
+
+// nextLine draws the next line of the graph, and tells whether it was the
+// line of the commit
+func (g *Graph) nextLine(line *strings.Builder) bool {
+	if g.commit == nil {
+		return false
+	}
+	shownCommitLine := false
+	switch g.state {
+	case graphPadding:
+		g.paddingLine(line)
+	case graphSkip:
+		g.skipLine(line)
+	case graphPreCommit:
+		g.preCommitLine(line)
+	case graphCommit:
+		g.commitLine(line)
+		shownCommitLine = true
+	case graphPostMerge:
+		g.postMergeLine(line)
+	case graphCollapsing:
+		g.collapsingLine(line)
+	}
+	g.padHorizontally(line)
+	return shownCommitLine
+}
+
+func (g *Graph) padHorizontally(line *strings.Builder) {
+	if line.Len() < g.width {
+		line.WriteString(strings.Repeat(" ", g.width-line.Len()))
+	}
+}
+
+func (g *Graph) paddingLine(line *strings.Builder) {
+	for range g.newColumns {
+		line.WriteString("| ")
+	}
+}
+
+func (g *Graph) skipLine(line *strings.Builder) {
+	line.WriteString("...")
+	if g.needsPreCommitLine() {
+		g.updateState(graphPreCommit)
+	} else {
+		g.updateState(graphCommit)
+	}
+}
+
+// preCommitLine makes room for the dashes of an octopus merge, moving the
+// branch lines right of it further right
+func (g *Graph) preCommitLine(line *strings.Builder) {
+	seenThis := false
+	for i, col := range g.columns {
+		switch {
+		case col == g.commit:
+			seenThis = true
+			line.WriteByte('|')
+			line.WriteString(strings.Repeat(" ", g.expansionRow))
+		case seenThis && g.expansionRow == 0:
+			// carry on the "\" of the merge just drawn
+			if g.prevState == graphPostMerge && g.prevCommitIndex < i {
+				line.WriteByte('\\')
+			} else {
+				line.WriteByte('|')
+			}
+		case seenThis && g.expansionRow > 0:
+			line.WriteByte('\\')
+		default:
+			line.WriteByte('|')
+		}
+		line.WriteByte(' ')
+	}
+
+	g.expansionRow++
+	if !g.needsPreCommitLine() {
+		g.updateState(graphCommit)
+	}
+}
+
+// commitChar is the mark of the commit on its line, its side with LeftRight
+func (g *Graph) commitChar() string {
+	if g.LeftRight {
+		return sideMark(g.commit)
+	}
+	return "*"
+}
+
+// octopusDashes joins the commit to its parents past the second one
+func (g *Graph) octopusDashes(line *strings.Builder) {
+	dashed := g.numDashedParents()
+	for i := 0; i < dashed; i++ {
+		line.WriteByte('-')
+		if i == dashed-1 {
+			line.WriteByte('.')
+		} else {
+			line.WriteByte('-')
+		}
+	}
+}
+
+func (g *Graph) commitLine(line *strings.Builder) {
+	seenThis := false
+	for i := 0; i <= len(g.columns); i++ {
+		var colCommit *Commit
+		if i == len(g.columns) {
+			if seenThis {
+				break
+			}
+			colCommit = g.commit
+		} else {
+			colCommit = g.columns[i]
+		}
+
+		switch {
+		case colCommit == g.commit:
+			seenThis = true
+			line.WriteString(g.commitChar())
+			if g.numParents > 2 {
+				g.octopusDashes(line)
+			}
+		case seenThis && g.edgesAdded > 1:
+			line.WriteByte('\\')
+		case seenThis && g.edgesAdded == 1:
+			// the first line of a right-skewed merge carries on a "\" drawn
+			// by the merge before it
+			if g.prevState == graphPostMerge && g.prevEdgesAdded > 0 && g.prevCommitIndex < i {
+				line.WriteByte('\\')
+			} else {
+				line.WriteByte('|')
+			}
+		case g.prevState == graphCollapsing && 2*i+1 < g.oldMappingSize && g.oldMapping[2*i+1] == i && g.mapping[2*i] < i:
+			// a branch line that was collapsing into this column goes on
+			line.WriteByte('/')
+		default:
+			line.WriteByte('|')
+		}
+		line.WriteByte(' ')
+	}
+
+	g.padHorizontally(line)
+	switch {
+	case g.numParents > 1:
+		g.updateState(graphPostMerge)
+	case g.isMappingCorrect():
+		g.updateState(graphPadding)
+	default:
+		g.updateState(graphCollapsing)
+	}
+}
+
+var mergeChars = []byte{'/', '|', '\\'}
+
+// postMergeLine draws the edges from a merge to each of its parents
+func (g *Graph) postMergeLine(line *strings.Builder) {
+	parents := g.interestingParents()
+	var parentCol *Commit
+	seenThis := false
+	for i := 0; i <= len(g.columns); i++ {
+		var colCommit *Commit
+		if i == len(g.columns) {
+			if seenThis {
+				break
+			}
+			colCommit = g.commit
+		} else {
+			colCommit = g.columns[i]
+		}
+
+		switch {
+		case colCommit == g.commit:
+			seenThis = true
+			idx := g.mergeLayout
+			for j := range parents {
+				line.WriteByte(mergeChars[idx])
+				if idx == 2 {
+					if g.edgesAdded > 0 || j < len(parents)-1 {
+						line.WriteByte(' ')
+					}
+				} else {
+					idx++
+				}
+			}
+			if g.edgesAdded == 0 {
+				line.WriteByte(' ')
+			}
+		case seenThis:
+			if g.edgesAdded > 0 {
+				line.WriteByte('\\')
+			} else {
+				line.WriteByte('|')
+			}
+			line.WriteByte(' ')
+		default:
+			line.WriteByte('|')
+			if g.mergeLayout != 0 || i != g.commitIndex-1 {
+				if parentCol != nil {
+					line.WriteByte('_')
+				} else {
+					line.WriteByte(' ')
+				}
+			}
+		}
+		if len(parents) > 0 && colCommit == parents[0] {
+			parentCol = colCommit
+		}
+	}
+
+	g.padHorizontally(line)
+	if g.isMappingCorrect() {
+		g.updateState(graphPadding)
+	} else {
+		g.updateState(graphCollapsing)
+	}
+}
+
+// collapsingLine moves each branch line one step towards its column, and
+// at most one of them along a horizontal "_" line
+func (g *Graph) collapsingLine(line *strings.Builder) {
+	usedHorizontal := false
+	horizontalEdge := -1
+	horizontalEdgeTarget := -1
+
+	g.mapping, g.oldMapping = g.oldMapping, g.mapping
+	g.oldMappingSize = g.mappingSize
+	for i := 0; i < g.mappingSize; i++ {
+		g.mapping[i] = -1
+	}
+
+	for i := 0; i < g.mappingSize; i++ {
+		target := g.oldMapping[i]
+		if target < 0 {
+			continue
+		}
+		// branch lines only ever move left
+		switch {
+		case target*2 == i:
+			g.mapping[i] = target
+		case g.mapping[i-1] < 0:
+			// nothing to the left, move one step left
+			g.mapping[i-1] = target
+			if horizontalEdge == -1 {
+				horizontalEdge = i
+				horizontalEdgeTarget = target
+				for j := target*2 + 3; j < i-2; j += 2 {
+					g.mapping[j] = target
+				}
+			}
+		case g.mapping[i-1] == target:
+			// the line to the left goes to the same parent, they merge
+		default:
+			// cross over the line to the left
+			g.mapping[i-2] = target
+			if horizontalEdge == -1 {
+				horizontalEdgeTarget = target
+				horizontalEdge = i - 1
+				for j := target*2 + 3; j < i-2; j += 2 {
+					g.mapping[j] = target
+				}
+			}
+		}
+	}
+
+	// the new mapping may be one smaller
+	if g.mapping[g.mappingSize-1] < 0 {
+		g.mappingSize--
+	}
+
+	for i := 0; i < g.mappingSize; i++ {
+		target := g.mapping[i]
+		switch {
+		case target < 0:
+			line.WriteByte(' ')
+		case target*2 == i:
+			line.WriteByte('|')
+		case target == horizontalEdgeTarget && i != horizontalEdge-1:
+			// only the first segment carries on into the next line
+			if i != target*2+3 {
+				g.mapping[i] = -1
+			}
+			usedHorizontal = true
+			line.WriteByte('_')
+		default:
+			if usedHorizontal && i < horizontalEdge {
+				g.mapping[i] = -1
+			}
+			line.WriteByte('/')
+		}
+	}
+
+	g.padHorizontally(line)
+	if g.isMappingCorrect() {
+		g.updateState(graphPadding)
+	}
+}
+
+// paddingLineForMessage is a line that keeps the branch lines going beside
+// the text of a commit
+func (g *Graph) paddingLineForMessage(line *strings.Builder) {
+	if g.state != graphCommit {
+		g.nextLine(line)
+		return
+	}
+	for _, col := range g.columns {
+		line.WriteByte('|')
+		if col == g.commit && g.numParents > 2 {
+			line.WriteString(strings.Repeat(" ", (g.numParents-2)*2))
+		} else {
+			line.WriteByte(' ')
+		}
+	}
+	g.padHorizontally(line)
+	g.prevState = graphPadding
+}
+
+func (g *Graph) write(s string) {
+	io.WriteString(g.out, s)
+}
+
+// ShowCommit draws the lines up to the one of the commit, which the text
+// of the commit then follows
+func (g *Graph) ShowCommit() {
+	if g.isCommitFinished() {
+		g.ShowPadding()
+		return
+	}
+	for {
+		var line strings.Builder
+		shown := g.nextLine(&line)
+		g.write(line.String())
+		if shown {
+			return
+		}
+		g.write("\n")
+		if g.isCommitFinished() {
+			return
+		}
+	}
+}
+
+// ShowOneline draws the next line of the graph, for a line of text
+func (g *Graph) ShowOneline() {
+	var line strings.Builder
+	g.nextLine(&line)
+	g.write(line.String())
+}
+
+// ShowPadding draws a line that only continues the branch lines
+func (g *Graph) ShowPadding() {
+	var line strings.Builder
+	g.paddingLineForMessage(&line)
+	g.write(line.String())
+}
+
+// ShowRemainder draws the lines left for the commit after its text
+func (g *Graph) ShowRemainder() {
+	for !g.isCommitFinished() {
+		var line strings.Builder
+		g.nextLine(&line)
+		g.write(line.String())
+		if g.isCommitFinished() {
+			return
+		}
+		g.write("\n")
+	}
+}
+
+// ShowMessage prints the text of the commit, with the graph before each of
+// its lines but the first, and then the rest of the graph of the commit
+func (g *Graph) ShowMessage(msg string) {
+	for rest := msg; rest != ""; {
+		line, after, found := strings.Cut(rest, "\n")
+		g.write(line)
+		if found {
+			g.write("\n")
+			if after != "" {
+				g.ShowOneline()
+			}
+		}
+		rest = after
+	}
+	if g.isCommitFinished() {
+		return
+	}
+	newlineTerminated := strings.HasSuffix(msg, "\n")
+	if !newlineTerminated {
+		g.write("\n")
+	}
+	g.ShowRemainder()
+	if newlineTerminated {
+		g.write("\n")
+	}
+}
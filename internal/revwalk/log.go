@@ -0,0 +1,204 @@
+package revwalk
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// setFlag is a flag without a value that sets an option shared with other
+// flags, so that the last of them given wins
+type setFlag struct {
+	option *string
+	value  string
+	abbrev *bool // set too, for --oneline
+}
+
+func (f setFlag) String() string   { return "" }
+func (f setFlag) IsBoolFlag() bool { return true }
+
+func (f setFlag) Set(value string) error {
+	if value != "true" {
+		return fmt.Errorf("the flag takes no value")
+	}
+	*f.option = f.value
+	if f.abbrev != nil {
+		*f.abbrev = true
+	}
+	return nil
+}
+
+type Log struct {
+	Fs   *flag.FlagSet
+	Repo *repository.Repository
+	walkOptions
+	pretty       string
+	abbrevCommit bool
+	graph        bool
+	decorate     string // empty for the log.decorate setting
+	date         string
+}
+
+func (l *Log) Initialize(args []string) error {
+	l.define(l.Fs)
+	l.Fs.StringVar(&l.pretty, "pretty", formatMedium, "Show commits in a format: oneline, short, medium, full, fuller, raw, reference or format:<placeholders>")
+	l.Fs.StringVar(&l.pretty, "format", formatMedium, "Same as --pretty, tformat:<placeholders> for a bare format")
+	l.Fs.Var(setFlag{option: &l.pretty, value: formatOneline, abbrev: &l.abbrevCommit}, "oneline", "Same as --pretty=oneline --abbrev-commit")
+	l.Fs.BoolVar(&l.abbrevCommit, "abbrev-commit", false, "Abbreviate the names of commits in the headers")
+	l.Fs.BoolVar(&l.graph, "graph", false, "Draw the history beside the commits")
+	l.Fs.StringVar(&l.decorate, "decorate", "", "Show the refs pointing at commits: short, full, auto or no")
+	l.Fs.Var(setFlag{option: &l.decorate, value: decorateNo}, "no-decorate", "Same as --decorate=no")
+	l.Fs.StringVar(&l.date, "date", "", "Show dates in a mode: default, iso, iso-strict, rfc, short, raw, unix, local or relative")
+	return l.parse(l.Fs, optionalValues(args))
+}
+
+// optionalValues gives --pretty and --decorate their default values when
+// they come without one
+func optionalValues(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		switch arg {
+		case "--":
+			return append(result, args[i:]...)
+		case "--pretty":
+			result = append(result, "--pretty="+formatMedium)
+		case "--decorate":
+			result = append(result, "--decorate="+decorateShort)
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func (l *Log) Usage() string {
+	return "git log [--oneline | --pretty=<format> | --format=<format>] [--graph] [--decorate[=<style>]] [-n <count>] [--first-parent] [--author=<pattern>] [--grep=<pattern>] [-i] [-E | -F] [--since=<date>] [--until=<date>] [--date=<mode>] [--topo-order | --date-order] [--reverse] [--left-right] [--all] [<revision-range>...] [[--] <path>...] : Show commit logs"
+}
+
+func (l *Log) Run() error {
+	format, err := parseFormat(l.pretty)
+	if err != nil {
+		return err
+	}
+	if l.date == "" && format.builtin == formatReference {
+		l.date = "short"
+	}
+	if _, err := identity.FormatDate(object.Signature{}, l.date); err != nil {
+		return err
+	}
+	decorate, err := l.decorateStyle()
+	if err != nil {
+		return err
+	}
+	if l.reverse && l.graph {
+		return fmt.Errorf("options '--reverse' and '--graph' cannot be used together")
+	}
+	w, err := l.setup(l.Repo)
+	if err != nil {
+		return err
+	}
+	if l.graph {
+		if w.Order == "" {
+			w.Order = OrderTopo
+		}
+		w.Rewrite = true
+	}
+	// the graph marks the side of each commit itself
+	p := &printer{repo: l.Repo, format: format, date: l.date, abbrevCommit: l.abbrevCommit, decorate: decorate, leftRight: l.leftRight && !l.graph}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var graph *Graph
+	if l.graph {
+		graph = NewGraph(w, out)
+		graph.LeftRight = l.leftRight
+	}
+	shownOne, missingNewline := false, false
+	for {
+		c, err := w.Next()
+		if c == nil || err != nil {
+			return err
+		}
+		if graph != nil {
+			graph.Update(c)
+		}
+		// without a terminator, a newline separates commits
+		if shownOne && !format.terminator {
+			if graph != nil && !missingNewline {
+				graph.ShowPadding()
+			}
+			out.WriteString("\n")
+		}
+		shownOne = true
+		if graph != nil {
+			graph.ShowCommit()
+		}
+
+		if !format.isUser() {
+			header, err := p.header(c)
+			if err != nil {
+				return err
+			}
+			out.WriteString(header)
+			if format.builtin != formatOneline {
+				out.WriteString("\n")
+				if graph != nil {
+					graph.ShowOneline()
+				}
+			}
+		}
+		msg, err := p.message(c)
+		if err != nil {
+			return err
+		}
+		missingNewline = !strings.HasSuffix(msg, "\n")
+		if graph != nil {
+			graph.ShowMessage(msg)
+		} else {
+			out.WriteString(msg)
+		}
+		if format.terminator && !(format.isUser() && format.user == "") {
+			if graph != nil && !missingNewline {
+				graph.ShowPadding()
+			}
+			out.WriteString("\n")
+		}
+	}
+}
+
+// decorateStyle is the --decorate style, else the one of log.decorate.
+// Like git, auto decorates only for a terminal
+func (l *Log) decorateStyle() (string, error) {
+	style := l.decorate
+	if style == "" {
+		cfg, err := l.Repo.Config()
+		if err != nil {
+			return "", err
+		}
+		style, _ = cfg.Get("log.decorate")
+		switch strings.ToLower(style) {
+		case "true", "yes", "on", "1":
+			style = decorateShort
+		case "false", "no", "off", "0":
+			style = decorateNo
+		case "":
+			style = "auto"
+		}
+	}
+	switch style {
+	case decorateShort, decorateFull, decorateNo:
+		return style, nil
+	case "auto":
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return decorateShort, nil
+		}
+		return decorateNo, nil
+	}
+	return "", fmt.Errorf("invalid --decorate option: %s", style)
+}
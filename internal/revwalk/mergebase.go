@@ -0,0 +1,194 @@
+package revwalk
+
+import (
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// MergeBases are the best common ancestors of a and b, the ones that are
+// no ancestor of another, newest first. Like git, the commits read on the
+// way are known to the walk after
+func (w *Walker) MergeBases(a, b objectstore.Hash) ([]objectstore.Hash, error) {
+	one, two := w.lookup(a), w.lookup(b)
+	if err := w.parse(one); err != nil {
+		return nil, err
+	}
+	if err := w.parse(two); err != nil {
+		return nil, err
+	}
+	found, painted, err := w.paintDownToCommon(one, []*Commit{two})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, c := range painted {
+			c.flags &^= parent1 | parent2 | stale | result
+		}
+	}()
+	var bases []*Commit
+	for _, c := range found {
+		if c.flags&stale == 0 {
+			bases = append(bases, c)
+		}
+	}
+	if bases, err = w.removeRedundant(bases); err != nil {
+		return nil, err
+	}
+	bases = sortByDate(bases)
+
+	hashes := make([]objectstore.Hash, len(bases))
+	for i, c := range bases {
+		hashes[i] = c.Hash
+	}
+	return hashes, nil
+}
+
+// IsAncestor is whether commit can be reached from tip. Like MergeBases,
+// the history is only walked down to where the two meet
+func (w *Walker) IsAncestor(commit, tip objectstore.Hash) (bool, error) {
+	if commit == tip {
+		return true, nil
+	}
+	one, two := w.lookup(commit), w.lookup(tip)
+	if err := w.parse(one); err != nil {
+		return false, err
+	}
+	if err := w.parse(two); err != nil {
+		return false, err
+	}
+	_, painted, err := w.paintDownToCommon(one, []*Commit{two})
+	if err != nil {
+		return false, err
+	}
+	reached := one.flags&parent2 != 0
+	for _, c := range painted {
+		c.flags &^= parent1 | parent2 | stale | result
+	}
+	return reached, nil
+}
+
+// AheadBehind counts the commits reachable from local but not upstream, and
+// the other way around, walking local...upstream
+func AheadBehind(repo *repository.Repository, local, upstream objectstore.Hash) (int, int, error) {
+	if local == upstream {
+		return 0, 0, nil
+	}
+	w := NewWalker(repo)
+	bases, err := w.MergeBases(local, upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, base := range bases {
+		if err := w.Hide(base); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := w.PushLeft(local); err != nil {
+		return 0, 0, err
+	}
+	if err := w.Push(upstream); err != nil {
+		return 0, 0, err
+	}
+	ahead, behind := 0, 0
+	for {
+		c, err := w.Next()
+		if err != nil {
+			return 0, 0, err
+		}
+		if c == nil {
+			return ahead, behind, nil
+		}
+		if c.Left() {
+			ahead++
+		} else {
+			behind++
+		}
+	}
+}
+
+// paintDownToCommon walks down from one and the others, newest first, and
+// gives the commits reached from both, and all the commits it painted. The
+// ancestors of those are marked stale, as they are reached from both too,
+// and the walk goes on while commits that are not stale are left
+func (w *Walker) paintDownToCommon(one *Commit, others []*Commit) ([]*Commit, []*Commit, error) {
+	queue := &dateQueue{}
+	one.flags |= parent1
+	queue.push(one)
+	painted := []*Commit{one}
+	for _, c := range others {
+		c.flags |= parent2
+		queue.push(c)
+		painted = append(painted, c)
+	}
+	var found []*Commit
+	for hasNonStale(queue) {
+		c := queue.pop()
+		flags := c.flags & (parent1 | parent2 | stale)
+		if flags == parent1|parent2 {
+			if c.flags&result == 0 {
+				c.flags |= result
+				found = append(found, c)
+			}
+			flags |= stale
+		}
+		for _, p := range c.Parents {
+			if p.flags&flags == flags {
+				continue
+			}
+			if err := w.parse(p); err != nil {
+				return nil, nil, err
+			}
+			p.flags |= flags
+			queue.push(p)
+			painted = append(painted, p)
+		}
+	}
+	return found, painted, nil
+}
+
+func hasNonStale(queue *dateQueue) bool {
+	for _, item := range queue.items {
+		if item.commit.flags&stale == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// removeRedundant leaves out the bases that are ancestors of other bases
+func (w *Walker) removeRedundant(bases []*Commit) ([]*Commit, error) {
+	if len(bases) < 2 {
+		return bases, nil
+	}
+	isBase := map[*Commit]bool{}
+	for _, c := range bases {
+		isBase[c] = true
+	}
+	redundant := map[*Commit]bool{}
+	for _, c := range bases {
+		visited := map[*Commit]bool{}
+		stack := append([]*Commit(nil), c.Parents...)
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[p] {
+				continue
+			}
+			visited[p] = true
+			if err := w.parse(p); err != nil {
+				return nil, err
+			}
+			if isBase[p] {
+				redundant[p] = true
+			}
+			stack = append(stack, p.Parents...)
+		}
+	}
+	var kept []*Commit
+	for _, c := range bases {
+		if !redundant[c] {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,477 @@
+package revwalk
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/flags"
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+// allRefs stands for --all among the revisions, which it is in the place of
+const allRefs = "--all"
+
+// allFlag adds allRefs to the revisions
+type allFlag struct {
+	args *[]string
+}
+
+func (f allFlag) String() string   { return "" }
+func (f allFlag) IsBoolFlag() bool { return true }
+
+func (f allFlag) Set(value string) error {
+	if value != "true" {
+		return fmt.Errorf("the flag takes no value")
+	}
+	*f.args = append(*f.args, allRefs)
+	return nil
+}
+
+// tagObject is an annotated tag given as a revision
+type tagObject struct {
+	hash objectstore.Hash
+	name string
+}
+
+// walkOptions are the options that choose the commits a walk shows, shared
+// by the commands walking the history
+type walkOptions struct {
+	maxCount     int
+	firstParent  bool
+	authors      flags.StringList
+	greps        flags.StringList
+	ignoreCase   bool
+	extended     bool
+	fixed        bool
+	since, until string
+	order        string
+	reverse      bool
+	leftRight    bool
+	args         []string // revisions, and then paths when there is no --
+	paths        []string // after --
+	dashDash     bool
+
+	tags       []tagObject // the annotated tags given, in order
+	hiddenTags map[objectstore.Hash]bool
+}
+
+func (o *walkOptions) define(fs *flag.FlagSet) {
+	fs.IntVar(&o.maxCount, "n", -1, "Show at most this many commits, -<n> is the same")
+	fs.IntVar(&o.maxCount, "max-count", -1, "Same as -n")
+	fs.BoolVar(&o.firstParent, "first-parent", false, "Only follow the first parent of merges")
+	fs.Var(&o.authors, "author", "Only show commits whose author matches the pattern, any of them when repeated")
+	fs.Var(&o.greps, "grep", "Only show commits whose message matches the pattern, any of them when repeated")
+	fs.BoolVar(&o.ignoreCase, "i", false, "Match --author and --grep ignoring case")
+	fs.BoolVar(&o.ignoreCase, "regexp-ignore-case", false, "Same as -i")
+	fs.BoolVar(&o.extended, "E", false, "Patterns are extended regular expressions rather than basic ones")
+	fs.BoolVar(&o.extended, "extended-regexp", false, "Same as -E")
+	fs.BoolVar(&o.fixed, "F", false, "Patterns are fixed strings")
+	fs.BoolVar(&o.fixed, "fixed-strings", false, "Same as -F")
+	fs.StringVar(&o.since, "since", "", "Only show commits more recent than the date")
+	fs.StringVar(&o.since, "after", "", "Same as --since")
+	fs.StringVar(&o.until, "until", "", "Only show commits older than the date")
+	fs.StringVar(&o.until, "before", "", "Same as --until")
+	fs.Var(allFlag{args: &o.args}, "all", "Start at every ref and HEAD, as if they were given")
+	fs.Var(setFlag{option: &o.order, value: OrderTopo}, "topo-order", "Show no parent before its children, and keep lines of history together")
+	fs.Var(setFlag{option: &o.order, value: OrderDate}, "date-order", "Show no parent before its children, newest first otherwise")
+	fs.BoolVar(&o.reverse, "reverse", false, "Show the commits chosen in reverse order")
+	fs.BoolVar(&o.leftRight, "left-right", false, "Mark the side of a symmetric range commits can be reached from, < or >")
+}
+
+// parse reads the options, which like git's may come between and after the
+// revisions, up to a "--" that the paths follow
+func (o *walkOptions) parse(fs *flag.FlagSet, args []string) error {
+	args, o.paths, o.dashDash = revision.SplitDashDash(args)
+	rest := countValues(args)
+	for len(rest) > 0 {
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		rest = fs.Args()
+		if len(rest) > 0 {
+			o.args = append(o.args, rest[0])
+			rest = rest[1:]
+		}
+	}
+	return nil
+}
+
+// countValues rewrites -<n> and -n<n> as -n <n>
+func countValues(args []string) []string {
+	var result []string
+	for _, arg := range args {
+		count, ok := strings.CutPrefix(arg, "-")
+		if ok {
+			count = strings.TrimPrefix(count, "n")
+		}
+		if ok && count != "" && strings.Trim(count, "0123456789") == "" {
+			result = append(result, "-n", count)
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+// setup makes a walker that starts at the revisions given, HEAD when there
+// are none, and shows the commits the options choose. Revisions can be
+// ^<rev> to hide a commit and its history, <rev>..<rev> for the commits of
+// the second not in the first, and <rev>...<rev> for the ones of either
+// not in both
+func (o *walkOptions) setup(repo *repository.Repository) (*Walker, error) {
+	w := NewWalker(repo)
+	w.MaxCount = o.maxCount
+	w.FirstParent = o.firstParent
+	w.Order = o.order
+	w.Reverse = o.reverse
+	if err := o.setupDates(w); err != nil {
+		return nil, err
+	}
+	if err := o.setupMatch(w); err != nil {
+		return nil, err
+	}
+
+	revs, paths, err := o.split(repo)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		if w.Paths, err = pathspec.Parse(repo.WorkTree, paths); err != nil {
+			return nil, err
+		}
+	}
+	if len(revs) == 0 {
+		head, err := refs.NewStore(repo).Head()
+		if errors.Is(err, refs.ErrNotFound) {
+			branch, _ := refs.NewStore(repo).HeadBranch()
+			return nil, fmt.Errorf("your current branch '%s' does not have any commits yet", strings.TrimPrefix(branch, "refs/heads/"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return w, w.Push(head)
+	}
+	resolver := revision.NewResolver(repo)
+	for _, rev := range revs {
+		if err := o.push(w, resolver, rev); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// push starts w at a revision, or hides it
+func (o *walkOptions) push(w *Walker, resolver *revision.Resolver, rev string) error {
+	if rev == allRefs {
+		return o.pushAll(w)
+	}
+	if a, b, symmetric, ok := splitRange(rev); ok && rangeResolves(resolver, a, b) {
+		from, err := resolver.ResolveType(a, packextractor.OBJ_COMMIT)
+		if err != nil {
+			return err
+		}
+		to, err := resolver.ResolveType(b, packextractor.OBJ_COMMIT)
+		if err != nil {
+			return err
+		}
+		if !symmetric {
+			if err := w.Hide(from); err != nil {
+				return err
+			}
+			return w.Push(to)
+		}
+		bases, err := w.MergeBases(from, to)
+		if err != nil {
+			return err
+		}
+		for _, base := range bases {
+			if err := w.Hide(base); err != nil {
+				return err
+			}
+		}
+		if err := w.PushLeft(from); err != nil {
+			return err
+		}
+		return w.Push(to)
+	}
+
+	name, hide := strings.CutPrefix(rev, "^")
+	hash, err := resolver.Resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := o.addTags(w.repo, hash, hide); err != nil {
+		return err
+	}
+	commit, err := resolver.ResolveType(name, packextractor.OBJ_COMMIT)
+	if err != nil {
+		return err
+	}
+	if hide {
+		return w.Hide(commit)
+	}
+	return w.Push(commit)
+}
+
+// pushAll starts w at every ref and HEAD that is a commit
+func (o *walkOptions) pushAll(w *Walker) error {
+	store := refs.NewStore(w.repo)
+	list, err := store.List("refs/")
+	if err != nil {
+		return err
+	}
+	var hashes []objectstore.Hash
+	for _, ref := range list {
+		hashes = append(hashes, ref.Hash)
+	}
+	head, err := store.Head()
+	if err == nil {
+		hashes = append(hashes, head)
+	} else if !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	for _, hash := range hashes {
+		if err := o.addTags(w.repo, hash, false); err != nil {
+			return err
+		}
+		obj, commit, err := object.Peel(w.repo.Objects, hash)
+		if err != nil {
+			return err
+		}
+		if obj.Type != packextractor.OBJ_COMMIT {
+			continue
+		}
+		if err := w.Push(commit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTags records the annotated tags hash is, and the ones it points to
+func (o *walkOptions) addTags(repo *repository.Repository, hash objectstore.Hash, hide bool) error {
+	for {
+		obj, err := repo.Objects.Get(hash)
+		if err != nil {
+			return err
+		}
+		if obj.Type != packextractor.OBJ_TAG {
+			return nil
+		}
+		tag, err := object.ParseTag(obj.Data)
+		if err != nil {
+			return err
+		}
+		if hide {
+			if o.hiddenTags == nil {
+				o.hiddenTags = map[objectstore.Hash]bool{}
+			}
+			o.hiddenTags[hash] = true
+		} else {
+			o.tags = append(o.tags, tagObject{hash: hash, name: tag.Name})
+		}
+		hash = tag.Object
+	}
+}
+
+// splitRange reads <a>..<b> and <a>...<b>, where a missing side is HEAD
+func splitRange(rev string) (a, b string, symmetric, ok bool) {
+	if a, b, ok = strings.Cut(rev, "..."); ok {
+		symmetric = true
+	} else if a, b, ok = strings.Cut(rev, ".."); !ok {
+		return "", "", false, false
+	}
+	if a == "" && b == "" {
+		return "", "", false, false
+	}
+	if a == "" {
+		a = "HEAD"
+	}
+	if b == "" {
+		b = "HEAD"
+	}
+	return a, b, symmetric, true
+}
+
+func rangeResolves(resolver *revision.Resolver, a, b string) bool {
+	if _, err := resolver.Resolve(a); err != nil {
+		return false
+	}
+	_, err := resolver.Resolve(b)
+	return err == nil
+}
+
+// resolves is whether rev names commits: a revision, ^<rev> or a range.
+// It gives the name that must not be a file too
+func resolves(resolver *revision.Resolver, rev string) (string, bool) {
+	if a, b, _, ok := splitRange(rev); ok && rangeResolves(resolver, a, b) {
+		return rev, true
+	}
+	name := strings.TrimPrefix(rev, "^")
+	_, err := resolver.Resolve(name)
+	return name, err == nil
+}
+
+// split tells the revisions from the paths. Without --, the arguments are
+// revisions up to the first that names none, which with the ones after it
+// must be files of the work tree
+func (o *walkOptions) split(repo *repository.Repository) ([]string, []string, error) {
+	resolver := revision.NewResolver(repo)
+	for i, arg := range o.args {
+		if arg == allRefs {
+			continue
+		}
+		if name, ok := resolves(resolver, arg); ok {
+			if !o.dashDash && revision.InWorkTree(name) {
+				return nil, nil, revision.AmbiguousError(name)
+			}
+			continue
+		}
+		if o.dashDash || strings.HasPrefix(arg, "^") {
+			return nil, nil, fmt.Errorf("bad revision '%s'", arg)
+		}
+		revs := append([]string(nil), o.args[:i]...)
+		var paths []string
+		for _, path := range o.args[i:] {
+			if path == allRefs {
+				revs = append(revs, path)
+				continue
+			}
+			if !revision.InWorkTree(path) {
+				return nil, nil, revision.UnknownArgError(path)
+			}
+			paths = append(paths, path)
+		}
+		return revs, paths, nil
+	}
+	return o.args, o.paths, nil
+}
+
+func (o *walkOptions) setupDates(w *Walker) error {
+	now := time.Now()
+	var err error
+	if o.since != "" {
+		if w.Since, err = identity.ApproxDate(o.since, now); err != nil {
+			return err
+		}
+	}
+	if o.until != "" {
+		if w.Until, err = identity.ApproxDate(o.until, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupMatch shows the commits whose author matches one of the --author
+// patterns and whose message matches one of the --grep ones
+func (o *walkOptions) setupMatch(w *Walker) error {
+	authors, err := o.compile(o.authors)
+	if err != nil {
+		return err
+	}
+	greps, err := o.compile(o.greps)
+	if err != nil {
+		return err
+	}
+	if authors == nil && greps == nil {
+		return nil
+	}
+	w.Match = func(c *Commit) bool {
+		author := c.Commit.Author.Name + " <" + c.Commit.Author.Email + ">"
+		return matchAny(authors, author) && matchAny(greps, c.Commit.Message)
+	}
+	return nil
+}
+
+// matchAny is whether one of the patterns matches s, true without patterns
+func matchAny(patterns []*regexp.Regexp, s string) bool {
+	if patterns == nil {
+		return true
+	}
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile turns the patterns, basic regular expressions unless -E or -F
+// are given, into regular expressions matching a line of the text
+func (o *walkOptions) compile(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		expr := pattern
+		switch {
+		case o.fixed:
+			expr = regexp.QuoteMeta(pattern)
+		case !o.extended:
+			expr = basicToExtended(pattern)
+		}
+		expr = "(?m)" + expr
+		if o.ignoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// basicToExtended rewrites a POSIX basic regular expression: the +, ?, |,
+// braces and parentheses that are special only after a backslash there
+func basicToExtended(pattern string) string {
+	var sb strings.Builder
+	inBracket := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case inBracket:
+			if ch == ']' {
+				inBracket = false
+			}
+			sb.WriteByte(ch)
+		case ch == '[':
+			inBracket = true
+			sb.WriteByte(ch)
+			// a ] right after [ or [^ is part of the set
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				sb.WriteByte('^')
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				sb.WriteString(`\]`)
+				i++
+			}
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			if strings.IndexByte("+?|(){}", pattern[i]) >= 0 {
+				sb.WriteByte(pattern[i])
+			} else {
+				sb.WriteByte('\\')
+				sb.WriteByte(pattern[i])
+			}
+		case strings.IndexByte("+?|(){}", ch) >= 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
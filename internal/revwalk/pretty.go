@@ -0,0 +1,513 @@
+package revwalk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
+)
+
+// Built-in formats of --pretty
+const (
+	formatOneline   = "oneline"
+	formatShort     = "short"
+	formatMedium    = "medium"
+	formatFull      = "full"
+	formatFuller    = "fuller"
+	formatRaw       = "raw"
+	formatReference = "reference"
+)
+
+// builtinFormats in the order git looks them up, the shortest name wins
+// among the ones an abbreviation matches
+var builtinFormats = []string{formatRaw, formatMedium, formatShort, formatFull, formatFuller, formatReference, formatOneline}
+
+// prettyFormat is how each commit is shown
+type prettyFormat struct {
+	builtin string // empty for a user format
+	user    string // the placeholders of a user format
+	// terminator ends every commit with a newline, rather than separating
+	// commits with one
+	terminator bool
+}
+
+// parseFormat reads the value of --pretty or --format: a built-in format or
+// its abbreviation, format:<placeholders>, or tformat:<placeholders> which
+// is what a value with a % in it is too
+func parseFormat(value string) (prettyFormat, error) {
+	if user, ok := strings.CutPrefix(value, "format:"); ok {
+		return prettyFormat{user: user}, nil
+	}
+	if user, ok := strings.CutPrefix(value, "tformat:"); ok || value == "" || strings.Contains(value, "%") {
+		if !ok {
+			user = value
+		}
+		return prettyFormat{user: user, terminator: true}, nil
+	}
+	found := ""
+	for _, name := range builtinFormats {
+		if strings.HasPrefix(name, strings.ToLower(value)) && (found == "" || len(name) < len(found)) {
+			found = name
+		}
+	}
+	switch found {
+	case "":
+		return prettyFormat{}, fmt.Errorf("invalid --pretty format: %s", value)
+	case formatReference:
+		return prettyFormat{builtin: found, user: "%h (%s, %ad)", terminator: true}, nil
+	}
+	return prettyFormat{builtin: found, terminator: found == formatOneline}, nil
+}
+
+// isUser is whether the commits are shown through placeholders only
+func (f prettyFormat) isUser() bool {
+	return f.builtin == "" || f.builtin == formatReference
+}
+
+// Decoration styles, how refs are named beside the commits they point to
+const (
+	decorateNo    = "no"
+	decorateShort = "short"
+	decorateFull  = "full"
+)
+
+// printer shows commits in a pretty format
+type printer struct {
+	repo         *repository.Repository
+	format       prettyFormat
+	date         string // the --date mode
+	abbrevCommit bool   // abbreviate the name of the commit in the header
+	leftRight    bool   // mark the side of the commit in the header
+	decorate     string // decorateNo, decorateShort or decorateFull
+	decorations  map[objectstore.Hash][]decoration
+	abbreviator  *revision.Abbreviator
+}
+
+// decoration is a ref shown beside the commit it points to
+type decoration struct {
+	name string // the full name of the ref, or HEAD
+	tag  bool
+}
+
+// loadDecorations finds the refs pointing at each commit. Like git, HEAD
+// comes first and then the refs in reverse order of their names
+func (p *printer) loadDecorations() error {
+	if p.decorations != nil {
+		return nil
+	}
+	p.decorations = map[objectstore.Hash][]decoration{}
+	store := refs.NewStore(p.repo)
+	list, err := store.List("refs/")
+	if err != nil {
+		return err
+	}
+	if head, err := store.Head(); err == nil {
+		p.decorations[head] = append(p.decorations[head], decoration{name: "HEAD"})
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		ref := list[i]
+		hash := ref.Hash
+		tag := strings.HasPrefix(ref.Name, "refs/tags/")
+		if peeled, ok := store.Peel(ref); ok {
+			hash = peeled
+		}
+		p.decorations[hash] = append(p.decorations[hash], decoration{name: ref.Name, tag: tag})
+	}
+	return nil
+}
+
+// decorationList is the refs pointing at hash joined with ", ", with the
+// branch HEAD is on shown as "HEAD -> branch"
+func (p *printer) decorationList(hash objectstore.Hash) (string, error) {
+	if err := p.loadDecorations(); err != nil {
+		return "", err
+	}
+	decorations := p.decorations[hash]
+	current := ""
+	if len(decorations) > 0 && decorations[0].name == "HEAD" {
+		if branch, err := refs.NewStore(p.repo).HeadBranch(); err == nil {
+			for _, d := range decorations {
+				if d.name == branch {
+					current = branch
+				}
+			}
+		}
+	}
+	var names []string
+	for _, d := range decorations {
+		switch {
+		case d.name == current:
+		case d.name == "HEAD" && current != "":
+			names = append(names, "HEAD -> "+p.refName(current))
+		case d.tag:
+			names = append(names, "tag: "+p.refName(d.name))
+		default:
+			names = append(names, p.refName(d.name))
+		}
+	}
+	return strings.Join(names, ", "), nil
+}
+
+func (p *printer) refName(name string) string {
+	if p.decorate == decorateFull {
+		return name
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			return short
+		}
+	}
+	return name
+}
+
+// decorationSuffix is " (<refs>)" for the header of a commit, empty when none
+// point at it or they are not shown
+func (p *printer) decorationSuffix(hash objectstore.Hash) (string, error) {
+	if p.decorate == decorateNo {
+		return "", nil
+	}
+	list, err := p.decorationList(hash)
+	if list == "" || err != nil {
+		return "", err
+	}
+	return " (" + list + ")", nil
+}
+
+func (p *printer) abbrev(hash objectstore.Hash) string {
+	if p.abbreviator == nil {
+		p.abbreviator = revision.NewAbbreviator(p.repo.Objects)
+	}
+	return p.abbreviator.Abbreviate(hash, 7)
+}
+
+// header is the line before the message of a built-in format: "commit
+// <hash>" and the decorations, or "<hash> " for oneline
+func (p *printer) header(c *Commit) (string, error) {
+	name := c.Hash.String()
+	if p.abbrevCommit {
+		name = p.abbrev(c.Hash)
+	}
+	decorations, err := p.decorationSuffix(c.Hash)
+	if err != nil {
+		return "", err
+	}
+	if p.leftRight {
+		name = sideMark(c) + " " + name
+	}
+	if p.format.builtin == formatOneline {
+		return name + decorations + " ", nil
+	}
+	return "commit " + name + decorations, nil
+}
+
+// sideMark is the side of a symmetric range c is on, < for the left one
+func sideMark(c *Commit) string {
+	if c.Left() {
+		return "<"
+	}
+	return ">"
+}
+
+// message is what is shown for the commit after its header
+func (p *printer) message(c *Commit) (string, error) {
+	if p.format.isUser() {
+		return p.expand(c, p.format.user)
+	}
+	commit := c.Commit
+	if p.format.builtin == formatOneline {
+		return strings.TrimRight(object.Subject(commit.Message), " \t\n\r"), nil
+	}
+
+	var sb strings.Builder
+	if p.format.builtin == formatRaw {
+		header, _, _ := strings.Cut(string(commit.Encode()), "\n\n")
+		sb.WriteString(header + "\n")
+	} else {
+		if len(c.Parents) > 1 {
+			sb.WriteString("Merge:")
+			for _, parent := range c.Parents {
+				sb.WriteString(" " + p.abbrev(parent.Hash))
+			}
+			sb.WriteString("\n")
+		}
+		if err := p.writePeople(&sb, commit); err != nil {
+			return "", err
+		}
+	}
+	sb.WriteString("\n")
+
+	// the lines of the message indented, the subject only for short
+	expandTabs := p.format.builtin != formatRaw && p.format.builtin != formatShort
+	first := true
+	for _, line := range strings.Split(commit.Message, "\n") {
+		line = strings.TrimRight(line, " \t\n\r\v\f")
+		if line == "" {
+			if first {
+				continue
+			}
+			if p.format.builtin == formatShort {
+				break
+			}
+		}
+		first = false
+		if expandTabs {
+			line = expandTabsInLine(line)
+		}
+		sb.WriteString("    " + line + "\n")
+	}
+	return strings.TrimRight(sb.String(), " \t\n\r\v\f") + "\n", nil
+}
+
+// writePeople writes the author, and the committer for full and fuller,
+// with the dates the format shows
+func (p *printer) writePeople(sb *strings.Builder, commit *object.Commit) error {
+	author := commit.Author.Name + " <" + commit.Author.Email + ">"
+	committer := commit.Committer.Name + " <" + commit.Committer.Email + ">"
+	switch p.format.builtin {
+	case formatShort:
+		fmt.Fprintf(sb, "Author: %s\n", author)
+	case formatMedium:
+		date, err := identity.FormatDate(commit.Author, p.date)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sb, "Author: %s\nDate:   %s\n", author, date)
+	case formatFull:
+		fmt.Fprintf(sb, "Author: %s\nCommit: %s\n", author, committer)
+	case formatFuller:
+		authorDate, err := identity.FormatDate(commit.Author, p.date)
+		if err != nil {
+			return err
+		}
+		commitDate, err := identity.FormatDate(commit.Committer, p.date)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sb, "Author:     %s\nAuthorDate: %s\nCommit:     %s\nCommitDate: %s\n", author, authorDate, committer, commitDate)
+	}
+	return nil
+}
+
+// expandTabsInLine replaces tabs with the spaces up to the next multiple of
+// 8 columns
+func expandTabsInLine(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var sb strings.Builder
+	column := 0
+	for _, r := range line {
+		if r != '\t' {
+			sb.WriteRune(r)
+			column++
+			continue
+		}
+		for {
+			sb.WriteByte(' ')
+			column++
+			if column%8 == 0 {
+				break
+			}
+		}
+	}
+	return sb.String()
+}
+
+// expand replaces the placeholders of a user format. One that is not known
+// is left as it is. %+x adds a newline before a placeholder that is not
+// empty, "% x" a space, and %-x drops the newlines before an empty one
+func (p *printer) expand(c *Commit, format string) (string, error) {
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			sb.WriteString(format)
+			return sb.String(), nil
+		}
+		sb.WriteString(format[:i])
+		format = format[i+1:]
+
+		magic := byte(0)
+		if format != "" && strings.IndexByte("+- ", format[0]) >= 0 {
+			magic, format = format[0], format[1:]
+		}
+		value, consumed, err := p.placeholder(c, format)
+		if err != nil {
+			return "", err
+		}
+		if consumed == 0 {
+			if magic == 0 {
+				sb.WriteByte('%')
+			}
+			continue
+		}
+		format = format[consumed:]
+		switch {
+		case value == "" && magic == '-':
+			s := strings.TrimRight(sb.String(), "\n")
+			sb.Reset()
+			sb.WriteString(s)
+		case value != "" && magic == '+':
+			value = "\n" + value
+		case value != "" && magic == ' ':
+			value = " " + value
+		}
+		sb.WriteString(value)
+	}
+}
+
+// placeholder is the value of the placeholder format starts with, and the
+// length of its name, 0 when it is not one
+func (p *printer) placeholder(c *Commit, format string) (string, int, error) {
+	if format == "" {
+		return "", 0, nil
+	}
+	commit := c.Commit
+	switch format[0] {
+	case '%':
+		return "%", 1, nil
+	case 'n':
+		return "\n", 1, nil
+	case 'x':
+		if len(format) >= 3 {
+			if b, err := strconv.ParseUint(format[1:3], 16, 8); err == nil {
+				return string([]byte{byte(b)}), 3, nil
+			}
+		}
+		return "", 0, nil
+	case 'C':
+		// colors are only used on terminals, which output never goes to here
+		if strings.HasPrefix(format, "C(") {
+			end := strings.IndexByte(format, ')')
+			if end < 0 {
+				return "", 0, nil
+			}
+			return "", end + 1, nil
+		}
+		for _, color := range []string{"red", "green", "blue", "reset"} {
+			if strings.HasPrefix(format[1:], color) {
+				return "", 1 + len(color), nil
+			}
+		}
+		return "", 0, nil
+	case 'H':
+		return c.Hash.String(), 1, nil
+	case 'h':
+		return p.abbrev(c.Hash), 1, nil
+	case 'T':
+		return commit.Tree.String(), 1, nil
+	case 't':
+		return p.abbrev(commit.Tree), 1, nil
+	case 'P', 'p':
+		names := make([]string, len(c.Parents))
+		for i, parent := range c.Parents {
+			names[i] = parent.Hash.String()
+			if format[0] == 'p' {
+				names[i] = p.abbrev(parent.Hash)
+			}
+		}
+		return strings.Join(names, " "), 1, nil
+	case 'm':
+		return sideMark(c), 1, nil
+	case 'd', 'D':
+		list, err := p.decorationList(c.Hash)
+		if err != nil || list == "" || format[0] == 'D' {
+			return list, 1, err
+		}
+		return " (" + list + ")", 1, nil
+	case 's':
+		return commit.Subject(), 1, nil
+	case 'f':
+		return sanitizeSubject(commit.Subject()), 1, nil
+	case 'b':
+		return object.Body(commit.Message), 1, nil
+	case 'B':
+		return commit.Message, 1, nil
+	case 'e':
+		return commit.Encoding, 1, nil
+	case 'a', 'c':
+		if len(format) < 2 {
+			return "", 0, nil
+		}
+		sig := commit.Author
+		if format[0] == 'c' {
+			sig = commit.Committer
+		}
+		value, ok, err := p.signatureField(sig, format[1])
+		if !ok || err != nil {
+			return "", 0, err
+		}
+		return value, 2, nil
+	}
+	return "", 0, nil
+}
+
+// signatureField is a part of the author or the committer: n name, e email,
+// l the local part of the email, and the date in the --date mode (d) or in
+// a given one. The mailmap variants are the same, as there is no mailmap
+func (p *printer) signatureField(sig object.Signature, field byte) (string, bool, error) {
+	mode := ""
+	switch field {
+	case 'n', 'N':
+		return sig.Name, true, nil
+	case 'e', 'E':
+		return sig.Email, true, nil
+	case 'l', 'L':
+		local, _, _ := strings.Cut(sig.Email, "@")
+		return local, true, nil
+	case 'd':
+		mode = p.date
+	case 'D':
+		mode = "rfc"
+	case 'r':
+		mode = "relative"
+	case 't':
+		mode = "unix"
+	case 'i':
+		mode = "iso"
+	case 'I':
+		mode = "iso-strict"
+	case 's':
+		mode = "short"
+	default:
+		return "", false, nil
+	}
+	date, err := identity.FormatDate(sig, mode)
+	return date, true, err
+}
+
+// sanitizeSubject makes the subject fit a file name like %f: runs of other
+// characters than letters, digits, "." and "_" become one "-", runs of "."
+// one ".", and the ones at the end are dropped
+func sanitizeSubject(subject string) string {
+	var sb strings.Builder
+	space := 2
+	for i := 0; i < len(subject); i++ {
+		ch := subject[i]
+		if !isTitleChar(ch) {
+			space |= 1
+			continue
+		}
+		if space == 1 {
+			sb.WriteByte('-')
+		}
+		space = 0
+		sb.WriteByte(ch)
+		if ch == '.' {
+			for i+1 < len(subject) && subject[i+1] == '.' {
+				i++
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), ".-")
+}
+
+func isTitleChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '.' || ch == '_'
+}
@@ -0,0 +1,376 @@
+package revwalk
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// how long reflog entries are kept when gc.reflogExpire and
+// gc.reflogExpireUnreachable are not set
+const (
+	defaultExpire            = "90.days.ago"
+	defaultExpireUnreachable = "30.days.ago"
+)
+
+type Reflog struct {
+	Fs     *flag.FlagSet
+	Repo   *repository.Repository
+	action string // show, expire, delete or exists
+
+	// show
+	date     string
+	maxCount int
+
+	// expire and delete
+	expire, expireUnreachable string
+	all                       bool
+	dryRun                    bool
+	rewrite                   bool // make each entry start where the one kept before it ends
+	updateRef                 bool // set the ref to the newest entry kept
+
+	args  []string
+	store *refs.Store
+}
+
+func (r *Reflog) Initialize(args []string) error {
+	r.action = "show"
+	if len(args) > 0 {
+		switch args[0] {
+		case "show", "expire", "delete", "exists":
+			r.action, args = args[0], args[1:]
+		}
+	}
+	switch r.action {
+	case "show":
+		r.Fs.StringVar(&r.date, "date", "", "Show when each change was made in this format instead of its position")
+		r.Fs.IntVar(&r.maxCount, "n", -1, "Show at most this many entries")
+		r.Fs.IntVar(&r.maxCount, "max-count", -1, "Same as -n")
+	case "expire":
+		r.Fs.StringVar(&r.expire, "expire", "", "Prune entries older than this, gc.reflogExpire by default")
+		r.Fs.StringVar(&r.expireUnreachable, "expire-unreachable", "", "Prune entries older than this that are not reachable from the ref, gc.reflogExpireUnreachable by default")
+		r.Fs.BoolVar(&r.all, "all", false, "Process the reflogs of every ref")
+	}
+	if r.action == "expire" || r.action == "delete" {
+		r.Fs.BoolVar(&r.dryRun, "n", false, "Do not actually prune anything")
+		r.Fs.BoolVar(&r.dryRun, "dry-run", false, "Same as -n")
+		r.Fs.BoolVar(&r.rewrite, "rewrite", false, "Adjust the old value of entries that follow a pruned one")
+		r.Fs.BoolVar(&r.updateRef, "updateref", false, "Set the ref to the value of the newest entry left")
+	}
+	err := r.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	r.args = r.Fs.Args()
+	switch {
+	case r.action == "show" && len(r.args) > 1:
+		return errors.New("Expected at most one ref")
+	case r.action == "delete" && len(r.args) == 0:
+		return errors.New("No reflog specified to delete")
+	case r.action == "exists" && len(r.args) != 1:
+		return errors.New("Expected exactly one ref")
+	}
+	return nil
+}
+
+func (r *Reflog) Usage() string {
+	return "git reflog [show] [--date=<format>] [-n <count>] [<ref>] | expire [--expire=<time>] [--expire-unreachable=<time>] [--rewrite] [--updateref] [-n] [--all | <refs>...] | delete [--rewrite] [--updateref] [-n] <ref>@{<specifier>}... | exists <ref> : Manage reflog information"
+}
+
+func (r *Reflog) Run() error {
+	r.store = refs.NewStore(r.Repo)
+	switch r.action {
+	case "expire":
+		return r.runExpire()
+	case "delete":
+		return r.runDelete()
+	case "exists":
+		if refs.CheckName(r.args[0]) != nil {
+			return fmt.Errorf("Invalid ref format: %s", r.args[0])
+		}
+		if !r.store.HasReflog(r.args[0]) {
+			os.Exit(1)
+		}
+		return nil
+	}
+	return r.runShow()
+}
+
+// runShow lists the entries of one reflog newest first, each one as
+// <name>@{n} or <name>@{<date>} with --date
+func (r *Reflog) runShow() error {
+	label := "HEAD"
+	if len(r.args) == 1 {
+		label = r.args[0]
+	}
+	full, err := r.store.ReflogName(label)
+	if errors.Is(err, refs.ErrNotFound) {
+		// a ref without a reflog simply has nothing to show
+		if _, ok := r.store.Expand(label); ok {
+			return nil
+		}
+		return fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.", label)
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := r.store.Reflog(full)
+	if err != nil {
+		return err
+	}
+
+	for n := 0; n < len(entries) && n != r.maxCount; n++ {
+		e := entries[len(entries)-1-n]
+		selector := strconv.Itoa(n)
+		if r.date != "" {
+			if selector, err = identity.FormatDate(e.Who, r.date); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s %s@{%s}: %s\n", e.New.String()[:7], label, selector, e.Message)
+	}
+	return nil
+}
+
+func (r *Reflog) runExpire() error {
+	cfg, err := r.Repo.Config()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	configured, _ := cfg.Get("gc.reflogexpire")
+	total, err := expiryTime(r.expire, configured, defaultExpire, now)
+	if err != nil {
+		return err
+	}
+	configured, _ = cfg.Get("gc.reflogexpireunreachable")
+	unreachable, err := expiryTime(r.expireUnreachable, configured, defaultExpireUnreachable, now)
+	if err != nil {
+		return err
+	}
+
+	names := r.args
+	if r.all {
+		if names, err = r.store.ReflogNames(); err != nil {
+			return err
+		}
+	}
+	failed := false
+	for _, name := range names {
+		full, err := r.store.ReflogName(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s points nowhere!\n", name)
+			failed = true
+			continue
+		}
+		reachable, err := r.reachability(full, !unreachable.After(total))
+		if err != nil {
+			return err
+		}
+		err = r.prune(full, func(e *refs.ReflogEntry) (bool, error) {
+			if e.Who.When.Before(total) {
+				return true, nil
+			}
+			if !e.Who.When.Before(unreachable) {
+				return false, nil
+			}
+			for _, hash := range []objectstore.Hash{e.Old, e.New} {
+				if ok, err := reachable(hash); !ok || err != nil {
+					return true, err
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// runDelete removes the entries named by <ref>@{n}, the n-th newest, and
+// <ref>@{<date>}, the newest one older than the date. The arguments are
+// taken one after the other, so deleting @{1} twice removes two entries
+func (r *Reflog) runDelete() error {
+	failed := false
+	for _, arg := range r.args {
+		at := strings.Index(arg, "@{")
+		if at < 0 || !strings.HasSuffix(arg, "}") {
+			fmt.Fprintf(os.Stderr, "error: not a reflog: %s\n", arg)
+			failed = true
+			continue
+		}
+		name, selector := arg[:at], arg[at+2:len(arg)-1]
+		full, err := r.store.ReflogName(name)
+		if name == "" || err != nil {
+			fmt.Fprintf(os.Stderr, "error: no reflog for '%s'\n", arg)
+			failed = true
+			continue
+		}
+		entries, err := r.store.Reflog(full)
+		if err != nil {
+			return err
+		}
+
+		// the position of the entry to delete, oldest first
+		victim := -1
+		if n, err := strconv.Atoi(selector); err == nil && n >= 0 {
+			victim = len(entries) - 1 - n
+		} else {
+			when, err := identity.ApproxDate(selector, time.Now())
+			if err != nil {
+				return err
+			}
+			// like git, the entries older than the date are counted and the
+			// one at that position goes, which is the newest of them unless
+			// the dates are out of order
+			for _, e := range entries {
+				if e.Who.When.Before(when) {
+					victim++
+				}
+			}
+		}
+		i := 0
+		err = r.prune(full, func(e *refs.ReflogEntry) (bool, error) {
+			i++
+			return i-1 == victim, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// prune drops the entries of the reflog of name that expired says should
+// go, oldest first, and writes what is left unless this is a dry run
+func (r *Reflog) prune(name string, expired func(*refs.ReflogEntry) (bool, error)) error {
+	entries, err := r.store.Reflog(name)
+	if err != nil {
+		return err
+	}
+	var kept []*refs.ReflogEntry
+	var last objectstore.Hash
+	for _, e := range entries {
+		gone, err := expired(e)
+		if err != nil {
+			return err
+		}
+		if gone {
+			continue
+		}
+		if r.rewrite {
+			e.Old = last
+		}
+		last = e.New
+		kept = append(kept, e)
+	}
+	if r.dryRun {
+		return nil
+	}
+	return r.store.WriteReflog(name, kept, r.updateRef)
+}
+
+// reachability tells whether the commits of entries of the reflog of name
+// can be reached from the ref, or from any ref for HEAD. A zero hash is the
+// ref not existing and always counts as reachable. When never is set, or
+// the ref cannot be resolved, nothing is reachable
+func (r *Reflog) reachability(name string, never bool) (func(objectstore.Hash) (bool, error), error) {
+	var tips []objectstore.Hash
+	if tip, err := r.store.Hash(name); err == nil {
+		tips = append(tips, tip)
+	}
+	if len(tips) == 0 || never {
+		return func(hash objectstore.Hash) (bool, error) { return hash.IsZero(), nil }, nil
+	}
+	if name == "HEAD" {
+		all, err := r.store.List("refs/")
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range all {
+			tips = append(tips, ref.Hash)
+		}
+	}
+
+	// the history is only walked when an entry old enough asks for it
+	var seen map[objectstore.Hash]bool
+	return func(hash objectstore.Hash) (bool, error) {
+		if hash.IsZero() {
+			return true, nil
+		}
+		if seen == nil {
+			var err error
+			if seen, err = reachable(r.Repo, tips); err != nil {
+				return false, err
+			}
+		}
+		obj, commit, err := object.Peel(r.Repo.Objects, hash)
+		return err == nil && obj.Type == packextractor.OBJ_COMMIT && seen[commit], nil
+	}, nil
+}
+
+// reachable is every commit reachable from tips, tags being followed to
+// what they point to
+func reachable(repo *repository.Repository, tips []objectstore.Hash) (map[objectstore.Hash]bool, error) {
+	w := NewWalker(repo)
+	for _, tip := range tips {
+		if obj, hash, err := object.Peel(repo.Objects, tip); err == nil && obj.Type == packextractor.OBJ_COMMIT {
+			if err := w.Push(hash); err != nil {
+				return nil, err
+			}
+		}
+	}
+	seen := make(map[objectstore.Hash]bool)
+	for {
+		c, err := w.Next()
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return seen, nil
+		}
+		seen[c.Hash] = true
+	}
+}
+
+// expiryTime is the time before which entries expire, from the option,
+// the config or the default in that order. "never" keeps everything and
+// "all" or "now" expire everything
+func expiryTime(option, configured, fallback string, now time.Time) (time.Time, error) {
+	value := option
+	if value == "" {
+		value = configured
+	}
+	if value == "" {
+		value = fallback
+	}
+	switch strings.ToLower(value) {
+	case "never", "false":
+		return time.Time{}, nil
+	case "all", "now":
+		return now.Add(time.Second), nil
+	}
+	when, err := identity.ApproxDate(value, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid expiry date '%s'", value)
+	}
+	return when, nil
+}
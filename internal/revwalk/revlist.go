@@ -0,0 +1,218 @@
+package revwalk
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type RevList struct {
+	Fs   *flag.FlagSet
+	Repo *repository.Repository
+	walkOptions
+	count   bool
+	objects bool
+}
+
+func (r *RevList) Initialize(args []string) error {
+	r.define(r.Fs)
+	r.Fs.BoolVar(&r.count, "count", false, "Print how many commits would be listed, for each side with --left-right")
+	r.Fs.BoolVar(&r.objects, "objects", false, "List the trees and blobs of the commits listed and the tags given too")
+	return r.parse(r.Fs, args)
+}
+
+func (r *RevList) Usage() string {
+	return "git rev-list [-n <count>] [--first-parent] [--author=<pattern>] [--grep=<pattern>] [-i] [-E | -F] [--since=<date>] [--until=<date>] [--topo-order | --date-order] [--reverse] [--left-right] [--count] [--objects] [--all] <revision-range>... [[--] <path>...] : List commits in reverse chronological order"
+}
+
+func (r *RevList) Run() error {
+	if len(r.args) == 0 {
+		return errors.New("No revision given")
+	}
+	w, err := r.setup(r.Repo)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var left, right int
+	var trees []objectstore.Hash
+	for {
+		c, err := w.Next()
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			break
+		}
+		if c.Left() {
+			left++
+		} else {
+			right++
+		}
+		if r.objects {
+			trees = append(trees, c.Commit.Tree)
+		}
+		if r.count {
+			continue
+		}
+		if r.leftRight {
+			out.WriteString(sideMark(c))
+		}
+		fmt.Fprintln(out, c.Hash)
+	}
+
+	if r.objects {
+		l := &objectLister{repo: r.Repo, seen: map[objectstore.Hash]bool{}}
+		if w.limitedToPaths() {
+			l.paths = w.Paths
+		}
+		if !r.count {
+			l.out = out
+		}
+		if err := l.list(w.Edges(), r.tags, r.hiddenTags, trees); err != nil {
+			return err
+		}
+		right += l.listed
+	}
+	if r.count {
+		if r.leftRight {
+			fmt.Fprintf(out, "%d\t%d\n", left, right)
+		} else {
+			fmt.Fprintln(out, left+right)
+		}
+	}
+	return nil
+}
+
+// objectLister lists the objects of the commits walked that the hidden
+// ones do not have, each once
+type objectLister struct {
+	repo   *repository.Repository
+	paths  *pathspec.Pathspec // the blobs listed are limited to, or nil
+	out    io.Writer
+	seen   map[objectstore.Hash]bool
+	listed int
+}
+
+// list prints the tags given, then the trees of the commits and what they
+// hold, leaving out what the trees of the edges hold
+func (l *objectLister) list(edges []*Commit, tags []tagObject, hiddenTags map[objectstore.Hash]bool, trees []objectstore.Hash) error {
+	for _, c := range edges {
+		if err := l.hide(c.Commit.Tree); err != nil {
+			return err
+		}
+	}
+	for _, tag := range tags {
+		if hiddenTags[tag.hash] || l.seen[tag.hash] {
+			continue
+		}
+		l.seen[tag.hash] = true
+		l.show(tag.hash, tag.name)
+	}
+	for _, tree := range trees {
+		if _, err := l.tree(tree, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hide marks a tree and everything under it as seen
+func (l *objectLister) hide(hash objectstore.Hash) error {
+	if l.seen[hash] {
+		return nil
+	}
+	l.seen[hash] = true
+	tree, err := object.GetTree(l.repo.Objects, hash)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		switch {
+		case entry.Mode == object.ModeGitlink:
+		case entry.Mode.IsTree():
+			if err := l.hide(entry.Hash); err != nil {
+				return err
+			}
+		default:
+			l.seen[entry.Hash] = true
+		}
+	}
+	return nil
+}
+
+// tree lists a tree not seen yet before what it holds. Limited to paths,
+// it lists only the blobs matching them and the trees leading to those,
+// and tells whether it listed the tree
+func (l *objectLister) tree(hash objectstore.Hash, path string) (bool, error) {
+	if l.seen[hash] {
+		return false, nil
+	}
+	tree, err := object.GetTree(l.repo.Objects, hash)
+	if err != nil {
+		return false, err
+	}
+	// the entries are held back until it is known whether the tree, which
+	// comes before them, is listed
+	out := l.out
+	var held bufferedLines
+	if out != nil {
+		l.out = &held
+	}
+	listedAny := false
+	for _, entry := range tree.Entries {
+		name := entry.Name
+		if path != "" {
+			name = path + "/" + name
+		}
+		switch {
+		case entry.Mode == object.ModeGitlink:
+		case entry.Mode.IsTree():
+			listed, err := l.tree(entry.Hash, name)
+			if err != nil {
+				return false, err
+			}
+			listedAny = listedAny || listed
+		case l.seen[entry.Hash]:
+		case l.paths == nil || l.paths.Match(name):
+			l.seen[entry.Hash] = true
+			l.show(entry.Hash, name)
+			listedAny = true
+		}
+	}
+	l.out = out
+	if l.paths != nil && !listedAny && path != "" {
+		return false, nil
+	}
+	l.seen[hash] = true
+	l.show(hash, path)
+	if out != nil {
+		out.Write(held)
+	}
+	return true, nil
+}
+
+// bufferedLines holds the lines listed under a tree
+type bufferedLines []byte
+
+func (b *bufferedLines) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+func (l *objectLister) show(hash objectstore.Hash, name string) {
+	l.listed++
+	if l.out != nil {
+		fmt.Fprintf(l.out, "%s %s\n", hash, name)
+	}
+}
@@ -0,0 +1,744 @@
+package revwalk
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// flags of a commit during a walk
+const (
+	seen          = 1 << iota // queued, or given as a start
+	added                     // its parents were queued
+	treesame                  // does not change the paths the walk is limited to
+	uninteresting             // hidden, as it can be reached from a hidden commit
+	left                      // reached from the left side of a symmetric range
+	bottom                    // hidden as given, which keeps it relevant
+
+	// flags of the commits painted while looking for merge bases
+	parent1 // reached from the first commit
+	parent2 // reached from the second commit
+	stale   // reached from a common ancestor, so not a base
+	result  // a common ancestor found
+)
+
+// Orders the commits can be sorted in rather than newest first, which can
+// show a parent before its children when clocks were off
+const (
+	// OrderDate shows no parent before its children, newest first otherwise
+	OrderDate = "date"
+	// OrderTopo shows no parent before its children and keeps lines of
+	// history together, the parents of a merge right after it when they can be
+	OrderTopo = "topo"
+)
+
+// slop is how many more commits a walk with hidden commits looks at once
+// every commit left to walk is hidden, in case some are older than they say
+const slop = 5
+
+// Commit is a commit met by a walk. Parents are the ones the walk follows:
+// with paths, a commit that leaves them as one of its parents has them only
+// keeps that parent, and the parents of a shown commit skip the commits that
+// are not shown when parents are rewritten
+type Commit struct {
+	Hash    objectstore.Hash
+	Commit  *object.Commit // nil until the commit is parsed
+	Parents []*Commit
+
+	flags    uint
+	indegree int // for the topological sort
+}
+
+// Date is when the commit was made, what walks order commits by
+func (c *Commit) Date() time.Time {
+	return c.Commit.Committer.When
+}
+
+// Left is whether the commit is reached from the left side of a symmetric
+// range A...B, that is from A
+func (c *Commit) Left() bool {
+	return c.flags&left != 0
+}
+
+// Walker goes through the history from the commits pushed, newest first
+// like git log, leaving out the history of the commits hidden. Its options
+// are set before the first call to Next
+type Walker struct {
+	repo    *repository.Repository
+	commits map[objectstore.Hash]*Commit
+	starts  []*Commit
+	hidden  bool // some starts are hidden
+
+	// FirstParent only follows the first parent of merges
+	FirstParent bool
+	// Paths limits the walk to the commits that change them, nil for all
+	Paths *pathspec.Pathspec
+	// Since and Until leave out the commits made before or after them, zero
+	// for no limit. History is not walked past a commit older than Since
+	Since, Until time.Time
+	// Match leaves out the commits it is false for, nil to show everything
+	Match func(*Commit) bool
+	// Order sorts the commits, OrderDate or OrderTopo, empty for none
+	Order string
+	// Rewrite makes the parents of each commit shown the nearest ancestors
+	// that change the paths, as --graph needs to join them
+	Rewrite bool
+	// MaxCount stops the walk after that many commits, negative for no limit
+	MaxCount int
+	// Reverse shows the commits the walk would, the last one first
+	Reverse bool
+
+	started  bool
+	queue    *dateQueue
+	list     []*Commit // the history, when it is walked first
+	edges    []*Commit // the hidden commits the walked ones reach
+	shown    int
+	reversed []*Commit
+}
+
+func NewWalker(repo *repository.Repository) *Walker {
+	return &Walker{repo: repo, commits: make(map[objectstore.Hash]*Commit), MaxCount: -1}
+}
+
+// lookup is the one Commit for hash, not parsed yet when it is new
+func (w *Walker) lookup(hash objectstore.Hash) *Commit {
+	c, ok := w.commits[hash]
+	if !ok {
+		c = &Commit{Hash: hash}
+		w.commits[hash] = c
+	}
+	return c
+}
+
+// parse reads the commit from the object store, once
+func (w *Walker) parse(c *Commit) error {
+	if c.Commit != nil {
+		return nil
+	}
+	commit, err := object.GetCommit(w.repo.Objects, c.Hash)
+	if err != nil {
+		return err
+	}
+	c.Commit = commit
+	c.Parents = make([]*Commit, len(commit.Parents))
+	for i, parent := range commit.Parents {
+		c.Parents[i] = w.lookup(parent)
+	}
+	return nil
+}
+
+// Push starts the walk at a commit, as well
+func (w *Walker) Push(hash objectstore.Hash) error {
+	return w.push(hash, 0)
+}
+
+// PushLeft starts the walk at the left side of a symmetric range
+func (w *Walker) PushLeft(hash objectstore.Hash) error {
+	return w.push(hash, left)
+}
+
+// Hide leaves out a commit and its history
+func (w *Walker) Hide(hash objectstore.Hash) error {
+	w.hidden = true
+	return w.push(hash, uninteresting|bottom)
+}
+
+func (w *Walker) push(hash objectstore.Hash, flags uint) error {
+	c := w.lookup(hash)
+	if err := w.parse(c); err != nil {
+		return err
+	}
+	c.flags |= flags
+	if c.flags&seen == 0 {
+		c.flags |= seen
+		w.starts = append(w.starts, c)
+	}
+	return nil
+}
+
+// Next is the next commit to show, nil once the walk is over
+func (w *Walker) Next() (*Commit, error) {
+	if !w.Reverse {
+		return w.nextShown()
+	}
+	if w.reversed == nil {
+		w.reversed = []*Commit{}
+		for {
+			c, err := w.nextShown()
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				break
+			}
+			w.reversed = append(w.reversed, c)
+		}
+	}
+	if len(w.reversed) == 0 {
+		return nil, nil
+	}
+	c := w.reversed[len(w.reversed)-1]
+	w.reversed = w.reversed[:len(w.reversed)-1]
+	return c, nil
+}
+
+// Edges are the hidden commits met next to the ones walked: the objects
+// reachable from their trees are hidden too
+func (w *Walker) Edges() []*Commit {
+	return w.edges
+}
+
+func (w *Walker) nextShown() (*Commit, error) {
+	if !w.started {
+		w.started = true
+		if err := w.prepare(); err != nil {
+			return nil, err
+		}
+	}
+	if w.MaxCount >= 0 && w.shown >= w.MaxCount {
+		return nil, nil
+	}
+	for {
+		c, err := w.next()
+		if c == nil || err != nil {
+			return nil, err
+		}
+		show, err := w.Shows(c)
+		if err != nil {
+			return nil, err
+		}
+		if !show {
+			continue
+		}
+		if w.Rewrite && w.limitedToPaths() {
+			if err := w.rewriteParents(c); err != nil {
+				return nil, err
+			}
+		}
+		w.shown++
+		return c, nil
+	}
+}
+
+// next takes the next commit of the history, shown or not
+func (w *Walker) next() (*Commit, error) {
+	if w.list != nil {
+		if len(w.list) == 0 {
+			return nil, nil
+		}
+		c := w.list[0]
+		w.list = w.list[1:]
+		return c, nil
+	}
+	for w.queue.Len() > 0 {
+		c := w.queue.pop()
+		if w.tooOld(c) {
+			continue
+		}
+		if err := w.addParents(c); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, nil
+}
+
+// limited is whether the history is walked beforehand, to hide commits or
+// sort them
+func (w *Walker) limited() bool {
+	return w.hidden || w.Order != ""
+}
+
+// prepare queues the starts, newest first, and walks the history
+// beforehand when it is limited
+func (w *Walker) prepare() error {
+	w.queue = &dateQueue{}
+	for _, c := range sortByDate(w.starts) {
+		w.queue.push(c)
+	}
+	if !w.limited() {
+		return nil
+	}
+	// what is known of the history of the hidden commits is hidden too
+	for _, c := range w.starts {
+		if c.flags&uninteresting != 0 {
+			w.markParentsUninteresting(c)
+		}
+	}
+	list, err := w.limit()
+	if err != nil {
+		return err
+	}
+	for _, c := range list {
+		if c.flags&uninteresting != 0 {
+			w.edges = append(w.edges, c)
+			continue
+		}
+		for _, p := range c.Parents {
+			if p.flags&uninteresting == 0 {
+				continue
+			}
+			if err := w.parse(p); err != nil {
+				return err
+			}
+			w.edges = append(w.edges, p)
+		}
+	}
+	switch w.Order {
+	case OrderTopo:
+		list = topoSort(list, &commitStack{})
+	case OrderDate:
+		list = topoSort(list, &dateQueue{})
+	}
+	w.list = list
+	return nil
+}
+
+// limit walks the history newest first, like git, until only hidden
+// commits are left to walk, and a few more then. Commits older than Since
+// are hidden with their history
+func (w *Walker) limit() ([]*Commit, error) {
+	list := []*Commit{}
+	var last time.Time // the date of the last commit listed
+	slop := slop
+	for w.queue.Len() > 0 {
+		c := w.queue.pop()
+		if w.tooOld(c) {
+			c.flags |= uninteresting
+		}
+		if err := w.addParents(c); err != nil {
+			return nil, err
+		}
+		if c.flags&uninteresting != 0 {
+			w.markParentsUninteresting(c)
+			if slop = w.stillInteresting(last, slop); slop == 0 {
+				break
+			}
+			continue
+		}
+		if w.tooNew(c) {
+			continue
+		}
+		last = c.Date()
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// stillInteresting is how many more hidden commits to walk: a full slop
+// while a commit left is not hidden or is newer than the last one listed
+func (w *Walker) stillInteresting(last time.Time, slopLeft int) int {
+	if w.queue.Len() == 0 {
+		return 0
+	}
+	if !last.IsZero() && !last.After(w.queue.peek().Date()) {
+		return slop
+	}
+	for _, item := range w.queue.items {
+		if item.commit.flags&uninteresting == 0 {
+			return slop
+		}
+	}
+	return slopLeft - 1
+}
+
+// markParentsUninteresting hides the ancestors of c that are known so far,
+// the others are hidden as they are met
+func (w *Walker) markParentsUninteresting(c *Commit) {
+	stack := append([]*Commit(nil), c.Parents...)
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.flags&uninteresting != 0 {
+			continue
+		}
+		p.flags |= uninteresting
+		stack = append(stack, p.Parents...)
+	}
+}
+
+func (w *Walker) tooOld(c *Commit) bool {
+	return !w.Since.IsZero() && c.Date().Before(w.Since)
+}
+
+func (w *Walker) tooNew(c *Commit) bool {
+	return !w.Until.IsZero() && c.Date().After(w.Until)
+}
+
+// addParents simplifies the parents of c for the paths and queues the ones
+// followed that were not met yet. The parents of a hidden commit are all
+// hidden and followed
+func (w *Walker) addParents(c *Commit) error {
+	if c.flags&added != 0 {
+		return nil
+	}
+	c.flags |= added
+	if c.flags&uninteresting != 0 {
+		for _, p := range c.Parents {
+			p.flags |= uninteresting
+			if err := w.parse(p); err != nil {
+				return err
+			}
+			w.markParentsUninteresting(p)
+			if p.flags&seen != 0 {
+				continue
+			}
+			p.flags |= seen
+			w.queue.push(p)
+		}
+		return nil
+	}
+	if w.limitedToPaths() {
+		if err := w.simplify(c); err != nil {
+			return err
+		}
+	}
+	for i, p := range c.Parents {
+		if i > 0 && w.FirstParent {
+			break
+		}
+		if err := w.parse(p); err != nil {
+			return err
+		}
+		p.flags |= c.flags & left
+		if p.flags&seen != 0 {
+			continue
+		}
+		p.flags |= seen
+		w.queue.push(p)
+	}
+	return nil
+}
+
+// Shows is whether c is shown rather than only walked through: it is not
+// hidden, is in the dates asked for, changes the paths and passes Match
+func (w *Walker) Shows(c *Commit) (bool, error) {
+	if err := w.parse(c); err != nil {
+		return false, err
+	}
+	if c.flags&uninteresting != 0 || w.tooOld(c) || w.tooNew(c) {
+		return false, nil
+	}
+	if c.flags&treesame != 0 {
+		// a merge of two shown lines of history ties the graph together
+		if !w.Rewrite || relevantParents(c.Parents) < 2 {
+			return false, nil
+		}
+	}
+	return w.Match == nil || w.Match(c), nil
+}
+
+// relevant is whether c is part of the history shown, which the hidden
+// commits given are as its bottom
+func (c *Commit) relevant() bool {
+	return c.flags&(uninteresting|bottom) != uninteresting
+}
+
+// relevantParents counts the parents that are relevant
+func relevantParents(parents []*Commit) int {
+	n := 0
+	for _, p := range parents {
+		if p.relevant() {
+			n++
+		}
+	}
+	return n
+}
+
+func (w *Walker) limitedToPaths() bool {
+	return w.Paths != nil && !w.Paths.Empty()
+}
+
+// simplify marks c when it does not change the paths, and like git keeps
+// only the first parent that has the same paths as c: the history of the
+// paths is the same along it. Parents that are not relevant are kept, and
+// only matter when no parent is
+func (w *Walker) simplify(c *Commit) error {
+	if len(c.Parents) == 0 {
+		changed, err := w.changed(objectstore.ZeroHash, c.Commit.Tree, "")
+		if err != nil {
+			return err
+		}
+		if !changed {
+			c.flags |= treesame
+		}
+		return nil
+	}
+	relevant := 0
+	relevantChange, irrelevantChange := false, false
+	for i, p := range c.Parents {
+		if p.relevant() {
+			relevant++
+		}
+		if i > 0 && w.FirstParent {
+			break
+		}
+		if err := w.parse(p); err != nil {
+			return err
+		}
+		changed, err := w.changed(p.Commit.Tree, c.Commit.Tree, "")
+		if err != nil {
+			return err
+		}
+		switch {
+		case !changed && p.relevant():
+			c.Parents = []*Commit{p}
+			c.flags |= treesame
+			return nil
+		case !changed:
+		case p.relevant():
+			relevantChange = true
+		default:
+			irrelevantChange = true
+		}
+	}
+	if relevant > 0 && !relevantChange || relevant == 0 && !irrelevantChange {
+		c.flags |= treesame
+	}
+	return nil
+}
+
+// changed is whether a file matching the paths differs between the trees
+// a and b, zero for an empty tree, whose files are under prefix
+func (w *Walker) changed(a, b objectstore.Hash, prefix string) (bool, error) {
+	if a == b {
+		return false, nil
+	}
+	entries := map[string][2]object.TreeEntry{}
+	var names []string
+	for side, hash := range []objectstore.Hash{a, b} {
+		if hash.IsZero() {
+			continue
+		}
+		tree, err := object.GetTree(w.repo.Objects, hash)
+		if err != nil {
+			return false, err
+		}
+		for _, entry := range tree.Entries {
+			pair, ok := entries[entry.Name]
+			if !ok {
+				names = append(names, entry.Name)
+			}
+			pair[side] = entry
+			entries[entry.Name] = pair
+		}
+	}
+	for _, name := range names {
+		pair := entries[name]
+		if pair[0].Hash == pair[1].Hash && pair[0].Mode == pair[1].Mode {
+			continue
+		}
+		path := prefix + name
+		var subA, subB objectstore.Hash
+		fileChanged := false
+		for side, entry := range pair {
+			switch {
+			case entry.Hash.IsZero():
+			case entry.Mode.IsTree() && side == 0:
+				subA = entry.Hash
+			case entry.Mode.IsTree():
+				subB = entry.Hash
+			default:
+				fileChanged = true
+			}
+		}
+		// a file on one side and a directory on the other changes both
+		if fileChanged && w.Paths.Match(path) {
+			return true, nil
+		}
+		if !subA.IsZero() || !subB.IsZero() {
+			changed, err := w.changed(subA, subB, path+"/")
+			if changed || err != nil {
+				return changed, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// rewriteParents makes each parent of c the nearest ancestor along the
+// simplified history that changes the paths, dropping the ones that lead to
+// nothing and duplicates
+func (w *Walker) rewriteParents(c *Commit) error {
+	var parents []*Commit
+	have := map[*Commit]bool{}
+	for _, p := range c.Parents {
+		for p != nil {
+			if err := w.parse(p); err != nil {
+				return err
+			}
+			if !w.limited() {
+				if err := w.addParents(p); err != nil {
+					return err
+				}
+			}
+			if p.flags&(treesame|uninteresting) != treesame {
+				break
+			}
+			if len(p.Parents) == 0 {
+				p = nil
+				break
+			}
+			next := w.oneRelevantParent(p)
+			if next == nil {
+				break
+			}
+			p = next
+		}
+		if p != nil && !have[p] {
+			have[p] = true
+			parents = append(parents, p)
+		}
+	}
+	c.Parents = parents
+	return nil
+}
+
+// oneRelevantParent is the parent the history of a commit that does not
+// change the paths goes on along: its first parent with FirstParent or
+// when it has one, else its only relevant parent, if any
+func (w *Walker) oneRelevantParent(c *Commit) *Commit {
+	if w.FirstParent || len(c.Parents) == 1 {
+		return c.Parents[0]
+	}
+	var relevant *Commit
+	for _, p := range c.Parents {
+		if !p.relevant() {
+			continue
+		}
+		if relevant != nil {
+			return nil
+		}
+		relevant = p
+	}
+	return relevant
+}
+
+// sortByDate is commits newest first, in the order given for the same date
+func sortByDate(commits []*Commit) []*Commit {
+	sorted := append([]*Commit(nil), commits...)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j].Date().After(sorted[j-1].Date()); j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	return sorted
+}
+
+// topoSort orders list, the history walked newest first, so that commits
+// come before their parents. The commits whose children are all out are
+// taken from ready: newest first from a dateQueue, or like git's graph
+// order from a commitStack, where a commit's parents follow it as soon as
+// their other children are out, the last parent first
+func topoSort(list []*Commit, ready commitQueue) []*Commit {
+	for _, c := range list {
+		c.indegree = 1
+	}
+	for _, c := range list {
+		for _, p := range c.Parents {
+			if p.indegree > 0 {
+				p.indegree++
+			}
+		}
+	}
+	// the tips come out in the order of the list
+	var tips []*Commit
+	for _, c := range list {
+		if c.indegree == 1 {
+			tips = append(tips, c)
+		}
+	}
+	if _, ok := ready.(*commitStack); ok {
+		for i, j := 0, len(tips)-1; i < j; i, j = i+1, j-1 {
+			tips[i], tips[j] = tips[j], tips[i]
+		}
+	}
+	for _, c := range tips {
+		ready.push(c)
+	}
+	sorted := make([]*Commit, 0, len(list))
+	for ready.Len() > 0 {
+		c := ready.pop()
+		for _, p := range c.Parents {
+			if p.indegree == 0 {
+				continue
+			}
+			if p.indegree--; p.indegree == 1 {
+				ready.push(p)
+			}
+		}
+		c.indegree = 0
+		sorted = append(sorted, c)
+	}
+	return sorted
+}
+
+// commitQueue holds the commits ready to be sorted
+type commitQueue interface {
+	push(c *Commit)
+	pop() *Commit
+	Len() int
+}
+
+// commitStack gives the last commit pushed first
+type commitStack []*Commit
+
+func (s *commitStack) push(c *Commit) { *s = append(*s, c) }
+
+func (s *commitStack) pop() *Commit {
+	c := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return c
+}
+
+func (s *commitStack) Len() int { return len(*s) }
+
+// dateQueue gives the newest commit first, and the first queued of those
+// with the same date
+type dateQueue struct {
+	items   []queued
+	counter int
+}
+
+type queued struct {
+	commit *Commit
+	order  int
+}
+
+func (q *dateQueue) push(c *Commit) {
+	heap.Push(q, queued{commit: c, order: q.counter})
+	q.counter++
+}
+
+func (q *dateQueue) pop() *Commit {
+	return heap.Pop(q).(*Commit)
+}
+
+// peek is the commit pop would give
+func (q *dateQueue) peek() *Commit {
+	return q.items[0].commit
+}
+
+func (q *dateQueue) Len() int { return len(q.items) }
+
+func (q *dateQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if !a.commit.Date().Equal(b.commit.Date()) {
+		return a.commit.Date().After(b.commit.Date())
+	}
+	return a.order < b.order
+}
+
+func (q *dateQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *dateQueue) Push(x any) { q.items = append(q.items, x.(queued)) }
+
+func (q *dateQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last.commit
+}
@@ -0,0 +1,229 @@
+package rm
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/status"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
+)
+
+type Rm struct {
+	Fs            *flag.FlagSet
+	Repo          *repository.Repository
+	cached        bool // only remove from the index, keep the files
+	recursive     bool
+	force         bool // remove files even when they have local modifications
+	dryRun        bool
+	quiet         bool
+	ignoreUnmatch bool
+	pathspecs     []string
+}
+
+func (r *Rm) Initialize(args []string) error {
+	r.Fs.BoolVar(&r.cached, "cached", false, "Only remove from the index, keep the working tree files")
+	r.Fs.BoolVar(&r.recursive, "r", false, "Allow recursive removal when a leading directory name is given")
+	r.Fs.BoolVar(&r.force, "f", false, "Override the up-to-date check")
+	r.Fs.BoolVar(&r.force, "force", false, "Same as -f")
+	r.Fs.BoolVar(&r.dryRun, "n", false, "Do not remove anything, only show what would be removed")
+	r.Fs.BoolVar(&r.dryRun, "dry-run", false, "Same as -n")
+	r.Fs.BoolVar(&r.quiet, "q", false, "Do not list the removed files")
+	r.Fs.BoolVar(&r.quiet, "quiet", false, "Same as -q")
+	r.Fs.BoolVar(&r.ignoreUnmatch, "ignore-unmatch", false, "Exit with a zero status even if no files matched")
+	err := r.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	r.pathspecs = r.Fs.Args()
+	if len(r.pathspecs) == 0 {
+		return errors.New("No pathspec given. Which files should I remove?")
+	}
+	return nil
+}
+
+func (r *Rm) Usage() string {
+	return "git rm [--cached] [-r] [-f] [-n] [-q] [--ignore-unmatch] <pathspec>... : Remove files from the working tree and from the index"
+}
+
+func (r *Rm) Run() error {
+	if err := r.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	idx, err := r.Repo.ReadIndex()
+	if err != nil {
+		return err
+	}
+	ps, err := pathspec.Parse(r.Repo.WorkTree, r.pathspecs)
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	for _, e := range idx.Entries {
+		if len(targets) > 0 && targets[len(targets)-1] == e.Path || !ps.Match(e.Path) {
+			continue
+		}
+		if !r.recursive && !ps.MatchExact(e.Path) {
+			return fmt.Errorf("not removing '%s' recursively without -r", leadingDirectory(ps, e.Path))
+		}
+		targets = append(targets, e.Path)
+	}
+	if unmatched := ps.Unmatched(); len(unmatched) > 0 && !r.ignoreUnmatch {
+		return fmt.Errorf("pathspec '%s' did not match any files", unmatched[0])
+	}
+
+	if !r.force {
+		if err := r.checkLocalModifications(idx, targets); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range targets {
+		if !r.quiet {
+			fmt.Printf("rm '%s'\n", name)
+		}
+		if r.dryRun {
+			continue
+		}
+		idx.Remove(name)
+		if !r.cached {
+			if err := r.removeFile(name); err != nil {
+				return err
+			}
+		}
+	}
+	if r.dryRun {
+		return nil
+	}
+	return r.Repo.WriteIndex(idx)
+}
+
+// checkLocalModifications refuses to remove what would be lost, like git's
+// three up-to-date checks. Without --cached, a file that differs from its
+// index entry or an entry that differs from HEAD. With --cached, an entry
+// that differs from both, unless it is only an intent to add
+func (r *Rm) checkLocalModifications(idx *index.Index, targets []string) error {
+	head, err := refs.NewStore(r.Repo).Head()
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	// an unborn branch has nothing, so every entry counts as staged
+	headFiles, err := status.TreeFiles(r.Repo.Objects, head)
+	if err != nil {
+		return err
+	}
+	var staged, cached, local []string
+	for _, name := range targets {
+		e := idx.Entry(name)
+		if e == nil {
+			continue // conflicted paths have nothing staged to compare with
+		}
+		full := r.fullPath(name)
+		fi, err := os.Lstat(full)
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || err == nil && fi.IsDir() {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		localChanges := false
+		if e.Changed(fi) || idx.IsRacy(e) {
+			hash, err := worktree.HashFile(r.Repo.Objects, full, fi, false)
+			if err != nil {
+				return err
+			}
+			localChanges = hash != e.Hash || index.ModeFromFileInfo(fi) != e.Mode
+		}
+		inHead, ok := headFiles[name]
+		stagedChanges := !ok || inHead.Mode != e.Mode || inHead.Hash != e.Hash
+
+		switch {
+		case localChanges && stagedChanges:
+			if !r.cached || !e.IntentToAdd {
+				staged = append(staged, name)
+			}
+		case !r.cached:
+			if stagedChanges {
+				cached = append(cached, name)
+			}
+			if localChanges {
+				local = append(local, name)
+			}
+		}
+	}
+
+	var messages []string
+	if len(staged) > 0 {
+		messages = append(messages, listFiles(staged,
+			"the following file has staged content different from both the\nfile and the HEAD:",
+			"the following files have staged content different from both the\nfile and the HEAD:",
+			"\n(use -f to force removal)"))
+	}
+	if len(cached) > 0 {
+		messages = append(messages, listFiles(cached,
+			"the following file has changes staged in the index:",
+			"the following files have changes staged in the index:",
+			"\n(use --cached to keep the file, or -f to force removal)"))
+	}
+	if len(local) > 0 {
+		messages = append(messages, listFiles(local,
+			"the following file has local modifications:",
+			"the following files have local modifications:",
+			"\n(use --cached to keep the file, or -f to force removal)"))
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	// git reports each kind as an error of its own
+	return errors.New(strings.Join(messages, "\nerror: "))
+}
+
+// listFiles is one of the up-to-date check errors, names indented under it
+func listFiles(names []string, one, many, hint string) string {
+	message := one
+	if len(names) > 1 {
+		message = many
+	}
+	return message + "\n    " + strings.Join(names, "\n    ") + hint
+}
+
+// removeFile deletes the file and then its parent directories that became empty
+func (r *Rm) removeFile(name string) error {
+	err := os.Remove(r.fullPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if os.Remove(r.fullPath(dir)) != nil {
+			break // not empty
+		}
+	}
+	return nil
+}
+
+func (r *Rm) fullPath(name string) string {
+	return filepath.Join(r.Repo.WorkTree, filepath.FromSlash(name))
+}
+
+// leadingDirectory is the pathspec that matched name through one of its directories
+func leadingDirectory(ps *pathspec.Pathspec, name string) string {
+	for _, dir := range ps.Literals() {
+		if dir == "" {
+			return "."
+		}
+		if strings.HasPrefix(name, dir+"/") {
+			return dir
+		}
+	}
+	return name
+}
@@ -0,0 +1,305 @@
+package status
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+type Status struct {
+	Fs        *flag.FlagSet
+	Repo      *repository.Repository
+	short     bool
+	porcelain string // "", "v1" or "v2"
+	branch    bool   // show the branch header in the short formats
+	nul       bool   // -z
+	untracked string // -u, empty to use status.showUntrackedFiles
+	noRenames bool
+	pathspecs []string
+
+	// Committing is set when commit shows the status because there is nothing
+	// to commit, and Amend when that commit would have replaced HEAD
+	Committing bool
+	Amend      bool
+
+	out *bufio.Writer
+	cwd string
+}
+
+func (s *Status) Initialize(args []string) error {
+	s.Fs.BoolVar(&s.short, "s", false, "Give the output in the short format")
+	s.Fs.BoolVar(&s.short, "short", false, "Same as -s")
+	s.Fs.StringVar(&s.porcelain, "porcelain", "", "Give the output in a stable format for scripts, v1 or v2")
+	s.Fs.BoolVar(&s.branch, "b", false, "Show the branch and tracking info in the short formats")
+	s.Fs.BoolVar(&s.branch, "branch", false, "Same as -b")
+	s.Fs.BoolVar(&s.nul, "z", false, "Terminate entries with NUL, implies --porcelain=v1 without another format")
+	s.Fs.StringVar(&s.untracked, "untracked-files", "", "Show untracked files: no, normal or all")
+	s.Fs.BoolVar(&s.noRenames, "no-renames", false, "Do not detect renames")
+	err := s.Fs.Parse(optionalValues(args))
+	if err != nil {
+		return err
+	}
+	switch s.porcelain {
+	case "", "v1", "v2":
+	default:
+		return fmt.Errorf("Unsupported porcelain version '%s'", s.porcelain)
+	}
+	switch s.untracked {
+	case "", UntrackedNo, UntrackedNormal, UntrackedAll:
+	default:
+		return fmt.Errorf("Invalid untracked files mode '%s'", s.untracked)
+	}
+	if s.nul && s.porcelain == "" && !s.short {
+		s.porcelain = "v1"
+	}
+	s.pathspecs = s.Fs.Args()
+	return nil
+}
+
+// optionalValues rewrites the options whose value is optional, which the flag
+// package cannot parse: --porcelain, -u and --untracked-files. Bundled short
+// options like -sb are split up
+func optionalValues(args []string) []string {
+	var result []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return append(result, args[i:]...)
+		case arg == "--porcelain":
+			result = append(result, "--porcelain=v1")
+		case arg == "--untracked-files":
+			result = append(result, "--untracked-files=all")
+		case len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			for j := 1; j < len(arg); j++ {
+				if arg[j] == 'u' {
+					mode := arg[j+1:]
+					if mode == "" {
+						mode = UntrackedAll
+					}
+					result = append(result, "--untracked-files="+strings.TrimPrefix(mode, "="))
+					break
+				}
+				result = append(result, "-"+arg[j:j+1])
+			}
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func (s *Status) Usage() string {
+	return "git status [-s | --porcelain[=v1|v2]] [-b] [-z] [-u[<mode>]] [--no-renames] [<pathspec>...] : Show the working tree status"
+}
+
+func (s *Status) Run() error {
+	if err := s.Repo.RequireWorkTree(); err != nil {
+		return err
+	}
+	cfg, err := s.Repo.Config()
+	if err != nil {
+		return err
+	}
+	opts := Options{Untracked: s.untracked, Amend: s.Amend}
+	if opts.Untracked == "" {
+		opts.Untracked, _ = cfg.Get("status.showuntrackedfiles")
+		switch opts.Untracked {
+		case "", UntrackedNormal, UntrackedAll, UntrackedNo:
+		default:
+			return fmt.Errorf("Invalid untracked files mode '%s'", opts.Untracked)
+		}
+	}
+	opts.Renames, err = cfg.GetBool("status.renames", true)
+	if err != nil {
+		return err
+	}
+	opts.Renames = opts.Renames && !s.noRenames
+	if len(s.pathspecs) > 0 {
+		opts.Pathspec, err = pathspec.Parse(s.Repo.WorkTree, s.pathspecs)
+		if err != nil {
+			return err
+		}
+	}
+	st, err := Compute(s.Repo, opts)
+	if err != nil {
+		return err
+	}
+
+	s.cwd, err = os.Getwd()
+	if err != nil {
+		return err
+	}
+	s.out = bufio.NewWriter(os.Stdout)
+	defer s.out.Flush()
+	switch {
+	case s.porcelain == "v2":
+		s.printPorcelainV2(st)
+	case s.porcelain == "v1" || s.short:
+		s.printShort(st)
+	default:
+		return s.printLong(st, opts.Untracked)
+	}
+	return nil
+}
+
+// display is how a path from the top of the work tree is shown: relative to
+// the current directory, except in the porcelain v1 format and with -z
+func (s *Status) display(name string) string {
+	if s.porcelain == "v1" || s.nul {
+		return name
+	}
+	rel, err := filepath.Rel(s.cwd, filepath.Join(s.Repo.WorkTree, filepath.FromSlash(name)))
+	if err != nil {
+		return name
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(name, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+func shortRef(ref string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/tags/", "refs/"} {
+		if short, ok := strings.CutPrefix(ref, prefix); ok {
+			return short
+		}
+	}
+	return ref
+}
+
+func (s *Status) terminator() string {
+	if s.nul {
+		return "\x00"
+	}
+	return "\n"
+}
+
+func (s *Status) printShort(st *Result) {
+	if s.branch {
+		s.printBranchLine(st)
+	}
+	for _, e := range st.Entries {
+		switch {
+		case e.OrigPath != "" && s.nul:
+			fmt.Fprintf(s.out, "%c%c %s\x00%s\x00", e.Staged, e.Unstaged, s.display(e.Path), s.display(e.OrigPath))
+		case e.OrigPath != "":
+			fmt.Fprintf(s.out, "%c%c %s -> %s\n", e.Staged, e.Unstaged, s.display(e.OrigPath), s.display(e.Path))
+		default:
+			fmt.Fprintf(s.out, "%c%c %s%s", e.Staged, e.Unstaged, s.display(e.Path), s.terminator())
+		}
+	}
+	for _, name := range st.Untracked {
+		fmt.Fprintf(s.out, "?? %s%s", s.display(name), s.terminator())
+	}
+}
+
+// printBranchLine is the "## main...origin/main [ahead 1]" header
+func (s *Status) printBranchLine(st *Result) {
+	fmt.Fprint(s.out, "## ")
+	switch {
+	case st.Branch == "":
+		fmt.Fprint(s.out, "HEAD (no branch)")
+	case st.Initial():
+		fmt.Fprintf(s.out, "No commits yet on %s", shortRef(st.Branch))
+	default:
+		fmt.Fprint(s.out, shortRef(st.Branch))
+	}
+	if st.Branch != "" && st.Upstream != "" {
+		fmt.Fprintf(s.out, "...%s", shortRef(st.Upstream))
+		switch {
+		case st.UpstreamGone:
+			fmt.Fprint(s.out, " [gone]")
+		case st.Ahead > 0 && st.Behind > 0:
+			fmt.Fprintf(s.out, " [ahead %d, behind %d]", st.Ahead, st.Behind)
+		case st.Ahead > 0:
+			fmt.Fprintf(s.out, " [ahead %d]", st.Ahead)
+		case st.Behind > 0:
+			fmt.Fprintf(s.out, " [behind %d]", st.Behind)
+		}
+	}
+	fmt.Fprint(s.out, s.terminator())
+}
+
+func (s *Status) printPorcelainV2(st *Result) {
+	if s.branch {
+		if st.Initial() {
+			fmt.Fprintf(s.out, "# branch.oid (initial)%s", s.terminator())
+		} else {
+			fmt.Fprintf(s.out, "# branch.oid %s%s", st.Head, s.terminator())
+		}
+		if st.Branch == "" {
+			fmt.Fprintf(s.out, "# branch.head (detached)%s", s.terminator())
+		} else {
+			fmt.Fprintf(s.out, "# branch.head %s%s", shortRef(st.Branch), s.terminator())
+		}
+		if st.Branch != "" && st.Upstream != "" {
+			fmt.Fprintf(s.out, "# branch.upstream %s%s", shortRef(st.Upstream), s.terminator())
+			if !st.UpstreamGone {
+				fmt.Fprintf(s.out, "# branch.ab +%d -%d%s", st.Ahead, st.Behind, s.terminator())
+			}
+		}
+	}
+
+	// unmerged paths come after the other changes
+	var changed, unmerged []*Entry
+	for _, e := range st.Entries {
+		if e.Unmerged() {
+			unmerged = append(unmerged, e)
+		} else {
+			changed = append(changed, e)
+		}
+	}
+	for _, e := range append(changed, unmerged...) {
+		xy := strings.Map(func(r rune) rune {
+			if r == ' ' {
+				return '.'
+			}
+			return r
+		}, string([]byte{e.Staged, e.Unstaged}))
+		switch {
+		case e.Unmerged():
+			fmt.Fprintf(s.out, "u %s %s %06o %06o %06o %06o %s %s %s %s%s", xy, submoduleState(e),
+				uint32(e.StageModes[0]), uint32(e.StageModes[1]), uint32(e.StageModes[2]), uint32(e.WorktreeMode),
+				e.StageHashes[0], e.StageHashes[1], e.StageHashes[2], s.display(e.Path), s.terminator())
+		case e.OrigPath != "":
+			separator := "\t"
+			if s.nul {
+				separator = "\x00"
+			}
+			fmt.Fprintf(s.out, "2 %s %s %06o %06o %06o %s %s R%d %s%s%s%s", xy, submoduleState(e),
+				uint32(e.HeadMode), uint32(e.IndexMode), uint32(e.WorktreeMode), e.HeadHash, e.IndexHash,
+				e.Score, s.display(e.Path), separator, s.display(e.OrigPath), s.terminator())
+		default:
+			fmt.Fprintf(s.out, "1 %s %s %06o %06o %06o %s %s %s%s", xy, submoduleState(e),
+				uint32(e.HeadMode), uint32(e.IndexMode), uint32(e.WorktreeMode), e.HeadHash, e.IndexHash,
+				s.display(e.Path), s.terminator())
+		}
+	}
+	for _, name := range st.Untracked {
+		fmt.Fprintf(s.out, "? %s%s", s.display(name), s.terminator())
+	}
+}
+
+// submoduleState is "N..." for files and "S<c>.." for submodules, where c
+// tells whether another commit is checked out
+func submoduleState(e *Entry) string {
+	if !isGitlink(e) {
+		return "N..."
+	}
+	if e.SubmoduleCommit {
+		return "SC.."
+	}
+	return "S..."
+}
+
+func isGitlink(e *Entry) bool {
+	return e.HeadMode == object.ModeGitlink || e.IndexMode == object.ModeGitlink || e.WorktreeMode == object.ModeGitlink
+}
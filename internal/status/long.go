@@ -0,0 +1,277 @@
+package status
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+)
+
+// labels are padded to the longest one plus a space, like git does
+const (
+	changeLabelWidth   = len("typechange:") + 1
+	unmergedLabelWidth = len("deleted by them:") + 1
+)
+
+var changeLabels = map[byte]string{
+	'A': "new file:",
+	'M': "modified:",
+	'D': "deleted:",
+	'R': "renamed:",
+	'T': "typechange:",
+}
+
+var unmergedLabels = map[string]string{
+	"DD": "both deleted:",
+	"AU": "added by us:",
+	"UD": "deleted by them:",
+	"UA": "added by them:",
+	"DU": "deleted by us:",
+	"AA": "both added:",
+	"UU": "both modified:",
+}
+
+func (s *Status) printLong(st *Result, untrackedMode string) error {
+	if st.Branch != "" {
+		fmt.Fprintf(s.out, "On branch %s\n", shortRef(st.Branch))
+	} else {
+		header := DetachedHead(s.Repo, st.Head)
+		if header == "" {
+			header = "Not currently on any branch."
+		}
+		fmt.Fprintln(s.out, header)
+	}
+	if st.Branch != "" && !st.Initial() {
+		s.printTracking(st)
+	}
+
+	var staged, unstaged, unmerged []*Entry
+	for _, e := range st.Entries {
+		switch {
+		case e.Unmerged():
+			unmerged = append(unmerged, e)
+		default:
+			if e.Staged != ' ' {
+				staged = append(staged, e)
+			}
+			if e.Unstaged != ' ' {
+				unstaged = append(unstaged, e)
+			}
+		}
+	}
+
+	_, err := os.Stat(s.Repo.Path("MERGE_HEAD"))
+	merging := err == nil
+	if merging {
+		if len(unmerged) > 0 {
+			fmt.Fprint(s.out, "You have unmerged paths.\n  (fix conflicts and run \"git commit\")\n  (use \"git merge --abort\" to abort the merge)\n\n")
+		} else {
+			fmt.Fprint(s.out, "All conflicts fixed but you are still merging.\n  (use \"git commit\" to conclude merge)\n\n")
+		}
+	}
+	if st.Initial() && s.Committing {
+		fmt.Fprint(s.out, "\nInitial commit\n\n")
+	} else if st.Initial() {
+		fmt.Fprint(s.out, "\nNo commits yet\n\n")
+	}
+
+	unstageHint := "  (use \"git restore --staged <file>...\" to unstage)"
+	if st.Initial() {
+		unstageHint = "  (use \"git rm --cached <file>...\" to unstage)"
+	}
+	if len(staged) > 0 {
+		fmt.Fprintln(s.out, "Changes to be committed:")
+		if !merging {
+			fmt.Fprintln(s.out, unstageHint)
+		}
+		for _, e := range staged {
+			s.printChange(e, e.Staged)
+		}
+		fmt.Fprintln(s.out)
+	}
+
+	if len(unmerged) > 0 {
+		fmt.Fprintln(s.out, "Unmerged paths:")
+		if !merging {
+			fmt.Fprintln(s.out, unstageHint)
+		}
+		bothDeleted, deleteConflict := false, false
+		for _, e := range unmerged {
+			bothDeleted = bothDeleted || e.Conflict == "DD"
+			deleteConflict = deleteConflict || e.Conflict == "UD" || e.Conflict == "DU"
+		}
+		switch {
+		case deleteConflict:
+			fmt.Fprintln(s.out, "  (use \"git add/rm <file>...\" as appropriate to mark resolution)")
+		case bothDeleted:
+			fmt.Fprintln(s.out, "  (use \"git rm <file>...\" to mark resolution)")
+		default:
+			fmt.Fprintln(s.out, "  (use \"git add <file>...\" to mark resolution)")
+		}
+		for _, e := range unmerged {
+			label := unmergedLabels[e.Conflict]
+			fmt.Fprintf(s.out, "\t%-*s%s\n", unmergedLabelWidth, label, s.display(e.Path))
+		}
+		fmt.Fprintln(s.out)
+	}
+
+	if len(unstaged) > 0 {
+		hasDeleted := false
+		for _, e := range unstaged {
+			hasDeleted = hasDeleted || e.Unstaged == 'D'
+		}
+		fmt.Fprintln(s.out, "Changes not staged for commit:")
+		if hasDeleted {
+			fmt.Fprintln(s.out, "  (use \"git add/rm <file>...\" to update what will be committed)")
+		} else {
+			fmt.Fprintln(s.out, "  (use \"git add <file>...\" to update what will be committed)")
+		}
+		fmt.Fprintln(s.out, "  (use \"git restore <file>...\" to discard changes in working directory)")
+		for _, e := range unstaged {
+			s.printChange(e, e.Unstaged)
+		}
+		fmt.Fprintln(s.out)
+	}
+
+	if untrackedMode != UntrackedNo {
+		if len(st.Untracked) > 0 {
+			fmt.Fprint(s.out, "Untracked files:\n  (use \"git add <file>...\" to include in what will be committed)\n")
+			for _, name := range st.Untracked {
+				fmt.Fprintf(s.out, "\t%s\n", s.display(name))
+			}
+			fmt.Fprintln(s.out)
+		}
+	} else if len(staged) > 0 {
+		fmt.Fprint(s.out, "Untracked files not listed (use -u option to show untracked files)\n")
+	}
+
+	switch {
+	case len(staged) > 0:
+	case s.Amend:
+		fmt.Fprintln(s.out, "No changes")
+	case len(unstaged) > 0 || len(unmerged) > 0:
+		fmt.Fprintln(s.out, "no changes added to commit (use \"git add\" and/or \"git commit -a\")")
+	case len(st.Untracked) > 0:
+		fmt.Fprintln(s.out, "nothing added to commit but untracked files present (use \"git add\" to track)")
+	case st.Initial():
+		fmt.Fprintln(s.out, "nothing to commit (create/copy files and use \"git add\" to track)")
+	case untrackedMode == UntrackedNo:
+		fmt.Fprintln(s.out, "nothing to commit (use -u to show untracked files)")
+	default:
+		fmt.Fprintln(s.out, "nothing to commit, working tree clean")
+	}
+	return nil
+}
+
+// printChange prints one line of the staged or unstaged changes, where an
+// intent-to-add file shows up as a new file not staged yet
+func (s *Status) printChange(e *Entry, state byte) {
+	name := s.display(e.Path)
+	if state == 'R' {
+		name = s.display(e.OrigPath) + " -> " + name
+	}
+	if state == 'M' && e.SubmoduleCommit && state == e.Unstaged {
+		name += " (new commits)"
+	}
+	fmt.Fprintf(s.out, "\t%-*s%s\n", changeLabelWidth, changeLabels[state], name)
+}
+
+// printTracking compares the branch with its upstream
+func (s *Status) printTracking(st *Result) {
+	if text := Tracking(st); text != "" {
+		fmt.Fprintln(s.out, text)
+	}
+}
+
+// Tracking tells how the branch compares with its upstream, in the lines
+// status and checkout print, empty without an upstream
+func Tracking(st *Result) string {
+	if st.Upstream == "" {
+		return ""
+	}
+	var b strings.Builder
+	upstream := shortRef(st.Upstream)
+	switch {
+	case st.UpstreamGone:
+		fmt.Fprintf(&b, "Your branch is based on '%s', but the upstream is gone.\n", upstream)
+		fmt.Fprintln(&b, "  (use \"git branch --unset-upstream\" to fixup)")
+	case st.Ahead == 0 && st.Behind == 0:
+		fmt.Fprintf(&b, "Your branch is up to date with '%s'.\n", upstream)
+	case st.Behind == 0:
+		fmt.Fprintf(&b, "Your branch is ahead of '%s' by %d %s.\n", upstream, st.Ahead, Plural(st.Ahead, "commit", "commits"))
+		fmt.Fprintln(&b, "  (use \"git push\" to publish your local commits)")
+	case st.Ahead == 0:
+		fmt.Fprintf(&b, "Your branch is behind '%s' by %d %s, and can be fast-forwarded.\n", upstream, st.Behind, Plural(st.Behind, "commit", "commits"))
+		fmt.Fprintln(&b, "  (use \"git pull\" to update your local branch)")
+	default:
+		fmt.Fprintf(&b, "Your branch and '%s' have diverged,\nand have %d and %d different commits each, respectively.\n", upstream, st.Ahead, st.Behind)
+		fmt.Fprintln(&b, "  (use \"git pull\" to merge the remote branch into yours)")
+	}
+	return b.String()
+}
+
+// Plural is one or many, whichever fits a count of n
+func Plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// DetachedHead names what HEAD was detached at, from the last checkout
+// recorded in the HEAD reflog: a tag, a remote-tracking branch or a commit.
+// It is empty when the reflog does not tell
+func DetachedHead(repo *repository.Repository, head objectstore.Hash) string {
+	data, err := os.ReadFile(repo.Path("logs", "HEAD"))
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		_, message, ok := strings.Cut(lines[i], "\t")
+		if !ok {
+			continue
+		}
+		rest, ok := strings.CutPrefix(message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		idx := strings.LastIndex(rest, " to ")
+		if idx < 0 {
+			continue
+		}
+		target := rest[idx+len(" to "):]
+		fields := strings.Fields(lines[i])
+		if len(fields) < 2 {
+			break
+		}
+		to, err := objectstore.ParseHash(fields[1])
+		if err != nil {
+			break
+		}
+
+		// the abbreviated commit, unless a tag or remote-tracking branch of
+		// that name still points to it
+		from := to.String()[:7]
+		store := refs.NewStore(repo)
+		for _, ref := range []string{"refs/tags/" + target, "refs/remotes/" + target} {
+			hash, err := store.Hash(ref)
+			if err != nil {
+				continue
+			}
+			if _, peeled, err := object.Peel(repo.Objects, hash); err == nil && peeled == to {
+				from = target
+				break
+			}
+		}
+		if to == head {
+			return "HEAD detached at " + from
+		}
+		return "HEAD detached from " + from
+	}
+	return ""
+}
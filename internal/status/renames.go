@@ -0,0 +1,254 @@
+package status
+
+import (
+	"bytes"
+	"path"
+	"sort"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+)
+
+// Scores are fractions of maxScore like in git, a pair is a rename from
+// minScore (50%) on
+const (
+	maxScore = 60000
+	minScore = 30000
+	hashBase = 107927
+
+	candidatesPerTarget = 4
+)
+
+var emptyBlob, _ = objectstore.ParseHash("e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+
+// detectRenames pairs files deleted from HEAD with files added to the index.
+// Identical content is paired first, then files that kept their name in
+// another directory and then the most similar regular files. A pair becomes a
+// single entry with Staged set to 'R'
+func detectRenames(store objectstore.ObjectStore, entries []*Entry) ([]*Entry, error) {
+	var sources, targets []*Entry
+	for _, e := range entries {
+		switch {
+		case e.Unmerged():
+		case e.Staged == 'D' && e.IndexMode == 0 && e.HeadHash != emptyBlob:
+			sources = append(sources, e)
+		case e.Staged == 'A' && e.IndexHash != emptyBlob:
+			targets = append(targets, e)
+		}
+	}
+	if len(sources) == 0 || len(targets) == 0 {
+		return entries, nil
+	}
+
+	used := make(map[*Entry]bool)
+	renamed := make(map[*Entry]bool)
+	pair := func(src, dst *Entry, score int) {
+		used[src], renamed[dst] = true, true
+		dst.Staged = 'R'
+		dst.OrigPath = src.Path
+		dst.HeadMode, dst.HeadHash = src.HeadMode, src.HeadHash
+		dst.Score = score * 100 / maxScore
+	}
+
+	// exact renames, preferring a source with the same file name
+	for _, dst := range targets {
+		var match *Entry
+		for _, src := range sources {
+			if used[src] || src.HeadHash != dst.IndexHash || typeOf(src.HeadMode) != typeOf(dst.IndexMode) {
+				continue
+			}
+			if match == nil || path.Base(src.Path) == path.Base(dst.Path) && path.Base(match.Path) != path.Base(dst.Path) {
+				match = src
+			}
+		}
+		if match != nil {
+			pair(match, dst, maxScore)
+		}
+	}
+
+	var err error
+	blobs := make(map[objectstore.Hash][]byte)
+	spans := make(map[objectstore.Hash]map[uint32]int)
+	score := func(src, dst *Entry) (int, error) {
+		if !isFile(src.HeadMode) || !isFile(dst.IndexMode) {
+			return 0, nil
+		}
+		var data [2][]byte
+		for i, hash := range []objectstore.Hash{src.HeadHash, dst.IndexHash} {
+			if data[i] = blobs[hash]; data[i] == nil {
+				blob, err := object.GetBlob(store, hash)
+				if err != nil {
+					return 0, err
+				}
+				data[i] = blob.Data
+				blobs[hash] = blob.Data
+			}
+		}
+		return similarity(data[0], data[1], spans, src.HeadHash, dst.IndexHash), nil
+	}
+
+	// a file moved to another directory under the same name, when that name
+	// is unique on both sides
+	var sourceNames, targetNames map[string]*Entry
+	sourceNames, sources = uniqueNames(sources, used)
+	targetNames, targets = uniqueNames(targets, renamed)
+	for name, src := range sourceNames {
+		dst := targetNames[name]
+		if src == nil || dst == nil {
+			continue
+		}
+		s, err := score(src, dst)
+		if err != nil {
+			return nil, err
+		}
+		if s >= minScore+(maxScore-minScore)/2 {
+			pair(src, dst, s)
+		}
+	}
+
+	// then the most similar pairs, from the best few candidates of each target
+	type candidate struct {
+		src, dst *Entry
+		score    int
+		sameName bool
+	}
+	better := func(a, b candidate) bool {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.sameName && !b.sameName
+	}
+	var candidates []candidate
+	for _, dst := range targets {
+		if renamed[dst] {
+			continue
+		}
+		var best []candidate
+		for _, src := range sources {
+			if used[src] {
+				continue
+			}
+			c := candidate{src: src, dst: dst, sameName: path.Base(src.Path) == path.Base(dst.Path)}
+			if c.score, err = score(src, dst); err != nil {
+				return nil, err
+			}
+			if c.score < minScore {
+				continue
+			}
+			best = append(best, c)
+			sort.SliceStable(best, func(i, j int) bool { return better(best[i], best[j]) })
+			if len(best) > candidatesPerTarget {
+				best = best[:candidatesPerTarget]
+			}
+		}
+		candidates = append(candidates, best...)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return better(candidates[i], candidates[j])
+	})
+	for _, c := range candidates {
+		if !used[c.src] && !renamed[c.dst] {
+			pair(c.src, c.dst, c.score)
+		}
+	}
+
+	var result []*Entry
+	for _, e := range entries {
+		if !used[e] {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// uniqueNames maps the file names that occur once among the entries not yet
+// paired to their entry, and returns the entries not yet paired
+func uniqueNames(entries []*Entry, paired map[*Entry]bool) (map[string]*Entry, []*Entry) {
+	names := make(map[string]*Entry)
+	var rest []*Entry
+	for _, e := range entries {
+		if paired[e] {
+			continue
+		}
+		rest = append(rest, e)
+		name := path.Base(e.Path)
+		if _, ok := names[name]; ok {
+			names[name] = nil
+		} else {
+			names[name] = e
+		}
+	}
+	return names, rest
+}
+
+func isFile(mode object.FileMode) bool {
+	return mode == object.ModeBlob || mode == object.ModeExecutable
+}
+
+// similarity is git's estimate of how much of dst was copied from src, the
+// span hashes of each blob are cached in spans
+func similarity(src, dst []byte, spans map[objectstore.Hash]map[uint32]int, srcHash, dstHash objectstore.Hash) int {
+	maxSize, baseSize := len(src), len(dst)
+	if maxSize < baseSize {
+		maxSize, baseSize = baseSize, maxSize
+	}
+	if maxSize == 0 || len(dst) == 0 {
+		return 0
+	}
+	// too different in size to reach the minimum score
+	if maxSize*(maxScore-minScore) < (maxSize-baseSize)*maxScore {
+		return 0
+	}
+	srcSpans, ok := spans[srcHash]
+	if !ok {
+		srcSpans = hashSpans(src)
+		spans[srcHash] = srcSpans
+	}
+	dstSpans, ok := spans[dstHash]
+	if !ok {
+		dstSpans = hashSpans(dst)
+		spans[dstHash] = dstSpans
+	}
+	copied := 0
+	for hash, srcCount := range srcSpans {
+		dstCount := dstSpans[hash]
+		if srcCount < dstCount {
+			copied += srcCount
+		} else {
+			copied += dstCount
+		}
+	}
+	return copied * maxScore / maxSize
+}
+
+// hashSpans cuts data into lines of at most 64 bytes and counts the bytes
+// per line hash. A CR before a LF is ignored in text
+func hashSpans(data []byte) map[uint32]int {
+	checked := data
+	if len(checked) > 8000 {
+		checked = checked[:8000]
+	}
+	isText := bytes.IndexByte(checked, 0) < 0
+
+	spans := make(map[uint32]int)
+	var accum1, accum2 uint32
+	n := 0
+	for i, c := range data {
+		if isText && c == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		old1 := accum1
+		accum1 = accum1<<7 ^ accum2>>25
+		accum2 = accum2<<7 ^ old1>>25
+		accum1 += uint32(c)
+		n++
+		if n < 64 && c != '\n' {
+			continue
+		}
+		spans[(accum1+accum2*0x61)%hashBase] += n
+		n = 0
+		accum1, accum2 = 0, 0
+	}
+	// like git, an incomplete last line does not count
+	return spans
+}
@@ -0,0 +1,482 @@
+package status
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/config"
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/pathspec"
+	"github.com/codecrafters-io/git-starter-go/internal/refs"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revwalk"
+	"github.com/codecrafters-io/git-starter-go/internal/worktree"
+)
+
+// Entry is a path that differs between HEAD, the index and the work tree.
+// Staged compares HEAD with the index and Unstaged the index with the work
+// tree, using the letters of "git status --short"
+type Entry struct {
+	Path     string
+	OrigPath string // the path in HEAD when Staged is 'R'
+	Staged   byte   // ' ', 'A', 'M', 'D', 'R' or 'T' (type change)
+	Unstaged byte   // ' ', 'M', 'D', 'T' or 'A' for an intent-to-add file
+	Score    int    // similarity of a rename, in percent
+
+	HeadMode, IndexMode, WorktreeMode object.FileMode
+	HeadHash, IndexHash               objectstore.Hash
+
+	// SubmoduleCommit is set when a gitlink has a different commit checked out
+	SubmoduleCommit bool
+
+	// Conflict is the two letter state of an unmerged path, like "UU". The
+	// stages are indexed by stage number - 1 and have a zero mode when missing
+	Conflict    string
+	StageModes  [3]object.FileMode
+	StageHashes [3]objectstore.Hash
+}
+
+func (e *Entry) Unmerged() bool {
+	return e.Conflict != ""
+}
+
+// Result is the state of the repository as "git status" reports it
+type Result struct {
+	Branch string           // like refs/heads/main, empty when HEAD is detached
+	Head   objectstore.Hash // zero before the first commit
+
+	Upstream     string // like refs/remotes/origin/main, empty without one
+	UpstreamGone bool   // the upstream is configured but its ref does not exist
+	Ahead        int
+	Behind       int
+
+	Entries   []*Entry // sorted by path
+	Untracked []string // slash separated, directories end with a slash
+}
+
+func (s *Result) Initial() bool {
+	return s.Head.IsZero()
+}
+
+// Untracked file modes, like status.showUntrackedFiles
+const (
+	UntrackedNo     = "no"
+	UntrackedNormal = "normal" // untracked directories are shown as a whole
+	UntrackedAll    = "all"
+)
+
+type Options struct {
+	Untracked string
+	Renames   bool
+	Pathspec  *pathspec.Pathspec // nil for everything
+
+	// Amend compares the index with the parent of HEAD instead, like the
+	// status shown by commit --amend
+	Amend bool
+}
+
+type computer struct {
+	repo     *repository.Repository
+	refs     *refs.Store
+	cfg      *config.Config
+	idx      *index.Index
+	opts     Options
+	fileMode bool
+	symlinks bool
+	refresh  bool // stat data in the index was brought up to date
+}
+
+// Compute compares HEAD, the index and the work tree. Files whose stat data
+// no longer matches the index, or that were modified too close to the last
+// index write to trust it, are compared by content. Stat data found to be
+// stale for unchanged files is written back to the index when possible
+func Compute(repo *repository.Repository, opts Options) (*Result, error) {
+	if err := repo.RequireWorkTree(); err != nil {
+		return nil, err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Untracked == "" {
+		opts.Untracked = UntrackedNormal
+	}
+	c := &computer{repo: repo, refs: refs.NewStore(repo), cfg: cfg, idx: idx, opts: opts}
+	c.fileMode, err = cfg.GetBool("core.filemode", true)
+	if err != nil {
+		return nil, err
+	}
+	c.symlinks, err = cfg.GetBool("core.symlinks", true)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Result{}
+	if err := c.readHead(st); err != nil {
+		return nil, err
+	}
+	base := st.Head
+	if opts.Amend && !st.Initial() {
+		head, err := object.GetCommit(repo.Objects, st.Head)
+		if err != nil {
+			return nil, err
+		}
+		base = objectstore.ZeroHash
+		if len(head.Parents) > 0 {
+			base = head.Parents[0]
+		}
+	}
+	headFiles, err := TreeFiles(repo.Objects, base)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := c.compare(headFiles)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	if opts.Renames {
+		entries, err = detectRenames(repo.Objects, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range entries {
+		if c.selected(e.Path) || e.OrigPath != "" && c.selected(e.OrigPath) {
+			st.Entries = append(st.Entries, e)
+		}
+	}
+	sort.SliceStable(st.Entries, func(i, j int) bool {
+		return st.Entries[i].Path < st.Entries[j].Path
+	})
+
+	if opts.Untracked != UntrackedNo {
+		st.Untracked, err = c.untracked()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.refresh {
+		// only an optimisation, another process may hold the index
+		repo.WriteIndex(idx)
+	}
+	return st, nil
+}
+
+// CompareTrees lists the files that differ between two trees or commits, in
+// Staged as if from was HEAD and to the index. Either may be zero for the
+// empty tree
+func CompareTrees(store objectstore.ObjectStore, from, to objectstore.Hash, renames bool) ([]*Entry, error) {
+	fromFiles, err := TreeFiles(store, from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := TreeFiles(store, to)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for name, old := range fromFiles {
+		e := &Entry{Path: name, Staged: 'D', Unstaged: ' ', HeadMode: old.Mode, HeadHash: old.Hash}
+		if entry, ok := toFiles[name]; ok {
+			e.IndexMode, e.IndexHash = entry.Mode, entry.Hash
+			switch {
+			case typeOf(old.Mode) != typeOf(entry.Mode):
+				e.Staged = 'T'
+			case old.Hash != entry.Hash || old.Mode != entry.Mode:
+				e.Staged = 'M'
+			default:
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	for name, entry := range toFiles {
+		if _, ok := fromFiles[name]; !ok {
+			entries = append(entries, &Entry{Path: name, Staged: 'A', Unstaged: ' ', IndexMode: entry.Mode, IndexHash: entry.Hash})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	if renames {
+		entries, err = detectRenames(store, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// TreeFiles maps the paths of the files in a tree, or in the tree of a commit
+func TreeFiles(store objectstore.ObjectStore, hash objectstore.Hash) (map[string]object.TreeEntry, error) {
+	files := make(map[string]object.TreeEntry)
+	if hash.IsZero() {
+		return files, nil
+	}
+	_, tree, err := object.PeelToTree(store, hash)
+	if err != nil {
+		return nil, err
+	}
+	err = object.WalkTree(store, tree, func(name string, entry object.TreeEntry) error {
+		files[name] = entry
+		return nil
+	})
+	return files, err
+}
+
+func (c *computer) selected(name string) bool {
+	return c.opts.Pathspec == nil || c.opts.Pathspec.Match(name)
+}
+
+// readHead fills in the branch, HEAD and the upstream tracking counts
+func (c *computer) readHead(st *Result) error {
+	branch, err := c.refs.HeadBranch()
+	if err != nil {
+		return err
+	}
+	st.Branch = branch
+	head, err := c.refs.Head()
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	st.Head = head
+	if branch == "" {
+		return nil
+	}
+	st.Upstream = refs.Upstream(c.cfg, branch)
+	if st.Upstream == "" {
+		return nil
+	}
+	upstream, err := c.refs.Hash(st.Upstream)
+	if errors.Is(err, refs.ErrNotFound) {
+		st.UpstreamGone = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if st.Initial() {
+		return nil
+	}
+	st.Ahead, st.Behind, err = revwalk.AheadBehind(c.repo, st.Head, upstream)
+	return err
+}
+
+// compare builds an entry for every path that differs between HEAD, the
+// index and the work tree
+func (c *computer) compare(headFiles map[string]object.TreeEntry) ([]*Entry, error) {
+	var entries []*Entry
+	inIndex := make(map[string]bool)
+	for i := 0; i < len(c.idx.Entries); {
+		e := c.idx.Entries[i]
+		inIndex[e.Path] = true
+		stages := c.idx.Stages(e.Path)
+		i += len(stages)
+
+		if e.Stage != index.StageMerged {
+			st := conflict(e.Path, stages)
+			if fi, err := os.Lstat(filepath.Join(c.repo.WorkTree, filepath.FromSlash(e.Path))); err == nil {
+				st.WorktreeMode = index.ModeFromFileInfo(fi)
+			}
+			entries = append(entries, st)
+			continue
+		}
+
+		st := &Entry{Path: e.Path, Staged: ' ', Unstaged: ' ', IndexMode: e.Mode, IndexHash: e.Hash}
+		if e.IntentToAdd {
+			// the index has no content for it yet
+			st.IndexMode, st.IndexHash = 0, objectstore.ZeroHash
+		}
+		if head, ok := headFiles[e.Path]; ok {
+			st.HeadMode, st.HeadHash = head.Mode, head.Hash
+			switch {
+			case e.IntentToAdd:
+				st.Staged = 'D'
+			case typeOf(head.Mode) != typeOf(e.Mode):
+				st.Staged = 'T'
+			case head.Hash != e.Hash || head.Mode != e.Mode:
+				st.Staged = 'M'
+			}
+		} else if !e.IntentToAdd {
+			st.Staged = 'A'
+		}
+
+		if err := c.compareWorktree(e, st); err != nil {
+			return nil, err
+		}
+		if st.Staged != ' ' || st.Unstaged != ' ' {
+			entries = append(entries, st)
+		}
+	}
+
+	for name, head := range headFiles {
+		if !inIndex[name] {
+			entries = append(entries, &Entry{Path: name, Staged: 'D', Unstaged: ' ', HeadMode: head.Mode, HeadHash: head.Hash})
+		}
+	}
+	return entries, nil
+}
+
+// compareWorktree sets the unstaged state of the index entry e
+func (c *computer) compareWorktree(e *index.Entry, st *Entry) error {
+	full := filepath.Join(c.repo.WorkTree, filepath.FromSlash(e.Path))
+	fi, err := os.Lstat(full)
+	if os.IsNotExist(err) || err == nil && fi.IsDir() && e.Mode != object.ModeGitlink {
+		st.Unstaged = 'D'
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	mode := index.ModeFromFileInfo(fi)
+	// without core.fileMode the executable bit of the index is kept
+	if !c.fileMode && typeOf(mode) == object.ModeBlob && typeOf(e.Mode) == object.ModeBlob {
+		mode = e.Mode
+	}
+	// and without core.symlinks a symlink is checked out as a plain file
+	if !c.symlinks && mode == object.ModeBlob && e.Mode == object.ModeSymlink {
+		mode = e.Mode
+	}
+	st.WorktreeMode = mode
+
+	if e.Mode == object.ModeGitlink {
+		if !fi.IsDir() {
+			st.Unstaged = 'T'
+			return nil
+		}
+		// a submodule that is not checked out is not a change
+		nested, err := repository.Open(full)
+		if err != nil {
+			return nil
+		}
+		head, err := refs.NewStore(nested).Head()
+		if err == nil && head != e.Hash {
+			st.Unstaged = 'M'
+			st.SubmoduleCommit = true
+		}
+		return nil
+	}
+	if e.IntentToAdd {
+		st.Unstaged = 'A'
+		return nil
+	}
+	if typeOf(mode) != typeOf(e.Mode) {
+		st.Unstaged = 'T'
+		return nil
+	}
+	if !e.Changed(fi) && !c.idx.IsRacy(e) {
+		return nil
+	}
+	hash, err := worktree.HashFile(c.repo.Objects, full, fi, false)
+	if err != nil {
+		return err
+	}
+	if hash != e.Hash || mode != e.Mode {
+		st.Unstaged = 'M'
+		return nil
+	}
+	// same content, remember the new stat data so it is not hashed again
+	if e.Changed(fi) {
+		e.SetStat(fi)
+		c.refresh = true
+	}
+	return nil
+}
+
+// typeOf tells regular files, symlinks and gitlinks apart, a change between
+// them is a type change rather than a modification
+func typeOf(mode object.FileMode) object.FileMode {
+	if mode == object.ModeExecutable {
+		return object.ModeBlob
+	}
+	return mode
+}
+
+// conflict describes an unmerged path from its stages
+func conflict(name string, stages []*index.Entry) *Entry {
+	st := &Entry{Path: name, Staged: 'U', Unstaged: 'U'}
+	var present [3]bool
+	for _, s := range stages {
+		present[s.Stage-1] = true
+		st.StageModes[s.Stage-1] = s.Mode
+		st.StageHashes[s.Stage-1] = s.Hash
+	}
+	base, ours, theirs := present[0], present[1], present[2]
+	switch {
+	case base && !ours && !theirs:
+		st.Conflict = "DD"
+	case !base && ours && !theirs:
+		st.Conflict = "AU"
+	case base && !ours && theirs:
+		st.Conflict = "DU"
+	case !base && !ours && theirs:
+		st.Conflict = "UA"
+	case base && ours && !theirs:
+		st.Conflict = "UD"
+	case !base && ours && theirs:
+		st.Conflict = "AA"
+	default:
+		st.Conflict = "UU"
+	}
+	st.Staged, st.Unstaged = st.Conflict[0], st.Conflict[1]
+	return st
+}
+
+// untracked lists the files that are neither tracked nor ignored. Unless
+// every file is asked for, a directory without tracked files is listed once
+// with a trailing slash instead of its content
+func (c *computer) untracked() ([]string, error) {
+	trackedDirs := make(map[string]bool)
+	for _, e := range c.idx.Entries {
+		for dir := path.Dir(e.Path); dir != "."; dir = path.Dir(dir) {
+			if trackedDirs[dir] {
+				break
+			}
+			trackedDirs[dir] = true
+		}
+	}
+
+	matcher := ignore.NewMatcher(c.repo.WorkTree, c.repo.CommonDir, c.cfg)
+	var untracked []string
+	seen := make(map[string]bool)
+	err := worktree.Walk(c.repo.WorkTree, matcher, func(name string, fi os.FileInfo) error {
+		if len(c.idx.Stages(name)) > 0 || !c.selected(name) {
+			return nil
+		}
+		report := name
+		if fi.IsDir() {
+			report += "/" // a nested repository
+		}
+		if c.opts.Untracked == UntrackedNormal {
+			parts := strings.Split(name, "/")
+			for i := 1; i < len(parts); i++ {
+				if dir := strings.Join(parts[:i], "/"); !trackedDirs[dir] {
+					report = dir + "/"
+					break
+				}
+			}
+		}
+		if !seen[report] {
+			seen[report] = true
+			untracked = append(untracked, report)
+		}
+		return nil
+	})
+	return untracked, err
+}
@@ -1,15 +1,14 @@
 package tree
 
 import (
-	"bufio"
-	"bytes"
-	"compress/zlib"
 	"errors"
 	"flag"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
 )
 
 // type Tree struct {
@@ -21,20 +20,21 @@ import (
 
 type LsTree struct {
 	Fs       *flag.FlagSet
+	Repo     *repository.Repository
 	ObjName  string
 	NameOnly bool
 }
 
 func (lstree *LsTree) Initialize(args []string) error {
-	if len(args) != 2 {
-		return errors.New("Invalid arguments")
-	}
 	lstree.Fs.BoolVar(&lstree.NameOnly, "name-only", false, "Name only")
 	err := lstree.Fs.Parse(args)
 	if err != nil {
 		return err
 	}
-	lstree.ObjName = args[len(args)-1]
+	if lstree.Fs.NArg() != 1 {
+		return errors.New("Invalid arguments")
+	}
+	lstree.ObjName = lstree.Fs.Arg(0)
 	// fmt.Println(lstree.NameOnly)
 	return nil
 }
@@ -44,64 +44,27 @@ func (lstree *LsTree) Usage() string {
 }
 
 func (lstree *LsTree) Run() error {
-	objPath := filepath.Join(".git/objects", lstree.ObjName[:2], lstree.ObjName[2:])
-	objFile, err := os.Open(objPath)
+	hash, err := revision.NewResolver(lstree.Repo).ResolveType(lstree.ObjName, packextractor.OBJ_TREE)
 	if err != nil {
 		return err
 	}
-	defer objFile.Close()
-	zlibReader, err := zlib.NewReader(objFile)
+	tree, err := object.GetTree(lstree.Repo.Objects, hash)
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(zlibReader)
-	scanner.Split(bufio.ScanBytes)
-	for scanner.Scan() {
-		scanByte := scanner.Bytes()[0]
-		if scanByte == 0 {
-			break
-		}
-	}
-	// start of the file content
-	var accumulator bytes.Buffer
-	for scanner.Scan() {
-
-		scanBytes := scanner.Bytes()
-		// fmt.Printf("%c\n", scanBytes[0])
-		scanByte := scanBytes[0]
-		if scanByte == 0 {
-			hash := make([]byte, 20)
-			i := 0
-			for {
-				if i == 20 {
-					break
-				}
-				scanner.Scan()
-				scanByte = scanner.Bytes()[0]
-				hash[i] = scanByte
-				i++
-			}
-			hashHex := fmt.Sprintf("%x", hash)
-			modeName := strings.SplitN(accumulator.String(), " ", 2)
-			mode := modeName[0]
 
-			Name := modeName[1]
-			entityType := "blob"
-			if mode[0] != '1' {
-				entityType = "tree"
-				mode = "0" + mode
-			}
-			if lstree.NameOnly {
-				fmt.Println(Name)
-			} else {
-				fmt.Printf("%s %s %s\t%s\n", mode, entityType, hashHex, Name)
-			}
-			accumulator.Reset()
-			continue
+	for _, entry := range tree.Entries {
+		if lstree.NameOnly {
+			fmt.Println(entry.Name)
+		} else {
+			fmt.Println(FormatEntry(entry))
 		}
-		accumulator.WriteByte(scanByte)
-
 	}
 
 	return nil
 }
+
+// FormatEntry renders an entry the way ls-tree and cat-file -p do
+func FormatEntry(entry object.TreeEntry) string {
+	return fmt.Sprintf("%s %s %s\t%s", entry.Mode, entry.Mode.ObjectType(), entry.Hash, entry.Name)
+}
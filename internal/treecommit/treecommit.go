@@ -1,48 +1,161 @@
 package treecommit
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
-	"github.com/codecrafters-io/git-starter-go/internal/treewriter"
+	"github.com/codecrafters-io/git-starter-go/internal/flags"
+	"github.com/codecrafters-io/git-starter-go/internal/identity"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
+	"github.com/codecrafters-io/git-starter-go/internal/revision"
 )
 
 type Treecommit struct {
-	Fs            *flag.FlagSet
-	parentHash    string
-	currentHash   string
-	commitMessage string
+	Fs           *flag.FlagSet
+	Repo         *repository.Repository
+	parentHashes flags.StringList
+	messages     flags.StringList
+	messageFiles flags.StringList
+	currentHash  string
 }
 
 func (t *Treecommit) Initialize(args []string) error {
-	if len(args) < 5 {
-		return fmt.Errorf("Not enough arguments")
+	t.Fs.Var(&t.parentHashes, "p", "Parent commit hash, may be given more than once")
+	t.Fs.Var(&t.messages, "m", "Commit message, each one becomes a paragraph")
+	t.Fs.Var(&t.messageFiles, "F", "Read the commit message from a file, - for stdin")
+
+	// the tree may come before or after the options
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		t.currentHash = args[0]
+		args = args[1:]
+	}
+	err := t.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	rest := t.Fs.Args()
+	if t.currentHash == "" && len(rest) > 0 {
+		t.currentHash, rest = rest[0], rest[1:]
+	}
+	if t.currentHash == "" || len(rest) > 0 {
+		return errors.New("Expected a single tree object")
 	}
-	t.currentHash = args[0]
-	t.Fs.StringVar(&t.parentHash, "p", "", "Parent tree hash")
-	t.Fs.StringVar(&t.commitMessage, "m", "", "Commit Message")
-	t.Fs.Parse(args[1:])
 	return nil
 }
 
 func (t *Treecommit) Usage() string {
-	return "Write a tree object"
+	return "git commit-tree <tree> [(-p <parent>)...] [(-m <message>)...] [(-F <file>)...] : Create a new commit object"
 }
 
 func (t *Treecommit) Run() error {
-	commitData := `tree ` + t.currentHash + `
-parent ` + t.parentHash + `
-author Arman Chhetri <armanchhetri44@gmail.com> 1712252028 +0545
-commiter Arman Chhetri <armanchhetri44@gmail.com> 1712252028 +0545
-
-` + t.commitMessage + "\n"
-	header := fmt.Sprintf("commit %d\x00", len(commitData))
-	commitDataBytes := []byte(header + commitData)
-	hash, err := treewriter.WriteObject(commitDataBytes)
+	resolver := revision.NewResolver(t.Repo)
+	tree, err := resolver.Resolve(t.currentHash)
+	if err != nil {
+		return err
+	}
+	// unlike the parents, the tree is not peeled from a commit or tag
+	if _, err := object.GetTree(t.Repo.Objects, tree); err != nil {
+		return fmt.Errorf("%s is not a valid tree: %v", t.currentHash, err)
+	}
+
+	commit := &object.Commit{Tree: tree}
+	for _, p := range t.parentHashes {
+		parent, err := resolver.ResolveType(p, packextractor.OBJ_COMMIT)
+		if err != nil {
+			return err
+		}
+		if containsHash(commit.Parents, parent) {
+			fmt.Fprintf(os.Stderr, "error: duplicate parent %s ignored\n", p)
+			continue
+		}
+		commit.Parents = append(commit.Parents, parent)
+	}
+
+	commit.Message, err = t.message()
 	if err != nil {
 		return err
 	}
-	// fmt.Println(commitData)
-	fmt.Printf("%x\n", hash)
+	hash, err := CommitTree(t.Repo, commit)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", hash)
 	return nil
 }
+
+// CommitTree fills in the author and committer that are still empty from the
+// environment and configuration, and stores the commit
+func CommitTree(repo *repository.Repository, commit *object.Commit) (objectstore.Hash, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	if commit.Author.Name == "" && commit.Author.Email == "" {
+		commit.Author, err = identity.Author(cfg)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+	}
+	if commit.Committer.Name == "" && commit.Committer.Email == "" {
+		commit.Committer, err = identity.Committer(cfg)
+		if err != nil {
+			return objectstore.ZeroHash, err
+		}
+	}
+	if encoding, ok := cfg.Get("i18n.commitencoding"); ok && !strings.EqualFold(encoding, "utf-8") {
+		commit.Encoding = encoding
+	}
+	return object.Put(repo.Objects, commit)
+}
+
+// message joins the -m and -F sources in order, like git each -m is its own
+// paragraph. Without either the message is read from stdin
+func (t *Treecommit) message() (string, error) {
+	var paragraphs []string
+	for _, m := range t.messages {
+		paragraphs = append(paragraphs, strings.TrimRight(m, "\n")+"\n")
+	}
+	for _, file := range t.messageFiles {
+		data, err := ReadMessageFile(file)
+		if err != nil {
+			return "", err
+		}
+		paragraphs = append(paragraphs, string(data))
+	}
+	if len(t.messages) == 0 && len(t.messageFiles) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return strings.Join(paragraphs, "\n"), nil
+}
+
+func containsHash(hashes []objectstore.Hash, hash objectstore.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadMessageFile reads the message given with -F, from stdin for "-"
+func ReadMessageFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read log file '%s': %v", name, err)
+	}
+	return data, nil
+}
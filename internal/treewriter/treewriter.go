@@ -1,117 +1,190 @@
 package treewriter
 
 import (
-	"bytes"
-	"compress/zlib"
-	"crypto/sha1"
 	"flag"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/index"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/repository"
 )
 
 type Treewriter struct {
-	Fs *flag.FlagSet
+	Fs        *flag.FlagSet
+	Repo      *repository.Repository
+	prefix    string // write the tree of this directory instead of the root
+	missingOk bool   // do not require the staged objects to exist
 }
 
-func NewTreewriter() *Treewriter {
-	return &Treewriter{}
+func NewTreewriter(repo *repository.Repository) *Treewriter {
+	return &Treewriter{Repo: repo}
 }
 
 func (t *Treewriter) Initialize(args []string) error {
+	t.Fs.StringVar(&t.prefix, "prefix", "", "Write the tree object of the <prefix> subdirectory")
+	t.Fs.BoolVar(&t.missingOk, "missing-ok", false, "Allow objects referenced by the index to be missing")
+	err := t.Fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if t.Fs.NArg() > 0 {
+		return fmt.Errorf("Unexpected argument %s", t.Fs.Arg(0))
+	}
+	t.prefix = strings.Trim(t.prefix, "/")
 	return nil
 }
 
 func (t *Treewriter) Usage() string {
-	return "Write all the objects to .git/objects directory"
+	return "git write-tree [--missing-ok] [--prefix=<prefix>/] : Create a tree object from the index"
 }
 
 func (t *Treewriter) Run() error {
-	// get current directory
-	currentDirectory, err := os.Getwd()
+	idx, err := t.Repo.ReadIndex()
 	if err != nil {
 		return err
 	}
-	hash, err := createAndWriteObjects(currentDirectory)
+	hash, err := WriteTree(t.Repo.Objects, idx, Options{Prefix: t.prefix, MissingOk: t.missingOk})
 	if err != nil {
 		return err
 	}
-	hash = hash[len(hash)-20:]
-	fmt.Printf("%x", hash)
+	// the refreshed TREE extension lets the next write-tree skip unchanged directories
+	if t.prefix == "" {
+		if err := t.Repo.WriteIndex(idx); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%s\n", hash)
 	return nil
 }
 
-func createAndWriteObjects(path string) ([]byte, error) {
-	if strings.Contains(path, ".git") {
-		return []byte{}, nil
+type Options struct {
+	Prefix    string // slash separated directory to write instead of the root
+	MissingOk bool
+}
+
+// WriteTree stores the trees for the staged entries of idx and returns the
+// root, or the tree of opts.Prefix. Directories whose TREE extension entry is
+// still valid are reused as they are, and without a prefix the extension is
+// brought up to date
+func WriteTree(store objectstore.ObjectStore, idx *index.Index, opts Options) (objectstore.Hash, error) {
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		var lines []string
+		for _, path := range unmerged {
+			lines = append(lines, fmt.Sprintf("%s: unmerged (%s)", path, idx.Stages(path)[0].Hash))
+		}
+		return objectstore.ZeroHash, fmt.Errorf("%s\nCould not write tree: the index has unmerged entries", strings.Join(lines, "\n"))
 	}
-	files, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
+	w := &writer{store: store, missingOk: opts.MissingOk}
+
+	entries := idx.Entries
+	dir := ""
+	var cache *index.CacheTree
+	if idx.Cache != nil {
+		cache = idx.Cache.Find(opts.Prefix)
 	}
-	var treeContents []byte
-	for _, file := range files {
-		if file.IsDir() {
-			treeContent, err := createAndWriteObjects(path + "/" + file.Name())
-			if err != nil {
-				return nil, err
-			}
-			treeContents = append(treeContents, treeContent...)
-		} else {
-			treeContent, _ := createBlobObject(path, file.Name())
-			treeContents = append(treeContents, treeContent...)
+	if opts.Prefix != "" {
+		dir = opts.Prefix + "/"
+		start := sort.Search(len(entries), func(i int) bool { return entries[i].Path >= dir })
+		end := start
+		for end < len(entries) && strings.HasPrefix(entries[end].Path, dir) {
+			end++
+		}
+		if start == end {
+			return objectstore.ZeroHash, fmt.Errorf("prefix %s not found", opts.Prefix)
 		}
+		entries = entries[start:end]
 	}
-	treeHdrStr := fmt.Sprintf("tree %d\x00", len(treeContents))
-	treeContents = append([]byte(treeHdrStr), treeContents...)
-	hash, err := WriteObject(treeContents)
+
+	node, err := w.build(entries, dir, cache)
 	if err != nil {
-		return nil, err
+		return objectstore.ZeroHash, err
 	}
-	treeContentStr := fmt.Sprintf("40000 %s\x00", filepath.Base(path)) // 0 is prepended by ls-tree and git's cat-file
-	treeContent := append([]byte(treeContentStr), hash[:]...)
-	// treeContent = append(treeContent, 0)
-	return treeContent, nil
+	if opts.Prefix == "" {
+		idx.Cache = node
+	}
+	return node.Hash, nil
 }
 
-func createBlobObject(path string, filename string) ([]byte, error) {
-	data, _ := os.ReadFile(filepath.Join(path, filename))
+type writer struct {
+	store     objectstore.ObjectStore
+	missingOk bool
+}
 
-	header := fmt.Sprintf("blob %d\x00", len(data))
-	finalData := append([]byte(header), data...)
-	hash, err := WriteObject(finalData)
-	if err != nil {
-		return nil, err
+// build writes the tree for entries, which all start with dir, and returns
+// the TREE extension node describing it. cache is the current node for dir
+// and may be nil
+func (w *writer) build(entries []*index.Entry, dir string, cache *index.CacheTree) (*index.CacheTree, error) {
+	name := strings.TrimSuffix(dir, "/")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if cache != nil && cache.Valid() && cache.EntryCount == len(entries) {
+		return cache, nil
 	}
 
-	treeContentStr := fmt.Sprintf("100644 %s\x00", filename)
-	treeContent := append([]byte(treeContentStr), hash[:]...)
-	// treeContent = append(treeContent, 0)
-	return treeContent, nil
-}
+	node := &index.CacheTree{Name: name, EntryCount: len(entries)}
+	tree := &object.Tree{}
+	for i := 0; i < len(entries); {
+		e := entries[i]
+		rel := e.Path[len(dir):]
+		slash := strings.IndexByte(rel, '/')
+		if slash < 0 {
+			i++
+			if e.IntentToAdd {
+				// not part of any tree until it is really added
+				node.EntryCount = -1
+				continue
+			}
+			if !w.missingOk && e.Mode != object.ModeGitlink && !w.store.Has(e.Hash) {
+				return nil, fmt.Errorf("invalid object %s %s for '%s'", e.Mode, e.Hash, e.Path)
+			}
+			tree.Entries = append(tree.Entries, object.TreeEntry{Mode: e.Mode, Name: rel, Hash: e.Hash})
+			continue
+		}
 
-func WriteObject(data []byte) ([]byte, error) {
-	hash := sha1.Sum(data)
-	hashStr := fmt.Sprintf("%x", hash)
-	prefix := hashStr[:2]
-	objFilename := hashStr[2:]
-	objDir := ".git/objects"
-	err := os.MkdirAll(filepath.Join(objDir, prefix), 0o755)
-	if err != nil {
-		return nil, err
-	}
-	var compressedData bytes.Buffer
-	w := zlib.NewWriter(&compressedData)
-	_, err = w.Write(data)
-	if err != nil {
-		return nil, err
+		subdir := dir + rel[:slash+1]
+		end := i
+		for end < len(entries) && strings.HasPrefix(entries[end].Path, subdir) {
+			end++
+		}
+		var subcache *index.CacheTree
+		if cache != nil {
+			subcache = cache.Find(rel[:slash])
+		}
+		child, err := w.build(entries[i:end], subdir, subcache)
+		if err != nil {
+			return nil, err
+		}
+		i = end
+		node.Children = append(node.Children, child)
+		if !child.Valid() {
+			node.EntryCount = -1
+		}
+		if child.Hash == emptyTree {
+			// a directory of intent-to-add files only
+			continue
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Mode: object.ModeTree, Name: child.Name, Hash: child.Hash})
 	}
+	tree.Sort()
 
-	w.Close()
-	err = os.WriteFile(filepath.Join(objDir, prefix, objFilename), compressedData.Bytes(), 0o644)
+	hash, err := object.Put(w.store, tree)
 	if err != nil {
 		return nil, err
 	}
-	return hash[:], nil
+	node.Hash = hash
+	// git keeps subtrees ordered by name length first
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i].Name, node.Children[j].Name
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	return node, nil
 }
+
+var emptyTree = object.HashOf(&object.Tree{})
@@ -0,0 +1,216 @@
+package worktree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codecrafters-io/git-starter-go/internal/ignore"
+	"github.com/codecrafters-io/git-starter-go/internal/object"
+	"github.com/codecrafters-io/git-starter-go/internal/objectstore"
+	"github.com/codecrafters-io/git-starter-go/internal/packextractor"
+)
+
+// ReadBlob returns the blob git stores for a file of the work tree: the
+// content of a regular file or the target of a symbolic link
+func ReadBlob(path string, fi os.FileInfo) (*objectstore.Object, error) {
+	var data []byte
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(filepath.ToSlash(target))
+	} else {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &objectstore.Object{Type: packextractor.OBJ_BLOB, Data: data}, nil
+}
+
+// HashBlob names obj and, when write is set, stores it
+func HashBlob(store objectstore.ObjectStore, obj *objectstore.Object, write bool) (objectstore.Hash, error) {
+	if !write {
+		return obj.Hash(), nil
+	}
+	return store.Put(obj)
+}
+
+// HashFile is ReadBlob followed by HashBlob
+func HashFile(store objectstore.ObjectStore, path string, fi os.FileInfo, write bool) (objectstore.Hash, error) {
+	obj, err := ReadBlob(path, fi)
+	if err != nil {
+		return objectstore.ZeroHash, err
+	}
+	return HashBlob(store, obj, write)
+}
+
+// WalkFunc gets the slash separated path of a file relative to the top of
+// the work tree along with its lstat information
+type WalkFunc func(name string, fi os.FileInfo) error
+
+// Walk calls fn for every file of the work tree that is not ignored, in
+// sorted order. Nested repositories are passed to fn as directories without
+// being entered, as are never the .git directory and ignored directories.
+// matcher may be nil to see everything
+func Walk(workTree string, matcher *ignore.Matcher, fn WalkFunc) error {
+	return walkDir(workTree, "", matcher, fn)
+}
+
+// WalkDir is like Walk for the files under dir only, a slash separated path
+// relative to the top of the work tree
+func WalkDir(workTree, dir string, matcher *ignore.Matcher, fn WalkFunc) error {
+	return walkDir(workTree, dir, matcher, fn)
+}
+
+func walkDir(workTree, dir string, matcher *ignore.Matcher, fn WalkFunc) error {
+	entries, err := os.ReadDir(filepath.Join(workTree, filepath.FromSlash(dir)))
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Name() != ".git" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rel := name
+		if dir != "" {
+			rel = dir + "/" + name
+		}
+		full := filepath.Join(workTree, filepath.FromSlash(rel))
+		fi, err := os.Lstat(full)
+		if err != nil {
+			return err
+		}
+		if matcher != nil && matcher.Ignored(rel, fi.IsDir()) {
+			continue
+		}
+		if !fi.IsDir() || IsNestedRepository(full) {
+			if err := fn(rel, fi); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := walkDir(workTree, rel, matcher, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsNestedRepository reports whether dir is the top of another work tree
+func IsNestedRepository(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// WriteEntry checks out a tree entry at path, replacing what is there: the
+// blob as a regular or executable file, a symbolic link (a plain file holding
+// the target when symlinks is false), or an empty directory for a gitlink
+// since submodules are not checked out. It returns the lstat of the result
+func WriteEntry(store objectstore.ObjectStore, path string, mode object.FileMode, hash objectstore.Hash, symlinks bool) (os.FileInfo, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if mode == object.ModeGitlink {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return nil, err
+		}
+		return os.Lstat(path)
+	}
+	blob, err := object.GetBlob(store, hash)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.IsDir() {
+			err = os.RemoveAll(path)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case mode == object.ModeSymlink && symlinks:
+		err = os.Symlink(filepath.FromSlash(string(blob.Data)), path)
+	case mode == object.ModeExecutable:
+		err = os.WriteFile(path, blob.Data, 0o755)
+	default:
+		err = os.WriteFile(path, blob.Data, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(path)
+}
+
+// ValidName is whether a tree entry name is safe to check out, like git's
+// verify_path: not empty, not "." or "..", not .git in any case, and without
+// slashes or NUL bytes that would make it several names or cut it short
+func ValidName(name string) bool {
+	switch {
+	case name == "", name == ".", name == "..", strings.EqualFold(name, ".git"):
+		return false
+	}
+	return !strings.ContainsAny(name, "/\x00")
+}
+
+// ValidPath is whether every name of a slash separated path is valid
+func ValidPath(path string) bool {
+	for _, name := range strings.Split(path, "/") {
+		if !ValidName(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// TreeFiles is every file of a tree, or of the tree of a commit, by slash
+// separated path, for checking it out. It fails on the first name that is
+// not valid, before anything is written
+func TreeFiles(store objectstore.ObjectStore, hash objectstore.Hash) (map[string]object.TreeEntry, error) {
+	files := make(map[string]object.TreeEntry)
+	if hash.IsZero() {
+		return files, nil
+	}
+	_, treeHash, err := object.PeelToTree(store, hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := object.GetTree(store, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	return files, treeFiles(store, tree, "", files)
+}
+
+func treeFiles(store objectstore.ObjectStore, tree *object.Tree, prefix string, files map[string]object.TreeEntry) error {
+	for _, entry := range tree.Entries {
+		name := prefix + entry.Name
+		if !ValidName(entry.Name) {
+			return fmt.Errorf("invalid path '%s'", name)
+		}
+		if !entry.Mode.IsTree() {
+			files[name] = entry
+			continue
+		}
+		subtree, err := object.GetTree(store, entry.Hash)
+		if err != nil {
+			return err
+		}
+		if err := treeFiles(store, subtree, name+"/", files); err != nil {
+			return err
+		}
+	}
+	return nil
+}